@@ -0,0 +1,354 @@
+// Package app wires the file caching service's handlers, routes and
+// middleware into an http.Handler. It is used by cmd/server and lets other
+// programs embed the service, e.g. with httptest.NewServer(a.Handler()).
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/ch374n/file-downloader/internal/cache"
+	"github.com/ch374n/file-downloader/internal/clock"
+	"github.com/ch374n/file-downloader/internal/config"
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/metrics"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// Aliases that make the internal types usable from outside the module
+type (
+	Config                    = config.Config
+	RedisConfig               = config.RedisConfig
+	R2Config                  = config.R2Config
+	MissStormConfig           = config.MissStormConfig
+	AdaptiveConcurrencyConfig = config.AdaptiveConcurrencyConfig
+	Cache                     = cache.Cache
+	Storage                   = storage.Storage
+	ObjectInfo                = storage.ObjectInfo
+	PresignedRequest          = storage.PresignedRequest
+)
+
+// LoadConfig reads the configuration from environment variables, using
+// defaults for anything unset
+func LoadConfig() *Config {
+	return config.Load()
+}
+
+// App is the fully wired service
+type App struct {
+	cfg       *Config
+	handler   http.Handler
+	accessLog *handlers.AccessLogWriter
+}
+
+// New wires the service around the given cache and storage. Pass a nil
+// Cache (not a typed nil pointer) to run without caching. It fails if the
+// configured key patterns don't compile, a timeout is below its minimum,
+// access tracking is combined with a Redis key secret or the metrics
+// backend can't be set up.
+func New(cfg *Config, c Cache, s Storage) (*App, error) {
+	if err := validateTimeouts(cfg); err != nil {
+		return nil, err
+	}
+	// The access log lists filenames, which a key secret keeps out of Redis
+	if cfg.AccessTrackingInterval > 0 && cfg.Redis.KeySecret != "" {
+		return nil, errors.New("ACCESS_TRACKING_INTERVAL can't be used with REDIS_KEY_SECRET")
+	}
+	allowPattern, err := compilePattern(cfg.KeyAllowPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KEY_ALLOW_PATTERN: %w", err)
+	}
+	denyPattern, err := compilePattern(cfg.KeyDenyPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KEY_DENY_PATTERN: %w", err)
+	}
+	transforms, err := resolveTransformers(cfg.TransformRules)
+	if err != nil {
+		return nil, err
+	}
+	appMetrics, err := newMetrics(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if notifier, ok := s.(storage.ReadFallbackNotifier); ok {
+		notifier.OnReadFallback(func(operation string) {
+			appMetrics.IncCounter(metrics.R2ReadFallbacks, metrics.Labels{"operation": operation})
+		})
+	}
+
+	handler := handlers.NewFileHandler(c, s,
+		handlers.WithMetrics(appMetrics),
+		handlers.WithNotFoundKey(cfg.NotFoundKey),
+		handlers.WithIndexFile(cfg.IndexFile),
+		handlers.WithAliases(cfg.Aliases),
+		handlers.WithRequestTimeout(cfg.RequestTimeout),
+		handlers.WithMinRequestTimeout(cfg.MinRequestTimeout),
+		handlers.WithHealthCheckTimeout(cfg.HealthCheckTimeout),
+		handlers.WithDrainGracePeriod(cfg.DrainGracePeriod),
+		handlers.WithMaintenanceMode(
+			cfg.Maintenance.Enabled,
+			cfg.Maintenance.Message,
+			cfg.Maintenance.RetryAfter,
+			cfg.Maintenance.ServeCached,
+		),
+		handlers.WithKeyPatterns(allowPattern, denyPattern),
+		handlers.WithRequiredTag(cfg.RequiredTagKey, cfg.RequiredTagValue),
+		handlers.WithKeyDecoding(handlers.KeyDecoding(cfg.KeyDecoding)),
+		handlers.WithKeyNormalization(handlers.KeyNormalization(cfg.KeyNormalization)),
+		handlers.WithRootMode(handlers.RootMode(cfg.RootMode), cfg.RootRedirectURL),
+		handlers.WithMaxRanges(cfg.MaxRanges),
+		handlers.WithRangeRequests(cfg.RangeRequests),
+		handlers.WithRangeCoalescing(int64(cfg.RangeCoalesceMaxSize)),
+		handlers.WithBase64MaxSize(int64(cfg.Base64MaxSize)),
+		handlers.WithDispositionDefaults(cfg.DispositionDefaults),
+		handlers.WithSlowStorageClasses(cfg.SlowStorageClasses),
+		handlers.WithStoredContentTypes(cfg.StoredContentTypes),
+		handlers.WithContentSniffing(cfg.SniffContentTypeExtensions, nil),
+		handlers.WithGeneratedETags(cfg.GeneratedETags),
+		handlers.WithTransformers(transforms),
+		handlers.WithUnsafeContentTypes(cfg.UnsafeContentTypes,
+			handlers.UnsafeTypeAction(cfg.UnsafeContentTypeAction)),
+		handlers.WithMissStormProtection(
+			cfg.MissStorm.Threshold,
+			cfg.MissStorm.ShedFraction,
+			cfg.MissStorm.MaxDelay,
+		),
+		handlers.WithGzipDecompression(cfg.GzipDecompress),
+		handlers.WithGzipRangeLimit(int64(cfg.GzipRangeMaxSize)),
+		handlers.WithGzipMaxInflatedSize(int64(cfg.GzipMaxInflatedSize)),
+		handlers.WithMaxBufferedSize(int64(cfg.MaxBufferedObjectSize)),
+		handlers.WithResponseBufferSize(cfg.ResponseBufferSize),
+		handlers.WithContentLengthCheck(cfg.ContentLengthCheck),
+		handlers.WithDownloadProgress(cfg.DownloadProgressInterval),
+		handlers.WithStorageRedirect(int64(cfg.RedirectMinSize), cfg.RedirectURLExpiry),
+		handlers.WithMemoryShedding(
+			uint64(cfg.MemoryShedThreshold),
+			int64(cfg.MemoryShedMinObjectSize),
+		),
+		handlers.WithAdaptiveConcurrency(
+			cfg.AdaptiveConcurrency.MinLimit,
+			cfg.AdaptiveConcurrency.MaxLimit,
+			cfg.AdaptiveConcurrency.TargetLatency,
+		),
+		handlers.WithUploadURLs(cfg.UploadContentTypes, cfg.UploadURLExpiry),
+		handlers.WithUploadMaxSize(int64(cfg.UploadMaxSize)),
+		handlers.WithReadAfterWriteRetry(
+			cfg.ReadAfterWriteWindow,
+			cfg.ReadAfterWriteRetries,
+			cfg.ReadAfterWriteDelay,
+		),
+		handlers.WithCacheTTLRules(cfg.Redis.CacheTTLRules),
+		handlers.WithSlidingExpiration(cfg.Redis.CacheTTL, cfg.Redis.MaxCacheLifetime),
+		handlers.WithStaleOnAuthError(cfg.Redis.StaleOnAuthErrorTTL),
+		handlers.WithStaleMaxAge(cfg.Redis.StaleMaxAge),
+		handlers.WithCacheOOMCooldown(cfg.Redis.OOMCooldown),
+		handlers.WithCacheWriteOnMiss(cfg.Redis.WriteOnMiss),
+		handlers.WithCacheReadRepair(cfg.Redis.ReadRepair),
+		handlers.WithVersionedCacheWrites(cfg.Redis.VersionedWrites),
+		handlers.WithWarmConcurrency(cfg.WarmConcurrency),
+		handlers.WithPrefetch(cfg.PrefetchRules, cfg.PrefetchConcurrency),
+		handlers.WithFallbackPrefixes(cfg.FallbackPrefixes),
+		handlers.WithAccessTracking(cfg.AccessTrackingInterval),
+		handlers.WithArchiveMaxFiles(cfg.ArchiveMaxFiles),
+		handlers.WithArchiveETags(cfg.ArchiveETags),
+		handlers.WithManifestMaxObjects(cfg.ManifestMaxObjects),
+		handlers.WithCacheTTLHeader(cfg.CacheTTLHeaderMax, cfg.AdminToken),
+		handlers.WithSigningKey([]byte(cfg.URLSigningKey)),
+		// Access logs are written to the bucket this service serves
+		handlers.WithPrivatePrefixes(cfg.AccessLogPrefix),
+	)
+
+	mux := http.NewServeMux()
+	withMetrics := handlers.NewMetricsMiddleware(appMetrics, cfg.MetricsRouteLabels)
+
+	// Data routes only answer requests from the CDN when an origin-pull
+	// secret is set; health, metrics and admin routes are reachable directly
+	originPull := func(next http.HandlerFunc) http.HandlerFunc {
+		return handlers.RequireOriginPullSecret(cfg.OriginPullSecret, next)
+	}
+
+	// Endpoints
+	mux.HandleFunc("GET /health", handler.Health)
+	mux.HandleFunc("GET /readyz", handler.Ready)
+	mux.HandleFunc("GET /", handler.Root)
+	// Data routes answer 503 during maintenance; single files may still be
+	// served from the cache, archives can't since a miss would cut one short
+	mux.HandleFunc("GET /files/{name}", withMetrics(originPull(handler.Maintenance(handler.GetFile, true))))
+	mux.HandleFunc("GET /f/{alias}", withMetrics(originPull(handler.Maintenance(handler.Alias, true))))
+	if cfg.URLSigningKey != "" {
+		mux.HandleFunc("GET /s/{sig}/files/{name}", withMetrics(originPull(handler.Maintenance(handler.SignedFile, true))))
+	}
+	if cfg.ArchiveMaxFiles > 0 {
+		mux.HandleFunc("POST /files/tar",
+			withMetrics(originPull(handler.Maintenance(limitBody(cfg, "/files/tar", handler.TarArchive), false))))
+	}
+	if cfg.ManifestMaxObjects > 0 {
+		mux.HandleFunc("GET /manifest/{prefix}/checksum",
+			withMetrics(originPull(handler.Maintenance(handler.ManifestChecksum, false))))
+	}
+	// Progress streams are long-lived, so they are kept out of the request
+	// duration metrics
+	if cfg.DownloadProgressInterval > 0 {
+		mux.HandleFunc("GET /files/{name}/progress", originPull(handler.DownloadProgress))
+	}
+	// Upload URLs let the caller write to the bucket, so like the admin
+	// endpoints they are only served with a token configured
+	if len(cfg.UploadContentTypes) > 0 && cfg.UploadToken != "" {
+		mux.HandleFunc("POST /files/{name}/upload-url",
+			withMetrics(originPull(handler.Maintenance(handlers.RequireBearerToken(cfg.UploadToken,
+				limitBody(cfg, "/files/{name}/upload-url", handler.UploadURL)), false))))
+	}
+
+	// Admin endpoints are only served with a token configured
+	if cfg.AdminToken != "" {
+		mux.HandleFunc("POST /admin/cache/warm", handlers.RequireBearerToken(cfg.AdminToken,
+			limitBody(cfg, "/admin/cache/warm", handler.WarmCache)))
+		mux.HandleFunc("POST /admin/drain", handlers.RequireBearerToken(cfg.AdminToken, handler.Drain))
+		if cfg.AccessTrackingInterval > 0 {
+			mux.HandleFunc("GET /admin/access-stats", handlers.RequireBearerToken(cfg.AdminToken, handler.AccessStats))
+		}
+		mux.HandleFunc("PUT /admin/maintenance", handlers.RequireBearerToken(cfg.AdminToken,
+			limitBody(cfg, "/admin/maintenance", handler.SetMaintenance)))
+		mux.HandleFunc("GET /admin/aliases", handlers.RequireBearerToken(cfg.AdminToken, handler.ListAliases))
+		mux.HandleFunc("PUT /admin/aliases/{alias}", handlers.RequireBearerToken(cfg.AdminToken,
+			limitBody(cfg, "/admin/aliases/{alias}", handler.SetAlias)))
+		mux.HandleFunc("DELETE /admin/aliases/{alias}", handlers.RequireBearerToken(cfg.AdminToken, handler.DeleteAlias))
+	}
+
+	// Prometheus metrics endpoint
+	if cfg.MetricsBackend == "prometheus" {
+		mux.Handle("GET /metrics", promhttp.Handler())
+	}
+
+	a := &App{cfg: cfg}
+	var root http.Handler = mux
+	if cfg.AccessLogPrefix != "" && cfg.AccessLogFlushInterval > 0 {
+		a.accessLog = handlers.NewAccessLogWriter(s, cfg.AccessLogPrefix,
+			cfg.AccessLogFlushInterval, cfg.AccessLogFlushRecords, clock.Real{})
+		root = a.accessLog.Middleware(root)
+	}
+	a.handler = handlers.RequestContext(root)
+	return a, nil
+}
+
+// minRedisTimeout is the shortest Redis dial, read or write timeout that
+// leaves room for a round trip
+const minRedisTimeout = time.Millisecond
+
+// validateTimeouts rejects timeouts too small for any request to succeed,
+// which would otherwise surface as every request failing with 504
+func validateTimeouts(cfg *Config) error {
+	if cfg.MinRequestTimeout < 0 {
+		return fmt.Errorf("invalid MIN_REQUEST_TIMEOUT %v: must not be negative", cfg.MinRequestTimeout)
+	}
+	// 0 leaves the handler's default timeout in place
+	if cfg.RequestTimeout < 0 || (cfg.RequestTimeout > 0 && cfg.RequestTimeout < cfg.MinRequestTimeout) {
+		return fmt.Errorf("invalid REQUEST_TIMEOUT %v: must be at least MIN_REQUEST_TIMEOUT (%v)",
+			cfg.RequestTimeout, cfg.MinRequestTimeout)
+	}
+
+	redisTimeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"REDIS_DIAL_TIMEOUT", cfg.Redis.DialTimeout},
+		{"REDIS_READ_TIMEOUT", cfg.Redis.ReadTimeout},
+		{"REDIS_WRITE_TIMEOUT", cfg.Redis.WriteTimeout},
+	}
+	for _, t := range redisTimeouts {
+		if t.value > 0 && t.value < minRedisTimeout {
+			return fmt.Errorf("invalid %s %v: must be at least %v", t.name, t.value, minRedisTimeout)
+		}
+	}
+	return nil
+}
+
+// Handler returns the service's routes with all middleware applied
+func (a *App) Handler() http.Handler {
+	return a.handler
+}
+
+// SignedPath returns a path serving key until expires without further
+// authorization, signed with the configured URL_SIGNING_KEY. It returns ""
+// when no signing key is configured.
+func (a *App) SignedPath(key string, expires time.Time) string {
+	if a.cfg.URLSigningKey == "" {
+		return ""
+	}
+	return handlers.SignPath([]byte(a.cfg.URLSigningKey), key, expires)
+}
+
+// Close releases what the app holds once the server has shut down,
+// writing out buffered access log records. It waits at most until ctx is
+// done.
+func (a *App) Close(ctx context.Context) error {
+	if a.accessLog != nil {
+		return a.accessLog.Close(ctx)
+	}
+	return nil
+}
+
+// Server returns an http.Server serving the app on the configured port
+func (a *App) Server() *http.Server {
+	return &http.Server{
+		Addr:              ":" + a.cfg.Port,
+		Handler:           a.handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// defaultBodyLimits are the request body size limits of routes that accept
+// a body, in bytes
+var defaultBodyLimits = map[string]int64{
+	"/files/{name}/upload-url": 4 << 10,
+	"/files/tar":               256 << 10,
+	"/admin/cache/warm":        1 << 20,
+	"/admin/maintenance":       1 << 10,
+	"/admin/aliases/{alias}":   4 << 10,
+}
+
+// limitBody applies the route's body size limit and the body read time
+// limits to next
+func limitBody(cfg *Config, route string, next http.HandlerFunc) http.HandlerFunc {
+	return handlers.LimitBody(bodyLimit(cfg, route),
+		handlers.LimitBodyReadTime(cfg.BodyReadTimeout, int64(cfg.BodyMinReadRate), next))
+}
+
+// bodyLimit returns the body size limit for a route template, preferring
+// the configured override
+func bodyLimit(cfg *Config, route string) int64 {
+	if limit, ok := cfg.BodyLimits[route]; ok {
+		return limit
+	}
+	return defaultBodyLimits[route]
+}
+
+// newMetrics returns the configured metrics backend
+func newMetrics(cfg *Config) (metrics.Metrics, error) {
+	switch cfg.MetricsBackend {
+	case "prometheus":
+		return metrics.NewPrometheus(prometheus.DefaultRegisterer), nil
+	case "statsd":
+		return metrics.NewStatsD(cfg.StatsDAddr, cfg.StatsDPrefix)
+	case "none", "":
+		return metrics.Nop{}, nil
+	default:
+		return nil, fmt.Errorf("invalid METRICS_BACKEND %q", cfg.MetricsBackend)
+	}
+}
+
+// compilePattern compiles a key pattern, returning nil for an empty one
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
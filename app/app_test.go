@@ -0,0 +1,292 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/app"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func newTestServer(t *testing.T, cfg *app.Config) (*httptest.Server, *mocks.MockStorage) {
+	t.Helper()
+
+	mockStorage := mocks.NewMockStorage()
+	a, err := app.New(cfg, mocks.NewMockCache(), mockStorage)
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	server := httptest.NewServer(a.Handler())
+	t.Cleanup(server.Close)
+	return server, mockStorage
+}
+
+func TestHandler_ServesFiles(t *testing.T) {
+	server, mockStorage := newTestServer(t, &app.Config{})
+	mockStorage.SetObject("hello.txt", []byte("hello"))
+
+	resp, err := http.Get(server.URL + "/files/hello.txt")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if string(body) != "hello" {
+		t.Errorf("Expected body 'hello', got '%s'", body)
+	}
+}
+
+func TestHandler_MetricsRecordRequests(t *testing.T) {
+	server, mockStorage := newTestServer(t, &app.Config{
+		MetricsBackend:     "prometheus",
+		MetricsRouteLabels: true,
+	})
+	mockStorage.SetObject("hello.txt", []byte("hello"))
+
+	resp, err := http.Get(server.URL + "/files/hello.txt")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if !strings.Contains(string(body), `path="/files/{name}"`) {
+		t.Error("Expected /metrics to include requests labelled with the route template")
+	}
+}
+
+func TestHandler_AccessLogsNotServed(t *testing.T) {
+	server, mockStorage := newTestServer(t, &app.Config{
+		AccessLogPrefix:        "logs/access/",
+		AccessLogFlushInterval: time.Hour,
+	})
+	mockStorage.SetObject("logs/access/2024/01/02/15-04-05-1a2b3c4d-000001.ndjson", []byte("{}"))
+
+	resp, err := http.Get(server.URL + "/files/logs%2Faccess%2F2024%2F01%2F02%2F15-04-05-1a2b3c4d-000001.ndjson")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestNew_InvalidMetricsBackend(t *testing.T) {
+	_, err := app.New(&app.Config{MetricsBackend: "graphite"}, nil, mocks.NewMockStorage())
+	if err == nil {
+		t.Error("Expected an error for an unknown metrics backend")
+	}
+}
+
+func TestNew_AccessTrackingWithKeySecret(t *testing.T) {
+	cfg := &app.Config{AccessTrackingInterval: time.Minute}
+	cfg.Redis.KeySecret = "secret"
+	if _, err := app.New(cfg, nil, mocks.NewMockStorage()); err == nil {
+		t.Error("Expected an error for access tracking with a key secret")
+	}
+}
+
+func TestHandler_OptionalRoutes(t *testing.T) {
+	// Unregistered POST routes fall through to the "GET /" catch-all, so
+	// they are answered with 405 rather than 404
+	tests := []struct {
+		name       string
+		cfg        *app.Config
+		path       string
+		wantStatus int
+	}{
+		{"admin disabled", &app.Config{}, "/admin/cache/warm", http.StatusMethodNotAllowed},
+		{"admin requires token", &app.Config{AdminToken: "s3cret"}, "/admin/cache/warm", http.StatusUnauthorized},
+		{"uploads disabled", &app.Config{}, "/files/a.png/upload-url", http.StatusMethodNotAllowed},
+		{"uploads disabled without token", &app.Config{UploadContentTypes: []string{"image/png"}}, "/files/a.png/upload-url", http.StatusMethodNotAllowed},
+		{"uploads require token", &app.Config{UploadContentTypes: []string{"image/png"}, UploadToken: "s3cret"}, "/files/a.png/upload-url", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newTestServer(t, tt.cfg)
+
+			resp, err := http.Post(server.URL+tt.path, "application/json", strings.NewReader(`{}`))
+			if err != nil {
+				t.Fatalf("POST failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestHandler_OriginPullSecret(t *testing.T) {
+	server, mockStorage := newTestServer(t, &app.Config{
+		OriginPullSecret: "cdn-secret",
+		MetricsBackend:   "prometheus",
+	})
+	mockStorage.SetObject("hello.txt", []byte("hello"))
+
+	tests := []struct {
+		name       string
+		path       string
+		secret     string
+		wantStatus int
+	}{
+		{"file with secret", "/files/hello.txt", "cdn-secret", http.StatusOK},
+		{"file without secret", "/files/hello.txt", "", http.StatusForbidden},
+		{"file with wrong secret", "/files/hello.txt", "guess", http.StatusForbidden},
+		{"alias without secret", "/f/logo", "", http.StatusForbidden},
+		{"health exempt", "/health", "", http.StatusOK},
+		{"metrics exempt", "/metrics", "", http.StatusOK},
+		{"root exempt", "/", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			if tt.secret != "" {
+				req.Header.Set("X-Origin-Pull-Secret", tt.secret)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("GET failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidKeyPattern(t *testing.T) {
+	_, err := app.New(&app.Config{KeyDenyPattern: "("}, nil, mocks.NewMockStorage())
+	if err == nil {
+		t.Error("Expected an error for an invalid key pattern")
+	}
+}
+
+func TestNew_InvalidTimeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *app.Config
+	}{
+		{"request timeout below minimum", &app.Config{RequestTimeout: time.Millisecond, MinRequestTimeout: 100 * time.Millisecond}},
+		{"negative request timeout", &app.Config{RequestTimeout: -time.Second}},
+		{"negative minimum", &app.Config{MinRequestTimeout: -time.Second}},
+		{"redis read timeout too small", &app.Config{Redis: app.RedisConfig{ReadTimeout: time.Microsecond}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := app.New(tt.cfg, nil, mocks.NewMockStorage()); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestApp_SignedPath(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	a, err := app.New(&app.Config{URLSigningKey: "s3cret"}, nil, mockStorage)
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	server := httptest.NewServer(a.Handler())
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + a.SignedPath("a.txt", time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Errorf("Expected 200 'hello', got %d '%s'", resp.StatusCode, body)
+	}
+}
+
+func TestHandler_RegisteredTransformer(t *testing.T) {
+	app.RegisterTransformer("shout", app.TransformerFunc(
+		func(_ context.Context, _, contentType string, data []byte) ([]byte, string, error) {
+			return bytes.ToUpper(data), contentType, nil
+		}))
+	server, mockStorage := newTestServer(t, &app.Config{
+		TransformRules: map[string]string{"text/*": "shout"},
+	})
+	mockStorage.SetObject("hello.txt", []byte("hello"))
+
+	resp, err := http.Get(server.URL + "/files/hello.txt")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if string(body) != "HELLO" {
+		t.Errorf("Expected transformed body 'HELLO', got %q", body)
+	}
+}
+
+func TestNew_UnknownTransformer(t *testing.T) {
+	_, err := app.New(&app.Config{TransformRules: map[string]string{"image/*": "missing"}}, nil, mocks.NewMockStorage())
+	if err == nil {
+		t.Fatal("Expected an error for an unregistered transformer")
+	}
+}
+
+// fallbackStorage records the read fallback hook the app installs
+type fallbackStorage struct {
+	*mocks.MockStorage
+	onFallback func(operation string)
+}
+
+func (s *fallbackStorage) OnReadFallback(fn func(operation string)) {
+	s.onFallback = fn
+}
+
+func TestNew_ReadFallbackMetric(t *testing.T) {
+	s := &fallbackStorage{MockStorage: mocks.NewMockStorage()}
+	a, err := app.New(&app.Config{MetricsBackend: "prometheus"}, nil, s)
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	if s.onFallback == nil {
+		t.Fatal("Expected the app to hook read fallbacks")
+	}
+	server := httptest.NewServer(a.Handler())
+	defer server.Close()
+
+	s.onFallback("get")
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if !strings.Contains(string(body), `r2_read_fallbacks_total{operation="get"} 1`) {
+		t.Error("Expected /metrics to count the read fallback")
+	}
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+)
+
+// Transformer post-processes objects before they are cached and served.
+// See RegisterTransformer.
+type Transformer = handlers.Transformer
+
+// TransformerFunc adapts a function to a Transformer
+type TransformerFunc = handlers.TransformerFunc
+
+var (
+	transformersMu sync.RWMutex
+	transformers   = map[string]Transformer{
+		"identity": handlers.IdentityTransformer{},
+	}
+)
+
+// RegisterTransformer makes t available to TRANSFORM_RULES under name.
+// Register transformers before calling New. "identity", which leaves
+// objects unchanged, is always available.
+func RegisterTransformer(name string, t Transformer) {
+	transformersMu.Lock()
+	defer transformersMu.Unlock()
+	transformers[name] = t
+}
+
+// resolveTransformers maps the configured content types to registered
+// transformers, failing on names that aren't registered
+func resolveTransformers(rules map[string]string) (map[string]Transformer, error) {
+	transformersMu.RLock()
+	defer transformersMu.RUnlock()
+
+	resolved := make(map[string]Transformer, len(rules))
+	for contentType, name := range rules {
+		t, ok := transformers[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid TRANSFORM_RULES: no transformer named %q", name)
+		}
+		resolved[contentType] = t
+	}
+	return resolved, nil
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// accessLogName is the sorted set last-access times are kept in, scored
+	// by Unix milliseconds, under the cache version prefix. The NUL byte
+	// keeps it apart from cached keys, which can't contain control
+	// characters.
+	accessLogName = "\x00fcs:last-access"
+
+	// defaultAccessLogMaxKeys is how many keys the access log keeps
+	defaultAccessLogMaxKeys = 100000
+
+	// accessScanPage is how many members LastAccesses reads per round trip
+	accessScanPage = 1000
+)
+
+// ErrAccessLogKeySecret is returned by the access log of a cache with a key
+// secret, which would otherwise store the filenames it hides in plain text
+var ErrAccessLogKeySecret = errors.New("access log is unavailable with a key secret")
+
+// Access is when a key was last read
+type Access struct {
+	Key string
+	At  time.Time
+}
+
+// AccessLog records when keys were last read, for finding cold objects
+type AccessLog interface {
+	// RecordAccess stores last-access times, keeping the later time for
+	// keys that already have one
+	RecordAccess(ctx context.Context, accesses map[string]time.Time) error
+
+	// LastAccesses returns up to limit keys starting with prefix, least
+	// recently read first
+	LastAccesses(ctx context.Context, prefix string, limit int) ([]Access, error)
+}
+
+// Ensure RedisCache implements AccessLog interface
+var _ AccessLog = (*RedisCache)(nil)
+
+// accessLogKey returns the Redis key of the access log
+func (c *RedisCache) accessLogKey() string {
+	return c.keyPrefix + accessLogName
+}
+
+// RecordAccess stores last-access times in one round trip, then drops the
+// least recently read keys beyond the cap. Keys are stored as they are
+// since the point is to list them, so a cache with a key secret refuses.
+func (c *RedisCache) RecordAccess(ctx context.Context, accesses map[string]time.Time) error {
+	if len(c.keySecret) > 0 {
+		return ErrAccessLogKeySecret
+	}
+	if len(accesses) == 0 {
+		return nil
+	}
+	members := make([]redis.Z, 0, len(accesses))
+	for key, at := range accesses {
+		members = append(members, redis.Z{Score: float64(at.UnixMilli()), Member: key})
+	}
+
+	key := c.accessLogKey()
+	err := withConnRetry(ctx, c.clock, func() error {
+		_, err := c.clients[c.db].TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZAddGT(ctx, key, members...)
+			pipe.ZRemRangeByRank(ctx, key, 0, int64(-c.accessLogMaxKeys-1))
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("redis access log error: %w", err)
+	}
+	return nil
+}
+
+// LastAccesses pages through the access log from the oldest entry,
+// keeping keys that start with prefix, until limit keys are found. Without
+// a prefix only the first limit entries are read. With one, a prefix few
+// keys match reads the whole log, accessScanPage members per round trip.
+func (c *RedisCache) LastAccesses(ctx context.Context, prefix string, limit int) ([]Access, error) {
+	if len(c.keySecret) > 0 {
+		return nil, ErrAccessLogKeySecret
+	}
+
+	pageSize := int64(accessScanPage)
+	if prefix == "" {
+		pageSize = int64(limit)
+	}
+
+	var accesses []Access
+	for start := int64(0); len(accesses) < limit; start += pageSize {
+		page, err := c.clients[c.db].ZRangeWithScores(ctx, c.accessLogKey(), start, start+pageSize-1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("redis access log error: %w", err)
+		}
+		for _, z := range page {
+			key, _ := z.Member.(string)
+			if strings.HasPrefix(key, prefix) && len(accesses) < limit {
+				accesses = append(accesses, Access{Key: key, At: time.UnixMilli(int64(z.Score))})
+			}
+		}
+		if int64(len(page)) < pageSize {
+			break
+		}
+	}
+	return accesses, nil
+}
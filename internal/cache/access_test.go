@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogKey(t *testing.T) {
+	c := &RedisCache{keyPrefix: "v2:"}
+	got := c.accessLogKey()
+	if !strings.HasPrefix(got, "v2:") {
+		t.Errorf("Expected the access log under the version prefix, got %q", got)
+	}
+	if got == c.redisKey("fcs:last-access") {
+		t.Error("Expected the access log apart from cached keys")
+	}
+}
+
+func TestAccessLog_RefusedWithKeySecret(t *testing.T) {
+	c := &RedisCache{keySecret: []byte("secret")}
+
+	err := c.RecordAccess(context.Background(), map[string]time.Time{"reports/q1.pdf": time.Now()})
+	if !errors.Is(err, ErrAccessLogKeySecret) {
+		t.Errorf("Expected ErrAccessLogKeySecret from RecordAccess, got %v", err)
+	}
+	if _, err := c.LastAccesses(context.Background(), "", 10); !errors.Is(err, ErrAccessLogKeySecret) {
+		t.Errorf("Expected ErrAccessLogKeySecret from LastAccesses, got %v", err)
+	}
+}
@@ -1,12 +1,26 @@
 package cache
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Cache defines the interface for caching operations
 // This allows for easy mocking in tests
 type Cache interface {
 	Get(ctx context.Context, key string) ([]byte, bool, error)
 	Set(ctx context.Context, key string, data []byte) error
+	SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error
+
+	// SetIfNewer stores data made from revision v unless the cached value
+	// is the same revision or a newer one, and reports whether it stored it
+	SetIfNewer(ctx context.Context, key string, data []byte, ttl time.Duration, v Version) (bool, error)
+
+	// Expire resets the TTL of an existing key without rewriting it
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+
+	// Delete removes key; a missing key is not an error
+	Delete(ctx context.Context, key string) error
 	Ping(ctx context.Context) error
 	Close() error
 }
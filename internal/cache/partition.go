@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// Partitions routes keys to Redis databases by the type of file they name.
+// Rules are keyed by a lowercase extension (".mp4"), a media type
+// ("video/mp4") or a media type family ("video/*"); the most specific rule
+// matching a key wins. Keys matching no rule use the default database.
+//
+// Routing only looks at the key, so a read always goes to the database
+// the write went to. Object size isn't known until after the lookup, so
+// large objects are partitioned by their types rather than their size.
+type Partitions map[string]int
+
+// db returns the database key is stored in
+func (p Partitions) db(key string, defaultDB int) int {
+	if len(p) == 0 {
+		return defaultDB
+	}
+
+	ext := strings.ToLower(filepath.Ext(key))
+	if db, ok := p[ext]; ok {
+		return db
+	}
+
+	mediaType, _, _ := strings.Cut(mime.TypeByExtension(ext), ";")
+	if mediaType == "" {
+		return defaultDB
+	}
+	if db, ok := p[mediaType]; ok {
+		return db
+	}
+	family, _, _ := strings.Cut(mediaType, "/")
+	if db, ok := p[family+"/*"]; ok {
+		return db
+	}
+	return defaultDB
+}
+
+// databases returns every database the partitions use besides defaultDB
+func (p Partitions) databases(defaultDB int) []int {
+	seen := map[int]bool{defaultDB: true}
+	var dbs []int
+	for _, db := range p {
+		if !seen[db] {
+			seen[db] = true
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs
+}
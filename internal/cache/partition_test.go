@@ -0,0 +1,52 @@
+package cache
+
+import "testing"
+
+func TestPartitions_DB(t *testing.T) {
+	p := Partitions{
+		"video/*":   1,
+		"video/mp4": 2,
+		".iso":      3,
+		".png":      4,
+	}
+
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"clips/intro.webm", 1},
+		{"clips/intro.mp4", 2},
+		{"clips/INTRO.MP4", 2},
+		{"images/disk.iso", 3},
+		{"stale:images/logo.png", 4},
+		{"docs/readme.txt", 0},
+		{"no-extension", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := p.db(tt.key, 0); got != tt.want {
+				t.Errorf("db(%q) = %d, want %d", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitions_Empty(t *testing.T) {
+	var p Partitions
+	if got := p.db("clips/intro.mp4", 5); got != 5 {
+		t.Errorf("Expected the default DB 5, got %d", got)
+	}
+	if dbs := p.databases(5); len(dbs) != 0 {
+		t.Errorf("Expected no extra databases, got %v", dbs)
+	}
+}
+
+func TestPartitions_Databases(t *testing.T) {
+	p := Partitions{"video/*": 1, ".iso": 1, "image/*": 0}
+
+	dbs := p.databases(0)
+	if len(dbs) != 1 || dbs[0] != 1 {
+		t.Errorf("Expected only DB 1 besides the default, got %v", dbs)
+	}
+}
@@ -2,10 +2,20 @@ package cache
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/ch374n/file-downloader/internal/clock"
 )
 
 // RedisConfig holds all Redis connection settings
@@ -17,19 +27,116 @@ type RedisConfig struct {
 	DialTimeout  time.Duration
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+
+	// IdleTimeout closes connections idle for longer than this, before a
+	// NAT or load balancer silently drops them; 0 keeps the client default
+	IdleTimeout time.Duration
+
+	// MaxIdleConns caps the idle connections kept in the pool; 0 is no cap
+	MaxIdleConns int
+
+	// Clock times retry backoffs; nil uses the real clock
+	Clock clock.Clock
+
+	// Version is mixed into every key so that changing it starts a fresh
+	// keyspace without flushing Redis; empty leaves keys unchanged
+	Version string
+
+	// KeySecret, when set, replaces every key with its HMAC-SHA256 under
+	// this secret so filenames can't be read or enumerated from Redis
+	KeySecret string
+
+	// Partitions stores some keys in databases other than DB, e.g. to
+	// tune eviction for large media separately; nil keeps every key in DB
+	Partitions Partitions
+
+	// AccessLogMaxKeys caps the keys kept in the access log, dropping the
+	// least recently read; 0 uses defaultAccessLogMaxKeys
+	AccessLogMaxKeys int
 }
 
+// connRetryBackoff is the pause before retrying an operation whose
+// connection dropped, long enough to ride out a quick Redis failover
+const connRetryBackoff = 50 * time.Millisecond
+
 type RedisCache struct {
-	client *redis.Client
-	ttl    time.Duration
+	// clients holds one client per database, keyed by number
+	clients    map[int]*redis.Client
+	db         int
+	partitions Partitions
+
+	ttl       time.Duration
+	clock     clock.Clock
+	keyPrefix string
+	keySecret []byte
+
+	accessLogMaxKeys int
 }
 
-// NewRedisCache creates a new Redis cache with the given configuration
+// NewRedisCache creates a new Redis cache with the given configuration,
+// connecting to DB and every database used by the partitions
 func NewRedisCache(cfg RedisConfig) (*RedisCache, error) {
-	client := redis.NewClient(&redis.Options{
+	clients := make(map[int]*redis.Client)
+	for _, db := range append([]int{cfg.DB}, cfg.Partitions.databases(cfg.DB)...) {
+		client, err := newRedisClient(cfg, db)
+		if err != nil {
+			for _, c := range clients {
+				c.Close()
+			}
+			return nil, err
+		}
+		clients[db] = client
+	}
+
+	clk := cfg.Clock
+	if clk == nil {
+		clk = clock.Real{}
+	}
+
+	accessLogMaxKeys := cfg.AccessLogMaxKeys
+	if accessLogMaxKeys <= 0 {
+		accessLogMaxKeys = defaultAccessLogMaxKeys
+	}
+
+	return &RedisCache{
+		clients:          clients,
+		db:               cfg.DB,
+		partitions:       cfg.Partitions,
+		ttl:              cfg.TTL,
+		clock:            clk,
+		keyPrefix:        versionPrefix(cfg.Version),
+		keySecret:        []byte(cfg.KeySecret),
+		accessLogMaxKeys: accessLogMaxKeys,
+	}, nil
+}
+
+// newRedisClient connects to database db and checks that it answers
+func newRedisClient(cfg RedisConfig, db int) (*redis.Client, error) {
+	client := redis.NewClient(redisOptions(cfg, db))
+
+	// Use dial timeout for ping
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout+5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis DB %d: %w", db, err)
+	}
+	return client, nil
+}
+
+// redisOptions returns the client options for database db
+func redisOptions(cfg RedisConfig, db int) *redis.Options {
+	// Warm idle connections can't exceed the idle cap
+	minIdleConns := 2
+	if cfg.MaxIdleConns > 0 {
+		minIdleConns = min(minIdleConns, cfg.MaxIdleConns)
+	}
+
+	return &redis.Options{
 		Addr:     cfg.Addr,
 		Password: cfg.Password,
-		DB:       cfg.DB,
+		DB:       db,
 
 		// Connection timeouts from config
 		DialTimeout:  cfg.DialTimeout,
@@ -37,32 +144,31 @@ func NewRedisCache(cfg RedisConfig) (*RedisCache, error) {
 		WriteTimeout: cfg.WriteTimeout,
 
 		// Connection pool settings
-		PoolSize:     10,
-		MinIdleConns: 2,
-		PoolTimeout:  cfg.ReadTimeout,
-
-		// Retry settings
-		MaxRetries:      3,
-		MinRetryBackoff: 100 * time.Millisecond,
-		MaxRetryBackoff: 500 * time.Millisecond,
-	})
-
-	// Use dial timeout for ping
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout+5*time.Second)
-	defer cancel()
+		PoolSize:        10,
+		MinIdleConns:    minIdleConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxIdleTime: cfg.IdleTimeout,
+		PoolTimeout:     cfg.ReadTimeout,
 
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		// Cache operations retry dropped connections once through
+		// withConnRetry, so the client doesn't retry on its own. -1, since
+		// 0 means the client's default of 3 retries.
+		MaxRetries: -1,
 	}
+}
 
-	return &RedisCache{
-		client: client,
-		ttl:    cfg.TTL,
-	}, nil
+// clientFor returns the client for the database key is partitioned to
+func (c *RedisCache) clientFor(key string) *redis.Client {
+	return c.clients[c.partitions.db(key, c.db)]
 }
 
 func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
-	data, err := c.client.Get(ctx, key).Bytes()
+	var data []byte
+	err := withConnRetry(ctx, c.clock, func() error {
+		var err error
+		data, err = c.clientFor(key).Get(ctx, c.redisKey(key)).Bytes()
+		return err
+	})
 	if err == redis.Nil {
 		// Key doesn't exist - cache miss
 		return nil, false, nil
@@ -70,23 +176,186 @@ func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error)
 	if err != nil {
 		return nil, false, fmt.Errorf("redis get error: %w", err)
 	}
-	// Cache hit
+	// Cache hit; values written by SetIfNewer carry their version first
+	_, data, _ = decodeVersioned(data)
 	return data, true, nil
 }
 
 func (c *RedisCache) Set(ctx context.Context, key string, data []byte) error {
-	err := c.client.Set(ctx, key, data, c.ttl).Err()
+	return c.SetWithTTL(ctx, key, data, c.ttl)
+}
+
+// SetWithTTL stores data under key with a TTL other than the configured
+// default. A ttl of 0 or less uses the default.
+func (c *RedisCache) SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	err := withConnRetry(ctx, c.clock, func() error {
+		return c.clientFor(key).Set(ctx, c.redisKey(key), data, ttl).Err()
+	})
 	if err != nil {
 		return fmt.Errorf("redis set error: %w", err)
 	}
 	return nil
 }
 
+// setIfNewerScript stores ARGV[4] under KEYS[1] for ARGV[5] milliseconds
+// unless the current value is framed by encodeVersioned (marker ARGV[1])
+// with the same ETag (ARGV[3]) or a later modification time than ARGV[2].
+// Only the start of the current value is read, and the fixed-width times
+// compare correctly as strings. Unversioned values are always replaced.
+var setIfNewerScript = redis.NewScript(`
+local magic = ARGV[1]
+local head = redis.call('GETRANGE', KEYS[1], 0, #magic + 21 + 999)
+if string.sub(head, 1, #magic) == magic then
+	local modified = string.sub(head, #magic + 1, #magic + 19)
+	local n = tonumber(string.sub(head, #magic + 20, #magic + 22))
+	local etag = string.sub(head, #magic + 23, #magic + 22 + n)
+	if etag == ARGV[3] or modified > ARGV[2] then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[4], 'PX', ARGV[5])
+return 1
+`)
+
+// SetIfNewer stores data under key as revision v unless the cached value
+// is the same revision or a newer one, checked and written atomically so
+// concurrent fetches racing an upload can't leave the older body cached.
+// It reports whether data was stored. A ttl of 0 or less uses the default.
+func (c *RedisCache) SetIfNewer(ctx context.Context, key string, data []byte, ttl time.Duration, v Version) (bool, error) {
+	value, ok := encodeVersioned(v, data)
+	if !ok {
+		return true, c.SetWithTTL(ctx, key, data, ttl)
+	}
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	var stored int64
+	err := withConnRetry(ctx, c.clock, func() error {
+		var err error
+		stored, err = setIfNewerScript.Run(ctx, c.clientFor(key), []string{c.redisKey(key)},
+			versionMagic,
+			fmt.Sprintf("%0*d", modifiedWidth, unixNanos(v.Modified)),
+			v.ETag,
+			value,
+			ttl.Milliseconds(),
+		).Int64()
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("redis set error: %w", err)
+	}
+	return stored == 1, nil
+}
+
+// Expire resets key's TTL to ttl, or the default when ttl is 0 or less.
+// A key that has already expired is left missing.
+func (c *RedisCache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	err := withConnRetry(ctx, c.clock, func() error {
+		return c.clientFor(key).Expire(ctx, c.redisKey(key), ttl).Err()
+	})
+	if err != nil {
+		return fmt.Errorf("redis expire error: %w", err)
+	}
+	return nil
+}
+
+// Delete removes key. Deleting a missing key succeeds.
+func (c *RedisCache) Delete(ctx context.Context, key string) error {
+	err := withConnRetry(ctx, c.clock, func() error {
+		return c.clientFor(key).Del(ctx, c.redisKey(key)).Err()
+	})
+	if err != nil {
+		return fmt.Errorf("redis delete error: %w", err)
+	}
+	return nil
+}
+
+// redisKey returns the Redis key the logical key is stored under. Every
+// operation on a key must go through it so they all agree.
+func (c *RedisCache) redisKey(key string) string {
+	if len(c.keySecret) == 0 {
+		return c.keyPrefix + key
+	}
+	mac := hmac.New(sha256.New, c.keySecret)
+	mac.Write([]byte(key))
+	return c.keyPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// versionPrefix returns the key prefix for a cache version
+func versionPrefix(version string) string {
+	if version == "" {
+		return ""
+	}
+	return "v" + version + ":"
+}
+
 func (c *RedisCache) Close() error {
-	return c.client.Close()
+	var errs []error
+	for _, client := range c.clients {
+		errs = append(errs, client.Close())
+	}
+	return errors.Join(errs...)
 }
 
-// Ping checks if Redis connection is alive
+// Ping checks that the connection to every database is alive
 func (c *RedisCache) Ping(ctx context.Context) error {
-	return c.client.Ping(ctx).Err()
+	for db, client := range c.clients {
+		if err := client.Ping(ctx).Err(); err != nil {
+			if len(c.clients) == 1 {
+				return err
+			}
+			return fmt.Errorf("redis DB %d: %w", db, err)
+		}
+	}
+	return nil
+}
+
+// withConnRetry runs op and retries it once after a short backoff if it
+// failed because the connection was dropped or refused. Any other error,
+// including logical ones like WRONGTYPE, is returned without a retry.
+func withConnRetry(ctx context.Context, clk clock.Clock, op func() error) error {
+	err := op()
+	if err == nil || !isConnError(err) {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return err
+	case <-clk.After(connRetryBackoff):
+	}
+
+	return op()
+}
+
+// isConnError reports whether err comes from the connection itself rather
+// than from Redis processing the command
+func isConnError(err error) bool {
+	if errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	// A read or write timeout is a slow server, not a dropped connection,
+	// and retrying it would only double the wait
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && !opErr.Timeout()
+}
+
+// IsOutOfMemory reports whether err is Redis refusing a write because it
+// has reached maxmemory under the noeviction policy
+func IsOutOfMemory(err error) bool {
+	var redisErr redis.Error
+	return errors.As(err, &redisErr) && strings.HasPrefix(redisErr.Error(), "OOM ")
 }
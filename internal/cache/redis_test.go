@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/ch374n/file-downloader/internal/clock"
+)
+
+// manualClock fires After channels only when advanced. mocks.MockClock
+// can't be used here since the mocks package imports this one.
+type manualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []chan time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, ch)
+	return ch
+}
+
+func (c *manualClock) WaiterCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// Advance moves the clock forward and fires every pending After channel
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, ch := range c.waiters {
+		ch <- c.now
+	}
+	c.waiters = nil
+}
+
+func TestIsConnError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"EOF", io.EOF, true},
+		{"unexpected EOF", io.ErrUnexpectedEOF, true},
+		{"wrapped EOF", fmt.Errorf("read: %w", io.EOF), true},
+		{"connection refused", syscall.ECONNREFUSED, true},
+		{"connection reset", syscall.ECONNRESET, true},
+		{"net op error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("no route")}, true},
+		{"closed connection", net.ErrClosed, true},
+		{"read timeout", &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, false},
+		{"cache miss", redis.Nil, false},
+		{"wrong type", errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnError(tt.err); got != tt.want {
+				t.Errorf("isConnError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithConnRetry_RetriesOnceOnConnError(t *testing.T) {
+	calls := 0
+	err := withConnRetry(context.Background(), clock.Real{}, func() error {
+		calls++
+		if calls == 1 {
+			return io.EOF
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("Expected no error after retry, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
+
+func TestWithConnRetry_GivesUpAfterOneRetry(t *testing.T) {
+	calls := 0
+	err := withConnRetry(context.Background(), clock.Real{}, func() error {
+		calls++
+		return syscall.ECONNREFUSED
+	})
+
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Expected ECONNREFUSED, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
+
+func TestWithConnRetry_NoRetryOnLogicalError(t *testing.T) {
+	logicalErr := errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	calls := 0
+	err := withConnRetry(context.Background(), clock.Real{}, func() error {
+		calls++
+		return logicalErr
+	})
+
+	if err != logicalErr {
+		t.Errorf("Expected logical error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestWithConnRetry_StopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := withConnRetry(ctx, clock.Real{}, func() error {
+		calls++
+		return io.EOF
+	})
+
+	if err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestWithConnRetry_WaitsForBackoff(t *testing.T) {
+	clk := &manualClock{now: time.Now()}
+
+	var calls atomic.Int32
+	done := make(chan error, 1)
+	go func() {
+		done <- withConnRetry(context.Background(), clk, func() error {
+			if calls.Add(1) == 1 {
+				return io.EOF
+			}
+			return nil
+		})
+	}()
+
+	for clk.WaiterCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("Expected no retry before the backoff, got %d calls", calls.Load())
+	}
+
+	clk.Advance(connRetryBackoff)
+
+	if err := <-done; err != nil {
+		t.Errorf("Expected success on retry, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls.Load())
+	}
+}
+
+func TestVersionPrefix(t *testing.T) {
+	if got := versionPrefix(""); got != "" {
+		t.Errorf("Expected no prefix without a version, got '%s'", got)
+	}
+	if got := versionPrefix("2"); got != "v2:" {
+		t.Errorf("Expected prefix 'v2:', got '%s'", got)
+	}
+}
+
+func TestRedisKey(t *testing.T) {
+	plain := &RedisCache{keyPrefix: "v2:"}
+	if got := plain.redisKey("reports/q1.pdf"); got != "v2:reports/q1.pdf" {
+		t.Errorf("Expected the key unchanged without a secret, got '%s'", got)
+	}
+
+	hashed := &RedisCache{keyPrefix: "v2:", keySecret: []byte("secret")}
+	got := hashed.redisKey("reports/q1.pdf")
+	want := "v2:" + hmacHex("secret", "reports/q1.pdf")
+	if got != want {
+		t.Errorf("Expected key '%s', got '%s'", want, got)
+	}
+	if strings.Contains(got, "reports") {
+		t.Errorf("Expected the filename to be hidden, got '%s'", got)
+	}
+	if hashed.redisKey("stale:reports/q1.pdf") == got {
+		t.Error("Expected different keys to map to different Redis keys")
+	}
+
+	other := &RedisCache{keyPrefix: "v2:", keySecret: []byte("other")}
+	if other.redisKey("reports/q1.pdf") == got {
+		t.Error("Expected a different secret to give a different Redis key")
+	}
+}
+
+func hmacHex(secret, key string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// replyError is an error reply from Redis, like the client's own
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+func (replyError) RedisError()     {}
+
+func TestIsOutOfMemory(t *testing.T) {
+	oom := replyError("OOM command not allowed when used memory > 'maxmemory'.")
+	if !IsOutOfMemory(fmt.Errorf("redis set error: %w", oom)) {
+		t.Error("Expected a wrapped OOM reply to be detected")
+	}
+	if IsOutOfMemory(replyError("ERR unknown command")) {
+		t.Error("Expected other Redis errors not to count as OOM")
+	}
+	if IsOutOfMemory(errors.New("OOM but not from Redis")) {
+		t.Error("Expected non-Redis errors not to count as OOM")
+	}
+}
+
+func TestRedisOptions_NoClientRetries(t *testing.T) {
+	opts := redisOptions(RedisConfig{Addr: "localhost:6379"}, 0)
+	if opts.MaxRetries != -1 {
+		t.Errorf("Expected client retries off in favour of withConnRetry, got MaxRetries %d", opts.MaxRetries)
+	}
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Version identifies the revision of an object a cached value was made from
+type Version struct {
+	ETag     string
+	Modified time.Time
+}
+
+// Replaces reports whether a value made from v may overwrite one made from
+// stored: only when it is a different revision that isn't older. ETags
+// aren't ordered, so the modification time decides which is newer.
+func (v Version) Replaces(stored Version) bool {
+	return v.ETag != stored.ETag && !stored.Modified.After(v.Modified)
+}
+
+// versionMagic marks values written by SetIfNewer. They are framed as the
+// marker, the modification time as modifiedWidth zero-padded digits of
+// Unix nanoseconds, the ETag length as etagLenWidth digits, the ETag and
+// the data. The fixed widths let setIfNewerScript compare versions by
+// reading only the start of the value.
+var versionMagic = []byte("\x00fcs-ver\x01")
+
+const (
+	modifiedWidth = 19
+	etagLenWidth  = 3
+	maxETagLen    = 999
+)
+
+// encodeVersioned frames data with v. ok is false for versions that can't
+// be framed, whose data should be stored unversioned.
+func encodeVersioned(v Version, data []byte) (value []byte, ok bool) {
+	if len(v.ETag) > maxETagLen {
+		return nil, false
+	}
+	value = make([]byte, 0, len(versionMagic)+modifiedWidth+etagLenWidth+len(v.ETag)+len(data))
+	value = append(value, versionMagic...)
+	value = fmt.Appendf(value, "%0*d%0*d", modifiedWidth, unixNanos(v.Modified), etagLenWidth, len(v.ETag))
+	value = append(value, v.ETag...)
+	value = append(value, data...)
+	return value, true
+}
+
+// decodeVersioned splits a value written by encodeVersioned. Values without
+// the marker are returned unchanged with ok false.
+func decodeVersioned(value []byte) (v Version, data []byte, ok bool) {
+	rest, found := bytes.CutPrefix(value, versionMagic)
+	if !found || len(rest) < modifiedWidth+etagLenWidth {
+		return Version{}, value, false
+	}
+	nanos, err := strconv.ParseInt(string(rest[:modifiedWidth]), 10, 64)
+	if err != nil {
+		return Version{}, value, false
+	}
+	n, err := strconv.Atoi(string(rest[modifiedWidth : modifiedWidth+etagLenWidth]))
+	rest = rest[modifiedWidth+etagLenWidth:]
+	if err != nil || n > len(rest) {
+		return Version{}, value, false
+	}
+	if nanos > 0 {
+		v.Modified = time.Unix(0, nanos)
+	}
+	v.ETag = string(rest[:n])
+	return v, rest[n:], true
+}
+
+// unixNanos returns t in Unix nanoseconds, or 0 for the zero time and
+// times before 1970
+func unixNanos(t time.Time) int64 {
+	if t.IsZero() || t.Before(time.Unix(0, 0)) {
+		return 0
+	}
+	return t.UnixNano()
+}
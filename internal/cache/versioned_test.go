@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeVersioned_RoundTrip(t *testing.T) {
+	v := Version{ETag: `"abc123"`, Modified: time.Unix(1700000000, 5)}
+	value, ok := encodeVersioned(v, []byte("body"))
+	if !ok {
+		t.Fatal("Expected the version to be framed")
+	}
+
+	got, data, ok := decodeVersioned(value)
+	if !ok {
+		t.Fatal("Expected a versioned value")
+	}
+	if got.ETag != v.ETag || !got.Modified.Equal(v.Modified) {
+		t.Errorf("Expected version %v, got %v", v, got)
+	}
+	if string(data) != "body" {
+		t.Errorf("Expected data 'body', got '%s'", data)
+	}
+}
+
+func TestDecodeVersioned_Unversioned(t *testing.T) {
+	for _, value := range [][]byte{[]byte("plain body"), versionMagic, nil} {
+		_, data, ok := decodeVersioned(value)
+		if ok {
+			t.Errorf("%q: expected an unversioned value", value)
+		}
+		if !bytes.Equal(data, value) {
+			t.Errorf("%q: expected the value unchanged, got %q", value, data)
+		}
+	}
+}
+
+func TestEncodeVersioned_LongETag(t *testing.T) {
+	if _, ok := encodeVersioned(Version{ETag: strings.Repeat("x", maxETagLen+1)}, nil); ok {
+		t.Error("Expected an over-long ETag not to be framed")
+	}
+}
+
+func TestVersion_Replaces(t *testing.T) {
+	older := time.Unix(1000, 0)
+	newer := time.Unix(2000, 0)
+
+	tests := []struct {
+		name   string
+		v      Version
+		stored Version
+		want   bool
+	}{
+		{"newer revision", Version{"b", newer}, Version{"a", older}, true},
+		{"older revision", Version{"a", older}, Version{"b", newer}, false},
+		{"same revision", Version{"a", newer}, Version{"a", newer}, false},
+		{"same time, different ETag", Version{"b", newer}, Version{"a", newer}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.v.Replaces(tt.stored); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
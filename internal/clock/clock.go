@@ -0,0 +1,27 @@
+// Package clock abstracts the passage of time so that TTL, window and
+// backoff logic can be tested without sleeping.
+package clock
+
+import "time"
+
+// Clock tells the time and waits for durations to pass
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the Clock backed by the time package
+type Real struct{}
+
+// Now returns the current time
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// After waits for d to pass and then sends the current time
+func (Real) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Ensure Real implements Clock interface
+var _ Clock = Real{}
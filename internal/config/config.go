@@ -20,6 +20,273 @@ type Config struct {
 	LogLevel string
 	Redis    RedisConfig
 	R2       R2Config
+
+	// NotFoundKey is the storage key of an object served as the body of
+	// 404 responses. Empty means the default JSON error is used.
+	NotFoundKey string
+
+	// IndexFile is the object served for requests for a prefix, i.e. keys
+	// ending in "/", e.g. "index.html"; empty disables it
+	IndexFile string
+
+	// Aliases maps short names served under /f/{alias} to object keys
+	Aliases map[string]string
+
+	// RequiredTag restricts serving to objects carrying this tag,
+	// configured as "key:value". Empty key means no restriction.
+	RequiredTagKey   string
+	RequiredTagValue string
+
+	// KeyAllowPattern/KeyDenyPattern are regular expressions restricting
+	// which keys can be requested; empty disables each
+	KeyAllowPattern string
+	KeyDenyPattern  string
+
+	// RequestTimeout caps how long a file request may take; callers can
+	// ask for less with X-Timeout-Ms
+	RequestTimeout time.Duration
+
+	// SlowStorageClasses are storage classes whose objects are served with
+	// an X-Storage-Class header; empty sends it for none
+	SlowStorageClasses []string
+
+	// DispositionDefaults maps file extensions to the Content-Disposition
+	// type, "inline" or "attachment", they are served with by default
+	DispositionDefaults map[string]string
+
+	// TransformRules maps media types ("text/html") or families
+	// ("image/*") to the name of a registered transformer applied to
+	// objects of that type before they are cached and served
+	TransformRules map[string]string
+
+	// Base64MaxSize is the largest object served base64-encoded in a JSON
+	// envelope on request; 0 disables envelopes
+	Base64MaxSize int
+
+	// StoredContentTypes serves objects with the Content-Type stored in R2
+	// when it is specific, falling back to the extension otherwise
+	StoredContentTypes bool
+
+	// SniffContentTypeExtensions are extensions whose objects are served
+	// with the type detected from their first bytes when it differs from
+	// the extension's
+	SniffContentTypeExtensions []string
+
+	// GeneratedETags gives objects stored without an ETag a weak one so
+	// conditional requests still work
+	GeneratedETags bool
+
+	// UnsafeContentTypes are content types never served as-is, e.g.
+	// text/html from user uploads; empty serves every type unchanged
+	UnsafeContentTypes []string
+
+	// UnsafeContentTypeAction is how unsafe types are served instead:
+	// "attachment" (default), "plain" or "reject"
+	UnsafeContentTypeAction string
+
+	// HealthCheckTimeout bounds each dependency check in /health
+	HealthCheckTimeout time.Duration
+
+	// DrainGracePeriod is how long after POST /admin/drain the instance
+	// keeps serving before it is reported safe to terminate
+	DrainGracePeriod time.Duration
+
+	// MinRequestTimeout is the shortest X-Timeout-Ms budget honored;
+	// smaller ones are raised to it. REQUEST_TIMEOUT may not be below it.
+	MinRequestTimeout time.Duration
+
+	// KeyDecoding selects how request paths are decoded into storage keys:
+	// "path" (default), "plus" or "double"
+	KeyDecoding string
+
+	// KeyNormalization selects the Unicode form requested keys are
+	// normalized to: "none" (default), "nfc" or "nfd"
+	KeyNormalization string
+
+	// RootMode selects what "/" serves: "info" (default), "health" or
+	// "redirect" to RootRedirectURL
+	RootMode        string
+	RootRedirectURL string
+
+	// MaxRanges caps the number of byte ranges accepted in one request
+	MaxRanges int
+
+	// RangeRequests honors Range headers; disabled, full bodies are always
+	// served with Accept-Ranges: none
+	RangeRequests bool
+
+	// RangeCoalesceMaxSize is the largest range, in bytes, whose storage
+	// read is shared by concurrent requests for it; 0 disables sharing
+	RangeCoalesceMaxSize int
+
+	MissStorm MissStormConfig
+
+	// GzipDecompress inflates gzip-stored objects for clients that don't
+	// send Accept-Encoding: gzip
+	GzipDecompress bool
+
+	// GzipRangeMaxSize is the largest inflated size for which Range
+	// requests on decompressed objects are honored
+	GzipRangeMaxSize int
+
+	// GzipMaxInflatedSize caps how far an object is inflated on the fly;
+	// responses past it are aborted. 0 is unlimited
+	GzipMaxInflatedSize int
+
+	// ContentLengthCheck fails reads whose body doesn't match the
+	// Content-Length R2 reported, rather than serving a partial file
+	ContentLengthCheck bool
+
+	// MaxBufferedObjectSize is the largest object held in memory; larger
+	// ones are streamed and not cached. 0 buffers everything.
+	MaxBufferedObjectSize int
+
+	// ResponseBufferSize is how many bytes of a streamed response are
+	// collected before they are written to the client; 0 writes each read
+	// from storage as it comes
+	ResponseBufferSize int
+
+	// DownloadProgressInterval is how often GET /files/{name}/progress
+	// reports on a streamed download; 0 disables progress reporting
+	DownloadProgressInterval time.Duration
+
+	// RedirectMinSize is the size above which objects fetched from
+	// storage are served by a redirect to a presigned URL valid for
+	// RedirectURLExpiry; 0 always proxies
+	RedirectMinSize   int
+	RedirectURLExpiry time.Duration
+
+	// MemoryShedThreshold is the memory use in bytes above which cache
+	// misses for objects of at least MemoryShedMinObjectSize bytes are
+	// rejected with 503; 0 disables shedding
+	MemoryShedThreshold     int
+	MemoryShedMinObjectSize int
+
+	// AdaptiveConcurrency bounds concurrent file fetches by a limit that
+	// shrinks as fetch latency rises above its target
+	AdaptiveConcurrency AdaptiveConcurrencyConfig
+
+	// Maintenance answers data routes with 503 during planned downtime
+	Maintenance MaintenanceConfig
+
+	// UploadContentTypes lists the content types clients may request
+	// presigned upload URLs for. The endpoint requires UploadToken as a
+	// bearer token and is disabled unless both are set.
+	UploadContentTypes []string
+	UploadURLExpiry    time.Duration
+	UploadToken        string
+
+	// UploadMaxSize is the largest upload, in bytes, an upload URL is
+	// issued for
+	UploadMaxSize int
+
+	// ReadAfterWriteWindow is how long after an upload URL expires a read
+	// that finds no object is retried ReadAfterWriteRetries times,
+	// ReadAfterWriteDelay apart; 0 disables the retries
+	ReadAfterWriteWindow  time.Duration
+	ReadAfterWriteRetries int
+	ReadAfterWriteDelay   time.Duration
+
+	// AdminToken is the bearer token for /admin endpoints; empty disables
+	// them
+	AdminToken string
+
+	// OriginPullSecret is the X-Origin-Pull-Secret value data routes
+	// require, so only the CDN can reach them; empty leaves them open
+	OriginPullSecret string
+
+	// URLSigningKey is the secret for signed /s/{sig}/files/{name} paths;
+	// empty disables them
+	URLSigningKey string
+
+	// CacheTTLHeaderMax caps the X-Cache-TTL override admins may send on
+	// file requests; 0 ignores the header
+	CacheTTLHeaderMax time.Duration
+
+	// WarmConcurrency bounds parallel fetches when warming the cache
+	WarmConcurrency int
+
+	// PrefetchRules maps keys to related keys warmed into the cache in the
+	// background when the key is served; empty disables prefetch
+	PrefetchRules map[string][]string
+
+	// FallbackPrefixes maps key prefixes to the prefixes a missing object
+	// is looked up under next, in order; empty disables the retries
+	FallbackPrefixes map[string][]string
+
+	// PrefetchConcurrency bounds parallel prefetches of related keys
+	PrefetchConcurrency int
+
+	// AccessTrackingInterval is how often last-access times of served
+	// objects are written to Redis; 0 disables access tracking. It can't
+	// be combined with a Redis key secret.
+	AccessTrackingInterval time.Duration
+
+	// AccessTrackingMaxKeys caps the keys whose last access is kept,
+	// dropping the least recently served
+	AccessTrackingMaxKeys int
+
+	// AccessLogPrefix is the storage prefix access log objects are
+	// written under; empty disables the access log
+	AccessLogPrefix string
+
+	// AccessLogFlushInterval and AccessLogFlushRecords bound how long and
+	// how many records the access log buffers before writing an object
+	AccessLogFlushInterval time.Duration
+	AccessLogFlushRecords  int
+
+	// MetricsRouteLabels labels HTTP metrics with the route template and
+	// cache result; disable to drop those labels entirely
+	MetricsRouteLabels bool
+
+	// ArchiveMaxFiles caps the files in one POST /files/tar request;
+	// 0 disables the endpoint
+	ArchiveMaxFiles int
+
+	// ArchiveETags sends archives with an ETag derived from their members'
+	// keys and ETags, so unchanged archives can be revalidated
+	ArchiveETags bool
+
+	// ManifestMaxObjects caps the objects one GET /manifest/{prefix}/checksum
+	// request may hash; 0 disables the endpoint
+	ManifestMaxObjects int
+
+	// BodyLimits overrides the request body size limit of a route
+	// template, in bytes
+	BodyLimits map[string]int64
+
+	// BodyReadTimeout caps how long reading a request body may take, and
+	// BodyMinReadRate is the slowest average rate in bytes per second it
+	// may arrive at; slower bodies get 408. 0 disables either.
+	BodyReadTimeout time.Duration
+	BodyMinReadRate int
+
+	// MetricsBackend is "prometheus", "statsd" or "none"
+	MetricsBackend string
+	StatsDAddr     string
+	StatsDPrefix   string
+}
+
+// AdaptiveConcurrencyConfig controls the adaptive limit on concurrent fetches
+type AdaptiveConcurrencyConfig struct {
+	MinLimit      int // the limit never drops below this
+	MaxLimit      int // the starting and largest limit; 0 disables
+	TargetLatency time.Duration
+}
+
+// MaintenanceConfig controls maintenance mode
+type MaintenanceConfig struct {
+	Enabled     bool   // start in maintenance mode
+	Message     string // sent in the 503 body
+	RetryAfter  time.Duration
+	ServeCached bool // keep serving cache hits during maintenance
+}
+
+// MissStormConfig controls admission control during cache miss storms
+type MissStormConfig struct {
+	Threshold    int     // misses per second that count as a storm; 0 disables
+	ShedFraction float64 // share of storm misses rejected with 503
+	MaxDelay     time.Duration
 }
 
 type RedisConfig struct {
@@ -29,10 +296,58 @@ type RedisConfig struct {
 	DB       int
 	CacheTTL time.Duration
 
+	// CacheTTLRules overrides CacheTTL for keys under a prefix
+	CacheTTLRules map[string]time.Duration
+
+	// MaxCacheLifetime makes cache hits reset their entry's TTL, keeping
+	// entries no longer than this after they were cached; 0 leaves TTLs
+	// fixed
+	MaxCacheLifetime time.Duration
+
+	// OOMCooldown pauses cache writes after Redis reports it is out of
+	// memory; 0 keeps writing
+	OOMCooldown time.Duration
+
+	// WriteOnMiss caches objects fetched on a miss; disable it when the
+	// cache is only populated through the warm endpoint
+	WriteOnMiss bool
+
+	// ReadRepair deletes cache entries that fail their checksum before
+	// refetching them, so the good copy replaces them
+	ReadRepair bool
+
+	// VersionedWrites stores cached objects with their revision so a late
+	// write from an older fetch can't replace a newer body
+	VersionedWrites bool
+
+	// StaleOnAuthErrorTTL is how long fallback copies served on storage
+	// auth errors are kept; 0 disables them
+	StaleOnAuthErrorTTL time.Duration
+
+	// StaleMaxAge is the oldest a fallback copy may be to be served;
+	// 0 serves copies for as long as they are kept
+	StaleMaxAge time.Duration
+
+	// KeySecret HMACs cache keys so filenames aren't readable in Redis;
+	// empty stores keys as they are
+	KeySecret string
+
+	// DBPartitions routes keys to other Redis databases than DB by
+	// extension (".mp4"), media type ("video/mp4") or family ("video/*")
+	DBPartitions map[string]int
+
+	// CacheVersion is mixed into every cache key; bump it to invalidate
+	// all entries without flushing Redis
+	CacheVersion string
+
 	// Timeout settings (optimized for in-cluster Redis)
 	DialTimeout  time.Duration
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+
+	// Idle connection reaping
+	IdleTimeout  time.Duration
+	MaxIdleConns int
 }
 
 type R2Config struct {
@@ -40,30 +355,133 @@ type R2Config struct {
 	AccessKeyID     string
 	SecretAccessKey string
 	BucketName      string
+
+	// ReadEndpoint, if set, serves object reads instead of the primary
+	// endpoint, which they fall back to when it fails
+	ReadEndpoint string
 }
 
 func Load() *Config {
 	redisMode := parseRedisMode(getEnv("REDIS_MODE", "enabled"))
+	tagKey, tagValue, _ := strings.Cut(getEnv("REQUIRED_TAG", ""), ":")
 
 	return &Config{
 		Port:     getEnv("PORT", "8080"),
 		LogLevel: getEnv("LOG_LEVEL", "info"),
 		Redis: RedisConfig{
-			Mode:         redisMode,
-			Addr:         getEnv("REDIS_ADDR", "localhost:6379"),
-			Password:     getEnv("REDIS_PASSWORD", ""),
-			DB:           getEnvAsInt("REDIS_DB", 0),
-			CacheTTL:     getEnvAsDuration("CACHE_TTL", 5*time.Minute),
-			DialTimeout:  getEnvAsDuration("REDIS_DIAL_TIMEOUT", 2*time.Second),
-			ReadTimeout:  getEnvAsDuration("REDIS_READ_TIMEOUT", 5*time.Second),
-			WriteTimeout: getEnvAsDuration("REDIS_WRITE_TIMEOUT", 5*time.Second),
+			Mode:                redisMode,
+			Addr:                getEnv("REDIS_ADDR", "localhost:6379"),
+			Password:            getEnv("REDIS_PASSWORD", ""),
+			DB:                  getEnvAsInt("REDIS_DB", 0),
+			DBPartitions:        parsePartitionRules(getEnv("REDIS_DB_PARTITIONS", "")),
+			KeySecret:           getEnv("REDIS_KEY_SECRET", ""),
+			CacheTTL:            getEnvAsDuration("CACHE_TTL", 5*time.Minute),
+			CacheTTLRules:       parseTTLRules(getEnv("CACHE_TTL_RULES", "")),
+			MaxCacheLifetime:    getEnvAsDuration("CACHE_SLIDING_MAX_LIFETIME", 0),
+			CacheVersion:        getEnv("CACHE_VERSION", ""),
+			StaleOnAuthErrorTTL: getEnvAsDuration("STALE_IF_AUTH_ERROR_TTL", 0),
+			StaleMaxAge:         getEnvAsDuration("STALE_IF_ERROR_MAX_AGE", 0),
+			OOMCooldown:         getEnvAsDuration("REDIS_OOM_COOLDOWN", 0),
+			WriteOnMiss:         getEnvAsBool("CACHE_WRITE_ON_MISS", true),
+			ReadRepair:          getEnvAsBool("CACHE_READ_REPAIR", true),
+			VersionedWrites:     getEnvAsBool("CACHE_VERSIONED_WRITES", false),
+			DialTimeout:         getEnvAsDuration("REDIS_DIAL_TIMEOUT", 2*time.Second),
+			ReadTimeout:         getEnvAsDuration("REDIS_READ_TIMEOUT", 5*time.Second),
+			WriteTimeout:        getEnvAsDuration("REDIS_WRITE_TIMEOUT", 5*time.Second),
+			IdleTimeout:         getEnvAsDuration("REDIS_IDLE_TIMEOUT", 5*time.Minute),
+			MaxIdleConns:        getEnvAsInt("REDIS_MAX_IDLE_CONNS", 0),
 		},
 		R2: R2Config{
 			AccountID:       getEnv("R2_ACCOUNT_ID", ""),
 			AccessKeyID:     getEnv("R2_ACCESS_KEY_ID", ""),
 			SecretAccessKey: getEnv("R2_SECRET_ACCESS_KEY", ""),
 			BucketName:      getEnv("R2_BUCKET_NAME", ""),
+			ReadEndpoint:    getEnv("R2_READ_ENDPOINT", ""),
+		},
+		NotFoundKey:                getEnv("NOT_FOUND_KEY", ""),
+		IndexFile:                  getEnv("INDEX_FILE", ""),
+		Aliases:                    parseAliases(getEnv("ALIASES", "")),
+		RequiredTagKey:             tagKey,
+		RequiredTagValue:           tagValue,
+		KeyAllowPattern:            getEnv("KEY_ALLOW_PATTERN", ""),
+		KeyDenyPattern:             getEnv("KEY_DENY_PATTERN", ""),
+		RequestTimeout:             getEnvAsDuration("REQUEST_TIMEOUT", 30*time.Second),
+		SlowStorageClasses:         getEnvAsList("SLOW_STORAGE_CLASSES"),
+		DispositionDefaults:        parseDispositionRules(getEnv("DISPOSITION_DEFAULTS", "")),
+		TransformRules:             parseTransformRules(getEnv("TRANSFORM_RULES", "")),
+		Base64MaxSize:              getEnvAsInt("BASE64_MAX_SIZE", 1<<20),
+		StoredContentTypes:         getEnvAsBool("STORED_CONTENT_TYPES", true),
+		SniffContentTypeExtensions: getEnvAsList("SNIFF_CONTENT_TYPE_EXTENSIONS"),
+		GeneratedETags:             getEnvAsBool("GENERATED_ETAGS", true),
+		UnsafeContentTypes:         getEnvAsList("UNSAFE_CONTENT_TYPES"),
+		UnsafeContentTypeAction:    parseUnsafeTypeAction(getEnv("UNSAFE_CONTENT_TYPE_ACTION", "attachment")),
+		HealthCheckTimeout:         getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		DrainGracePeriod:           getEnvAsDuration("DRAIN_GRACE_PERIOD", 30*time.Second),
+		MinRequestTimeout:          getEnvAsDuration("MIN_REQUEST_TIMEOUT", 100*time.Millisecond),
+		KeyDecoding:                parseKeyDecoding(getEnv("KEY_DECODING", "path")),
+		KeyNormalization:           parseKeyNormalization(getEnv("KEY_NORMALIZATION", "none")),
+		RootMode:                   parseRootMode(getEnv("ROOT_MODE", "info")),
+		RootRedirectURL:            getEnv("ROOT_REDIRECT_URL", ""),
+		MaxRanges:                  getEnvAsInt("MAX_RANGES", 10),
+		RangeRequests:              getEnvAsBool("RANGE_REQUESTS", true),
+		RangeCoalesceMaxSize:       getEnvAsInt("RANGE_COALESCE_MAX_SIZE", 0),
+		MissStorm: MissStormConfig{
+			Threshold:    getEnvAsInt("MISS_STORM_THRESHOLD", 0),
+			ShedFraction: getEnvAsFloat("MISS_STORM_SHED_FRACTION", 0.1),
+			MaxDelay:     getEnvAsDuration("MISS_STORM_MAX_DELAY", 50*time.Millisecond),
+		},
+		GzipDecompress:           getEnvAsBool("GZIP_DECOMPRESS", false),
+		GzipRangeMaxSize:         getEnvAsInt("GZIP_RANGE_MAX_SIZE", 8<<20),
+		GzipMaxInflatedSize:      getEnvAsInt("GZIP_MAX_INFLATED_SIZE", 1<<30),
+		MaxBufferedObjectSize:    getEnvAsInt("MAX_BUFFERED_OBJECT_SIZE", 0),
+		ResponseBufferSize:       getEnvAsInt("RESPONSE_BUFFER_SIZE", 32<<10),
+		ContentLengthCheck:       getEnvAsBool("CONTENT_LENGTH_CHECK", true),
+		DownloadProgressInterval: getEnvAsDuration("DOWNLOAD_PROGRESS_INTERVAL", 0),
+		RedirectMinSize:          getEnvAsInt("REDIRECT_MIN_SIZE", 0),
+		RedirectURLExpiry:        getEnvAsDuration("REDIRECT_URL_EXPIRY", 5*time.Minute),
+		MemoryShedThreshold:      getEnvAsInt("MEMORY_SHED_THRESHOLD", 0),
+		MemoryShedMinObjectSize:  getEnvAsInt("MEMORY_SHED_MIN_OBJECT_SIZE", 10<<20),
+		AdaptiveConcurrency: AdaptiveConcurrencyConfig{
+			MinLimit:      getEnvAsInt("ADAPTIVE_CONCURRENCY_MIN", 10),
+			MaxLimit:      getEnvAsInt("ADAPTIVE_CONCURRENCY_MAX", 0),
+			TargetLatency: getEnvAsDuration("ADAPTIVE_CONCURRENCY_TARGET_LATENCY", time.Second),
 		},
+		Maintenance: MaintenanceConfig{
+			Enabled:     getEnvAsBool("MAINTENANCE_MODE", false),
+			Message:     getEnv("MAINTENANCE_MESSAGE", ""),
+			RetryAfter:  getEnvAsDuration("MAINTENANCE_RETRY_AFTER", 5*time.Minute),
+			ServeCached: getEnvAsBool("MAINTENANCE_SERVE_CACHED", false),
+		},
+		UploadContentTypes:     getEnvAsList("UPLOAD_CONTENT_TYPES"),
+		UploadURLExpiry:        getEnvAsDuration("UPLOAD_URL_EXPIRY", 15*time.Minute),
+		UploadToken:            getEnv("UPLOAD_TOKEN", ""),
+		UploadMaxSize:          getEnvAsInt("UPLOAD_MAX_SIZE", 100<<20),
+		ReadAfterWriteWindow:   getEnvAsDuration("READ_AFTER_WRITE_WINDOW", 0),
+		ReadAfterWriteRetries:  getEnvAsInt("READ_AFTER_WRITE_RETRIES", 2),
+		ReadAfterWriteDelay:    getEnvAsDuration("READ_AFTER_WRITE_DELAY", 200*time.Millisecond),
+		AdminToken:             getEnv("ADMIN_TOKEN", ""),
+		OriginPullSecret:       getEnv("ORIGIN_PULL_SECRET", ""),
+		URLSigningKey:          getEnv("URL_SIGNING_KEY", ""),
+		CacheTTLHeaderMax:      getEnvAsDuration("CACHE_TTL_HEADER_MAX", 0),
+		WarmConcurrency:        getEnvAsInt("WARM_CONCURRENCY", 4),
+		PrefetchRules:          parsePrefetchRules(getEnv("PREFETCH_RULES", "")),
+		PrefetchConcurrency:    getEnvAsInt("PREFETCH_CONCURRENCY", 2),
+		FallbackPrefixes:       parseFallbackPrefixes(getEnv("FALLBACK_PREFIXES", "")),
+		AccessTrackingInterval: getEnvAsDuration("ACCESS_TRACKING_INTERVAL", 0),
+		AccessTrackingMaxKeys:  getEnvAsInt("ACCESS_TRACKING_MAX_KEYS", 100000),
+		AccessLogPrefix:        getEnv("ACCESS_LOG_PREFIX", ""),
+		AccessLogFlushInterval: getEnvAsDuration("ACCESS_LOG_FLUSH_INTERVAL", time.Minute),
+		AccessLogFlushRecords:  getEnvAsInt("ACCESS_LOG_FLUSH_RECORDS", 1000),
+		MetricsRouteLabels:     getEnvAsBool("METRICS_ROUTE_LABELS", true),
+		ArchiveMaxFiles:        getEnvAsInt("ARCHIVE_MAX_FILES", 0),
+		ArchiveETags:           getEnvAsBool("ARCHIVE_ETAGS", true),
+		ManifestMaxObjects:     getEnvAsInt("MANIFEST_MAX_OBJECTS", 0),
+		BodyLimits:             parseSizeRules(getEnv("REQUEST_BODY_LIMITS", "")),
+		BodyReadTimeout:        getEnvAsDuration("REQUEST_BODY_READ_TIMEOUT", 30*time.Second),
+		BodyMinReadRate:        getEnvAsInt("REQUEST_BODY_MIN_READ_RATE", 0),
+		MetricsBackend:         getEnv("METRICS_BACKEND", "prometheus"),
+		StatsDAddr:             getEnv("STATSD_ADDR", "127.0.0.1:8125"),
+		StatsDPrefix:           getEnv("STATSD_PREFIX", ""),
 	}
 }
 
@@ -76,6 +494,186 @@ func parseRedisMode(mode string) RedisMode {
 	}
 }
 
+func parseKeyDecoding(mode string) string {
+	switch strings.ToLower(mode) {
+	case "plus", "double":
+		return strings.ToLower(mode)
+	default:
+		return "path"
+	}
+}
+
+func parseKeyNormalization(form string) string {
+	switch strings.ToLower(form) {
+	case "nfc", "nfd":
+		return strings.ToLower(form)
+	default:
+		return "none"
+	}
+}
+
+func parseRootMode(mode string) string {
+	switch strings.ToLower(mode) {
+	case "health", "redirect":
+		return strings.ToLower(mode)
+	default:
+		return "info"
+	}
+}
+
+func parseUnsafeTypeAction(action string) string {
+	switch strings.ToLower(action) {
+	case "plain", "reject":
+		return strings.ToLower(action)
+	default:
+		return "attachment"
+	}
+}
+
+// parseDispositionRules parses "extension=disposition" pairs separated by
+// commas, e.g. ".pdf=inline,.zip=attachment". Malformed pairs and unknown
+// dispositions are skipped.
+func parseDispositionRules(value string) map[string]string {
+	rules := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		ext, disposition, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		disposition = strings.ToLower(strings.TrimSpace(disposition))
+		if disposition != "inline" && disposition != "attachment" {
+			continue
+		}
+		rules[strings.TrimSpace(ext)] = disposition
+	}
+	return rules
+}
+
+// parseTransformRules parses "type=transformer" pairs separated by commas,
+// e.g. "text/html=banner,image/*=strip-exif". Malformed pairs are skipped;
+// unknown transformer names are rejected when the app is built.
+func parseTransformRules(value string) map[string]string {
+	rules := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		contentType, name, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		contentType, name = strings.ToLower(strings.TrimSpace(contentType)), strings.TrimSpace(name)
+		if !ok || contentType == "" || name == "" {
+			continue
+		}
+		rules[contentType] = name
+	}
+	return rules
+}
+
+// parseAliases parses "alias=key" pairs separated by commas, e.g.
+// "logo=brand/assets/logo.png". Malformed pairs are skipped.
+func parseAliases(value string) map[string]string {
+	aliases := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		name, key, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+		if !ok || name == "" || key == "" {
+			continue
+		}
+		aliases[name] = key
+	}
+	return aliases
+}
+
+// parsePartitionRules parses "type=db" pairs separated by commas, e.g.
+// "video/*=1,.iso=2". Types are lowercased; malformed pairs are skipped.
+func parsePartitionRules(value string) map[string]int {
+	rules := make(map[string]int)
+	for _, pair := range strings.Split(value, ",") {
+		match, db, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(db))
+		if err != nil || n < 0 {
+			continue
+		}
+		rules[strings.ToLower(strings.TrimSpace(match))] = n
+	}
+	return rules
+}
+
+// parsePrefetchRules parses "key=related|related" pairs separated by
+// commas, e.g. "intro.mp4=intro.mp4.vtt|intro.jpg". Malformed pairs are
+// skipped.
+func parsePrefetchRules(value string) map[string][]string {
+	rules := make(map[string][]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, list, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		for _, related := range strings.Split(list, "|") {
+			if related = strings.TrimSpace(related); related != "" && related != key {
+				rules[key] = append(rules[key], related)
+			}
+		}
+	}
+	return rules
+}
+
+// parseFallbackPrefixes parses "prefix=fallback|fallback" pairs separated
+// by commas, e.g. "assets/v2/=assets/v1/|legacy/". The prefix may be empty
+// to match every key. Malformed pairs are skipped.
+func parseFallbackPrefixes(value string) map[string][]string {
+	rules := make(map[string][]string)
+	for _, pair := range strings.Split(value, ",") {
+		prefix, list, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		prefix = strings.TrimSpace(prefix)
+		for _, fallback := range strings.Split(list, "|") {
+			if fallback = strings.TrimSpace(fallback); fallback != "" && fallback != prefix {
+				rules[prefix] = append(rules[prefix], fallback)
+			}
+		}
+	}
+	return rules
+}
+
+// parseTTLRules parses "prefix=duration" pairs separated by commas, e.g.
+// "thumbs/=24h,live/=10s". Malformed pairs are skipped.
+func parseTTLRules(value string) map[string]time.Duration {
+	rules := make(map[string]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		prefix, ttl, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(ttl))
+		if err != nil || duration <= 0 {
+			continue
+		}
+		rules[strings.TrimSpace(prefix)] = duration
+	}
+	return rules
+}
+
+// parseSizeRules parses "route=bytes" pairs separated by commas, e.g.
+// "/admin/cache/warm=4194304". Malformed pairs are skipped.
+func parseSizeRules(value string) map[string]int64 {
+	rules := make(map[string]int64)
+	for _, pair := range strings.Split(value, ",") {
+		route, size, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		if err != nil || n <= 0 {
+			continue
+		}
+		rules[strings.TrimSpace(route)] = n
+	}
+	return rules
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -92,6 +690,35 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsList splits a comma-separated variable, dropping empty entries
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -0,0 +1,77 @@
+// Package contextkeys holds the values middleware stores on a request's
+// context for later handlers and log lines, behind typed getters and
+// setters so no two packages have to agree on a key.
+package contextkeys
+
+import "context"
+
+type (
+	requestIDKey struct{}
+	clientIDKey  struct{}
+	traceKey     struct{}
+)
+
+// Trace identifies the distributed trace a request belongs to, as carried
+// by a W3C traceparent header
+type Trace struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+
+	// ParentSpanID is set for spans started by the service on a request's
+	// behalf, e.g. background cache writes, and is the request's span
+	ParentSpanID string
+}
+
+// WithRequestID returns ctx carrying the request's ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored on ctx, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithClientID returns ctx carrying the ID of the authenticated client
+func WithClientID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, id)
+}
+
+// ClientID returns the authenticated client ID stored on ctx, or "" for
+// an unauthenticated request
+func ClientID(ctx context.Context) string {
+	id, _ := ctx.Value(clientIDKey{}).(string)
+	return id
+}
+
+// WithTrace returns ctx carrying the request's trace
+func WithTrace(ctx context.Context, t Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// TraceFrom returns the trace stored on ctx, if any
+func TraceFrom(ctx context.Context) (Trace, bool) {
+	t, ok := ctx.Value(traceKey{}).(Trace)
+	return t, ok
+}
+
+// LogAttrs returns the values stored on ctx as slog key-value pairs, with
+// unset values left out
+func LogAttrs(ctx context.Context) []any {
+	var attrs []any
+	if id := RequestID(ctx); id != "" {
+		attrs = append(attrs, "request_id", id)
+	}
+	if id := ClientID(ctx); id != "" {
+		attrs = append(attrs, "client_id", id)
+	}
+	if t, ok := TraceFrom(ctx); ok {
+		attrs = append(attrs, "trace_id", t.TraceID, "span_id", t.SpanID)
+		if t.ParentSpanID != "" {
+			attrs = append(attrs, "parent_span_id", t.ParentSpanID)
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,51 @@
+package contextkeys_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/contextkeys"
+)
+
+func TestContextKeys(t *testing.T) {
+	ctx := context.Background()
+	if contextkeys.RequestID(ctx) != "" || contextkeys.ClientID(ctx) != "" {
+		t.Error("Expected no values on an empty context")
+	}
+	if _, ok := contextkeys.TraceFrom(ctx); ok {
+		t.Error("Expected no trace on an empty context")
+	}
+	if attrs := contextkeys.LogAttrs(ctx); len(attrs) != 0 {
+		t.Errorf("Expected no log attributes, got %v", attrs)
+	}
+
+	ctx = contextkeys.WithRequestID(ctx, "req-1")
+	ctx = contextkeys.WithClientID(ctx, "client-1")
+	ctx = contextkeys.WithTrace(ctx, contextkeys.Trace{TraceID: "t1", SpanID: "s1", Sampled: true})
+
+	if got := contextkeys.RequestID(ctx); got != "req-1" {
+		t.Errorf("Expected request ID 'req-1', got %q", got)
+	}
+	if got := contextkeys.ClientID(ctx); got != "client-1" {
+		t.Errorf("Expected client ID 'client-1', got %q", got)
+	}
+	if got, ok := contextkeys.TraceFrom(ctx); !ok || got.TraceID != "t1" || !got.Sampled {
+		t.Errorf("Expected the stored trace, got %+v", got)
+	}
+
+	want := []any{"request_id", "req-1", "client_id", "client-1", "trace_id", "t1", "span_id", "s1"}
+	if got := contextkeys.LogAttrs(ctx); !slices.Equal(got, want) {
+		t.Errorf("Expected log attributes %v, got %v", want, got)
+	}
+}
+
+func TestLogAttrs_ParentSpan(t *testing.T) {
+	ctx := contextkeys.WithTrace(context.Background(),
+		contextkeys.Trace{TraceID: "t1", SpanID: "s2", ParentSpanID: "s1"})
+
+	want := []any{"trace_id", "t1", "span_id", "s2", "parent_span_id", "s1"}
+	if got := contextkeys.LogAttrs(ctx); !slices.Equal(got, want) {
+		t.Errorf("Expected log attributes %v, got %v", want, got)
+	}
+}
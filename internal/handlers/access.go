@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/cache"
+)
+
+const (
+	// maxPendingAccesses flushes recorded accesses early once this many
+	// keys are waiting, bounding the memory they use
+	maxPendingAccesses = 10000
+
+	// defaultAccessStatsLimit and maxAccessStatsLimit bound the keys
+	// returned by one access stats request
+	defaultAccessStatsLimit = 100
+	maxAccessStatsLimit     = 1000
+)
+
+// WithAccessTracking records when each served object was last read, to
+// help find cold objects for archival. Reads are collected in memory and
+// written to the cache's access log in one batch at most every interval,
+// so serving never waits on it. Tracking needs a cache that keeps an
+// access log, such as Redis; otherwise, or with an interval of 0, it is
+// off.
+func WithAccessTracking(interval time.Duration) Option {
+	return func(h *FileHandler) {
+		log, ok := h.cache.(cache.AccessLog)
+		if !ok || interval <= 0 {
+			return
+		}
+		h.accesses = &accessTracker{
+			log:      log,
+			interval: interval,
+			pending:  make(map[string]time.Time),
+		}
+	}
+}
+
+// accessTracker batches last-access times for the access log
+type accessTracker struct {
+	log      cache.AccessLog
+	interval time.Duration
+
+	mu        sync.Mutex
+	pending   map[string]time.Time
+	lastFlush time.Time
+	flushing  bool
+}
+
+// recordAccess notes that key was read at now, and starts writing the
+// batch collected so far once it is due. At most one write runs at a time.
+func (h *FileHandler) recordAccess(key string, now time.Time) {
+	t := h.accesses
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[key] = now
+	due := now.Sub(t.lastFlush) >= t.interval || len(t.pending) >= maxPendingAccesses
+	if !due || t.flushing {
+		return
+	}
+
+	batch := t.pending
+	t.pending = make(map[string]time.Time)
+	t.lastFlush = now
+	t.flushing = true
+
+	go func() {
+		bgCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		// Access times are only analytics, so a failed batch is dropped
+		if err := t.log.RecordAccess(bgCtx, batch); err != nil {
+			slog.Error("Failed to record object accesses", "keys", len(batch), "error", err)
+		}
+
+		t.mu.Lock()
+		t.flushing = false
+		t.mu.Unlock()
+	}()
+}
+
+// accessStat is one key in an access stats response
+type accessStat struct {
+	Key        string `json:"key"`
+	LastAccess string `json:"last_access"`
+}
+
+// AccessStats handles requests for the least recently read objects under
+// ?prefix=, coldest first, up to ?limit= keys. Reads from the last flush
+// interval may not be included yet. Without a prefix this is one Redis
+// read; with one, the log is scanned from the coldest end in pages of 1000
+// until enough keys match, so a rare prefix can scan the whole set, up to
+// ACCESS_TRACKING_MAX_KEYS members (100,000 by default) in 100 round trips.
+func (h *FileHandler) AccessStats(w http.ResponseWriter, r *http.Request) {
+	if h.accesses == nil {
+		writeJSON(w, http.StatusServiceUnavailable, Response{
+			Success: false,
+			Message: "access tracking is disabled",
+		})
+		return
+	}
+
+	limit := defaultAccessStatsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxAccessStatsLimit {
+			writeJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Message: fmt.Sprintf("limit must be between 1 and %d", maxAccessStatsLimit),
+			})
+			return
+		}
+		limit = n
+	}
+
+	accesses, err := h.accesses.log.LastAccesses(r.Context(), r.URL.Query().Get("prefix"), limit)
+	if err != nil {
+		slog.Error("Failed to read access log", "error", err)
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Message: "Failed to read access log",
+		})
+		return
+	}
+
+	stats := make([]accessStat, len(accesses))
+	for i, access := range accesses {
+		stats[i] = accessStat{Key: access.Key, LastAccess: access.At.UTC().Format(time.RFC3339)}
+	}
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: fmt.Sprintf("%d objects", len(stats)),
+		Data:    map[string]any{"objects": stats},
+	})
+}
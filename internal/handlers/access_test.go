@@ -0,0 +1,104 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+type accessStatsResponse struct {
+	Data struct {
+		Objects []struct {
+			Key        string `json:"key"`
+			LastAccess string `json:"last_access"`
+		} `json:"objects"`
+	} `json:"data"`
+}
+
+func getAccessStats(t *testing.T, handler *handlers.FileHandler, query string) (int, accessStatsResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.AccessStats(rec, httptest.NewRequest(http.MethodGet, "/admin/access-stats"+query, nil))
+
+	var resp accessStatsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return rec.Code, resp
+}
+
+func TestAccessTracking_BatchesAndListsColdestFirst(t *testing.T) {
+	clk := mocks.NewMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	for _, key := range []string{"videos/a.mp4", "videos/b.mp4", "images/c.jpg"} {
+		mockStorage.SetObject(key, []byte(key))
+	}
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithClock(clk),
+		handlers.WithAccessTracking(time.Minute),
+	)
+
+	// The first read flushes right away; later ones wait for the interval
+	getFile(handler, "videos/b.mp4")
+	waitFor(t, func() bool {
+		_, resp := getAccessStats(t, handler, "")
+		return len(resp.Data.Objects) == 1
+	})
+
+	clk.Advance(10 * time.Second)
+	getFile(handler, "videos/a.mp4")
+	getFile(handler, "images/c.jpg")
+	time.Sleep(20 * time.Millisecond)
+	if _, resp := getAccessStats(t, handler, ""); len(resp.Data.Objects) != 1 {
+		t.Fatalf("Expected reads within the interval to be held back, got %v", resp.Data.Objects)
+	}
+
+	clk.Advance(time.Minute)
+	getFile(handler, "videos/b.mp4")
+	waitFor(t, func() bool {
+		_, resp := getAccessStats(t, handler, "")
+		return len(resp.Data.Objects) == 3
+	})
+
+	code, resp := getAccessStats(t, handler, "?prefix=videos/")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	want := []struct{ key, at string }{
+		{"videos/a.mp4", "2024-01-01T00:00:10Z"},
+		{"videos/b.mp4", "2024-01-01T00:01:10Z"},
+	}
+	if len(resp.Data.Objects) != len(want) {
+		t.Fatalf("Expected %d objects, got %v", len(want), resp.Data.Objects)
+	}
+	for i, w := range want {
+		if got := resp.Data.Objects[i]; got.Key != w.key || got.LastAccess != w.at {
+			t.Errorf("Expected object %d to be %s at %s, got %s at %s", i, w.key, w.at, got.Key, got.LastAccess)
+		}
+	}
+}
+
+func TestAccessStats_Limit(t *testing.T) {
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mocks.NewMockStorage(),
+		handlers.WithAccessTracking(time.Minute))
+
+	for _, query := range []string{"?limit=0", "?limit=1001", "?limit=x"} {
+		if code, _ := getAccessStats(t, handler, query); code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, query, code)
+		}
+	}
+}
+
+func TestAccessStats_Disabled(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, mocks.NewMockStorage(), handlers.WithAccessTracking(time.Minute))
+
+	if code, _ := getAccessStats(t, handler, ""); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d without a cache, got %d", http.StatusServiceUnavailable, code)
+	}
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/clock"
+	"github.com/ch374n/file-downloader/internal/contextkeys"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// accessLogBacklogFactor bounds the records kept across failed flushes, as
+// a multiple of the flush size, so an unreachable bucket can't grow the
+// buffer without limit
+const accessLogBacklogFactor = 10
+
+// AccessLogRecord is one request in the access log
+type AccessLogRecord struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMS float64   `json:"duration_ms"`
+}
+
+// AccessLogWriter buffers a record of every request and writes them to
+// storage as newline-delimited JSON objects under a prefix, whenever
+// maxRecords have been buffered or interval has passed, whichever comes
+// first. Close writes what's left.
+type AccessLogWriter struct {
+	storage    storage.Storage
+	prefix     string
+	interval   time.Duration
+	maxRecords int
+	clock      clock.Clock
+
+	// instance keeps object keys from different replicas apart
+	instance string
+
+	mu      sync.Mutex
+	records []AccessLogRecord
+	seq     int
+	full    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewAccessLogWriter starts a writer flushing to objects under prefix in
+// s. Object keys are prefix, the UTC time of the flush, an instance ID and
+// a sequence number, e.g. "logs/access/2024/01/02/15-04-05-1a2b3c4d-000001.ndjson".
+func NewAccessLogWriter(s storage.Storage, prefix string, interval time.Duration, maxRecords int, c clock.Clock) *AccessLogWriter {
+	var id [4]byte
+	rand.Read(id[:])
+	l := &AccessLogWriter{
+		storage:    s,
+		prefix:     prefix,
+		interval:   interval,
+		maxRecords: max(maxRecords, 1),
+		clock:      c,
+		instance:   hex.EncodeToString(id[:]),
+		full:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Middleware wraps next so every request it serves is recorded. The
+// signature of a signed link is left out of the recorded path, so the log
+// can't be used to replay it.
+func (l *AccessLogWriter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := l.clock.Now()
+		counted := &countingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(counted, r)
+
+		l.Record(AccessLogRecord{
+			Time:       start.UTC(),
+			RequestID:  contextkeys.RequestID(r.Context()),
+			Method:     r.Method,
+			Path:       redactSignature(r.URL.Path),
+			Status:     counted.statusCode,
+			Bytes:      counted.bytes,
+			DurationMS: float64(l.clock.Now().Sub(start)) / float64(time.Millisecond),
+		})
+	})
+}
+
+// redactSignature replaces the signature segment of a /s/{sig}/... path
+func redactSignature(path string) string {
+	rest, ok := strings.CutPrefix(path, "/s/")
+	if !ok {
+		return path
+	}
+	if _, after, found := strings.Cut(rest, "/"); found {
+		return "/s/REDACTED/" + after
+	}
+	return "/s/REDACTED"
+}
+
+// Record buffers rec, waking the writer once a flush worth is buffered
+func (l *AccessLogWriter) Record(rec AccessLogRecord) {
+	l.mu.Lock()
+	l.records = append(l.records, rec)
+	full := len(l.records) >= l.maxRecords
+	l.mu.Unlock()
+
+	if full {
+		select {
+		case l.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run flushes on every interval and whenever the buffer fills, until Close
+func (l *AccessLogWriter) run() {
+	defer close(l.done)
+	for {
+		select {
+		case <-l.clock.After(l.interval):
+		case <-l.full:
+		case <-l.stop:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		l.flush(ctx)
+		cancel()
+	}
+}
+
+// Close stops the writer and writes the records still buffered, waiting
+// at most until ctx is done
+func (l *AccessLogWriter) Close(ctx context.Context) error {
+	close(l.stop)
+	<-l.done
+	return l.flush(ctx)
+}
+
+// flush writes the buffered records as one object. Records that fail to
+// be written are kept for the next flush, up to the backlog limit.
+func (l *AccessLogWriter) flush(ctx context.Context) error {
+	l.mu.Lock()
+	batch := l.records
+	l.records = nil
+	l.seq++
+	seq := l.seq
+	l.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, rec := range batch {
+		enc.Encode(rec)
+	}
+
+	key := fmt.Sprintf("%s%s-%s-%06d.ndjson",
+		l.prefix, l.clock.Now().UTC().Format("2006/01/02/15-04-05"), l.instance, seq)
+	err := l.storage.PutObject(ctx, key, &body, "application/x-ndjson")
+	if err == nil {
+		slog.Debug("Wrote access log", "key", key, "records", len(batch))
+		return nil
+	}
+
+	l.mu.Lock()
+	l.records = append(batch, l.records...)
+	if dropped := len(l.records) - l.maxRecords*accessLogBacklogFactor; dropped > 0 {
+		l.records = l.records[dropped:]
+		slog.Warn("Dropped access log records", "records", dropped)
+	}
+	l.mu.Unlock()
+	slog.Error("Failed to write access log", "key", key, "records", len(batch), "error", err,
+		"upstream_request_id", storage.RequestID(err))
+	return err
+}
+
+// countingResponseWriter records the status and body size of a response.
+// Unwrap lets http.ResponseController reach the underlying writer, for
+// streams that flush.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	bytes       int64
+}
+
+func (w *countingResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.statusCode, w.wroteHeader = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
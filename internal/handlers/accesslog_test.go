@@ -0,0 +1,147 @@
+package handlers_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+// accessLogRecords decodes every record in puts under prefix
+func accessLogRecords(t *testing.T, puts []mocks.PutCall, prefix string) []handlers.AccessLogRecord {
+	t.Helper()
+	var records []handlers.AccessLogRecord
+	for _, call := range puts {
+		if !strings.HasPrefix(call.Key, prefix) {
+			continue
+		}
+		if call.ContentType != "application/x-ndjson" {
+			t.Errorf("Expected Content-Type application/x-ndjson, got %q", call.ContentType)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(call.Data))
+		for scanner.Scan() {
+			var rec handlers.AccessLogRecord
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				t.Fatalf("Failed to decode record %q: %v", scanner.Text(), err)
+			}
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+func serveLogged(l *handlers.AccessLogWriter, target string) {
+	handler := handlers.RequestContext(l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	})))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+}
+
+func TestAccessLog_FlushesOnInterval(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	clk := mocks.NewMockClock(time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC))
+	l := handlers.NewAccessLogWriter(mockStorage, "logs/", time.Minute, 100, clk)
+	defer l.Close(context.Background())
+
+	serveLogged(l, "/files/a.txt")
+	serveLogged(l, "/missing")
+	if len(mockStorage.Puts()) != 0 {
+		t.Fatalf("Expected nothing written before the interval, got %d objects", len(mockStorage.Puts()))
+	}
+
+	waitFor(t, func() bool { return clk.WaiterCount() == 1 })
+	clk.Advance(time.Minute)
+	waitFor(t, func() bool { return len(mockStorage.Puts()) == 1 })
+
+	key := mockStorage.Puts()[0].Key
+	if !strings.HasPrefix(key, "logs/2024/01/02/15-05-05-") || !strings.HasSuffix(key, "-000001.ndjson") {
+		t.Errorf("Unexpected object key %q", key)
+	}
+	records := accessLogRecords(t, mockStorage.Puts(), "logs/")
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if got := records[0]; got.Path != "/files/a.txt" || got.Status != http.StatusOK || got.Bytes != 5 || got.RequestID == "" {
+		t.Errorf("Unexpected first record %+v", got)
+	}
+	if got := records[1]; got.Path != "/missing" || got.Status != http.StatusNotFound {
+		t.Errorf("Unexpected second record %+v", got)
+	}
+}
+
+func TestAccessLog_RedactsSignatures(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	l := handlers.NewAccessLogWriter(mockStorage, "logs/", time.Hour, 100, mocks.NewMockClock(time.Now()))
+
+	serveLogged(l, "/s/c2lnbmF0dXJl/files/report.pdf")
+	serveLogged(l, "/s/c2lnbmF0dXJl")
+	if err := l.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	records := accessLogRecords(t, mockStorage.Puts(), "logs/")
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	for i, want := range []string{"/s/REDACTED/files/report.pdf", "/s/REDACTED"} {
+		if records[i].Path != want {
+			t.Errorf("Expected path %q, got %q", want, records[i].Path)
+		}
+	}
+}
+
+func TestAccessLog_FlushesWhenFull(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	clk := mocks.NewMockClock(time.Now())
+	l := handlers.NewAccessLogWriter(mockStorage, "logs/", time.Hour, 3, clk)
+	defer l.Close(context.Background())
+
+	for range 3 {
+		serveLogged(l, "/files/a.txt")
+	}
+	waitFor(t, func() bool { return len(mockStorage.Puts()) == 1 })
+	if got := len(accessLogRecords(t, mockStorage.Puts(), "logs/")); got != 3 {
+		t.Errorf("Expected 3 records, got %d", got)
+	}
+}
+
+func TestAccessLog_CloseFlushesAndRetries(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	clk := mocks.NewMockClock(time.Now())
+	l := handlers.NewAccessLogWriter(mockStorage, "logs/", time.Hour, 100, clk)
+
+	serveLogged(l, "/files/a.txt")
+
+	// A failed write keeps the records for the next one
+	mockStorage.PutError = errors.New("storage down")
+	waitFor(t, func() bool { return clk.WaiterCount() == 1 })
+	clk.Advance(time.Hour)
+	waitFor(t, func() bool { return clk.WaiterCount() == 1 })
+	serveLogged(l, "/files/b.txt")
+
+	mockStorage.PutError = nil
+	if err := l.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	puts := mockStorage.Puts()
+	if len(puts) != 2 {
+		t.Fatalf("Expected a failed write and a retry, got %d writes", len(puts))
+	}
+	records := accessLogRecords(t, puts[1:], "logs/")
+	if len(records) != 2 || records[0].Path != "/files/a.txt" || records[1].Path != "/files/b.txt" {
+		t.Errorf("Expected both records written on close, got %+v", records)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestGetFile_AgeHeader(t *testing.T) {
+	clk := mocks.NewMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithClock(clk))
+
+	if got := getFile(handler, "a.txt").Header().Get("Age"); got != "0" {
+		t.Errorf("Expected Age 0 on a miss, got '%s'", got)
+	}
+	waitFor(t, func() bool { return mockCache.SetCallCount() == 1 })
+
+	clk.Advance(30 * time.Second)
+	if got := getFile(handler, "a.txt").Header().Get("Age"); got != "30" {
+		t.Errorf("Expected Age 30 after 30s, got '%s'", got)
+	}
+
+	clk.Advance(15 * time.Second)
+	if got := getFile(handler, "a.txt").Header().Get("Age"); got != "45" {
+		t.Errorf("Expected Age 45 after 45s, got '%s'", got)
+	}
+}
+
+func TestGetFile_AgeHeader_CacheDisabled(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	if got := getFile(handler, "a.txt").Header().Get("Age"); got != "0" {
+		t.Errorf("Expected Age 0 from storage, got '%s'", got)
+	}
+}
+
+func TestGetFile_AgeHeader_LegacyEntry(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockCache.SetData("a.txt", []byte("raw body cached by an older version"))
+	handler := handlers.NewFileHandler(mockCache, mocks.NewMockStorage())
+
+	rec := getFile(handler, "a.txt")
+	if _, ok := rec.Header()["Age"]; ok {
+		t.Errorf("Expected no Age for an entry without a timestamp, got '%s'", rec.Header().Get("Age"))
+	}
+}
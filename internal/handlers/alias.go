@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// aliases maps short names to the object keys they stand for
+type aliases struct {
+	mu    sync.RWMutex
+	names map[string]string
+}
+
+// WithAliases serves the object stored under keys[name] for requests to
+// Alias with that name. Aliases can be changed at runtime with SetAlias
+// and DeleteAlias. Names containing "/" and invalid keys are skipped.
+func WithAliases(keys map[string]string) Option {
+	return func(h *FileHandler) {
+		for name, key := range keys {
+			if validAliasName(name) && validObjectKey(key) {
+				h.aliases.names[name] = key
+			}
+		}
+	}
+}
+
+// validAliasName reports whether name can be an alias: one path segment
+func validAliasName(name string) bool {
+	return name != "" && name != "." && name != ".." && len(name) <= maxKeyLength &&
+		!strings.ContainsFunc(name, func(c rune) bool { return c == '/' || c < ' ' || c == 0x7f })
+}
+
+// resolve returns the key name stands for
+func (a *aliases) resolve(name string) (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	key, ok := a.names[name]
+	return key, ok
+}
+
+// Alias handles requests for an object by its alias. The object is served
+// and cached under its real key, so an alias and its key share one cache
+// entry. Unknown aliases get 404.
+func (h *FileHandler) Alias(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("alias")
+	key, ok := h.aliases.resolve(name)
+	if !ok {
+		ctx, cancel := h.requestContext(r)
+		defer cancel()
+		slog.Info("Unknown alias", "alias", name)
+		h.writeNotFound(ctx, w)
+		return
+	}
+	h.serveFile(w, r, key)
+}
+
+// aliasRequest is the body of a request to set an alias
+type aliasRequest struct {
+	Key string `json:"key"`
+}
+
+// SetAlias handles requests to point an alias at a key, replacing any
+// previous target. Changes only apply to this instance and last until it
+// restarts.
+func (h *FileHandler) SetAlias(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("alias")
+	if !validAliasName(name) {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: "invalid alias",
+		})
+		return
+	}
+
+	var req aliasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !validObjectKey(req.Key) {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: "invalid key",
+		})
+		return
+	}
+
+	h.aliases.mu.Lock()
+	h.aliases.names[name] = req.Key
+	h.aliases.mu.Unlock()
+	slog.Info("Alias set", "alias", name, "filename", req.Key)
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: "Alias set",
+		Data:    map[string]string{"alias": name, "key": req.Key},
+	})
+}
+
+// DeleteAlias handles requests to remove an alias from this instance
+func (h *FileHandler) DeleteAlias(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("alias")
+
+	h.aliases.mu.Lock()
+	_, found := h.aliases.names[name]
+	delete(h.aliases.names, name)
+	h.aliases.mu.Unlock()
+
+	if !found {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Message: "alias not found",
+		})
+		return
+	}
+	slog.Info("Alias removed", "alias", name)
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: "Alias removed",
+	})
+}
+
+// ListAliases handles requests for the aliases this instance serves
+func (h *FileHandler) ListAliases(w http.ResponseWriter, r *http.Request) {
+	h.aliases.mu.RLock()
+	names := maps.Clone(h.aliases.names)
+	h.aliases.mu.RUnlock()
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    names,
+	})
+}
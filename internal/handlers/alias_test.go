@@ -0,0 +1,127 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func getAlias(handler *handlers.FileHandler, name string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/f/"+name, nil)
+	req.SetPathValue("alias", name)
+	rec := httptest.NewRecorder()
+	handler.Alias(rec, req)
+	return rec
+}
+
+func setAlias(handler *handlers.FileHandler, name, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/admin/aliases/"+name, strings.NewReader(body))
+	req.SetPathValue("alias", name)
+	rec := httptest.NewRecorder()
+	handler.SetAlias(rec, req)
+	return rec
+}
+
+func deleteAlias(handler *handlers.FileHandler, name string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodDelete, "/admin/aliases/"+name, nil)
+	req.SetPathValue("alias", name)
+	rec := httptest.NewRecorder()
+	handler.DeleteAlias(rec, req)
+	return rec
+}
+
+func TestAlias_SharesCacheWithKey(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("brand/assets/logo-final.png", []byte("png"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithAliases(map[string]string{
+		"logo":    "brand/assets/logo-final.png",
+		"bad/one": "a.txt",
+		"bad-key": "../a.txt",
+	}))
+
+	rec := getAlias(handler, "logo")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Body.String() != "png" {
+		t.Errorf("Expected the aliased object, got %q", rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("Expected the real key's Content-Type, got %q", got)
+	}
+
+	// The entry is cached under the real key, so a request by key hits it
+	waitFor(t, func() bool { return cached(mockCache, "brand/assets/logo-final.png") })
+	if rec := getFile(handler, "brand/assets/logo-final.png"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if got := len(mockStorage.GetCalls); got != 1 {
+		t.Errorf("Expected 1 storage read, got %d", got)
+	}
+
+	for _, name := range []string{"unknown", "bad-key"} {
+		if rec := getAlias(handler, name); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status %d, got %d", name, http.StatusNotFound, rec.Code)
+		}
+	}
+}
+
+func TestAlias_AdminEndpoints(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("alpha"))
+	mockStorage.SetObject("b.txt", []byte("bravo"))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithAliases(map[string]string{"doc": "a.txt"}))
+
+	if rec := setAlias(handler, "doc", `{"key":"b.txt"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if got := getAlias(handler, "doc").Body.String(); got != "bravo" {
+		t.Errorf("Expected the new target, got %q", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/aliases", nil)
+	rec := httptest.NewRecorder()
+	handler.ListAliases(rec, req)
+	resp := parseResponse(t, rec.Body.Bytes())
+	if resp.Data["doc"] != "b.txt" || len(resp.Data) != 1 {
+		t.Errorf("Expected the alias to be listed, got %v", resp.Data)
+	}
+
+	if rec := deleteAlias(handler, "doc"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := getAlias(handler, "doc"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected a removed alias to get %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := deleteAlias(handler, "doc"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected removing a missing alias to get %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestAlias_SetInvalid(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, mocks.NewMockStorage())
+
+	tests := []struct {
+		name  string
+		alias string
+		body  string
+	}{
+		{"slash in alias", "a/b", `{"key":"a.txt"}`},
+		{"dot alias", "..", `{"key":"a.txt"}`},
+		{"missing key", "doc", `{}`},
+		{"traversal key", "doc", `{"key":"../a.txt"}`},
+		{"invalid body", "doc", `{`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := setAlias(handler, tt.alias, tt.body); rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+		})
+	}
+}
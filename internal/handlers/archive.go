@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// defaultArchiveMaxFiles caps the files in one archive request
+const defaultArchiveMaxFiles = 100
+
+// WithArchiveMaxFiles sets how many files one archive request may ask for.
+// Values below 1 keep the default.
+func WithArchiveMaxFiles(n int) Option {
+	return func(h *FileHandler) {
+		if n > 0 {
+			h.archiveMaxFiles = n
+		}
+	}
+}
+
+// errNoMemberETag means an archive member has no ETag to hash
+var errNoMemberETag = errors.New("archive member has no ETag")
+
+// WithArchiveETags sends a weak ETag with each archive, derived from its
+// members' keys and storage ETags, and answers a matching If-None-Match
+// with 304 instead of the archive. Each member is looked up in storage
+// on every request, including ones answered with 304, so an archive of n
+// files costs n HEAD requests before anything is sent.
+func WithArchiveETags(enabled bool) Option {
+	return func(h *FileHandler) {
+		h.archiveETags = enabled
+	}
+}
+
+// archiveRequest is the body of an archive request
+type archiveRequest struct {
+	Keys []string `json:"keys"`
+	Gzip bool     `json:"gzip"`
+}
+
+// TarArchive handles requests to download several files as one tar archive,
+// gzip-compressed if asked for. Each file is read from cache or storage
+// and written to the archive as soon as it's fetched. Keys that don't
+// exist or can't be served are left out. Headers are sent before the first
+// file is fetched, so if storage fails midway the response is aborted and
+// the client sees an incomplete body rather than a short archive.
+func (h *FileHandler) TarArchive(w http.ResponseWriter, r *http.Request) {
+	var req archiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	keys := make([]string, len(req.Keys))
+	for i, key := range req.Keys {
+		keys[i] = h.normalizeKey(key)
+	}
+	keys = dedupeKeys(keys)
+	if len(keys) == 0 || len(keys) > h.archiveMaxFiles {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: fmt.Sprintf("between 1 and %d keys are required", h.archiveMaxFiles),
+		})
+		return
+	}
+
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+
+	if h.archiveETags {
+		etag, err := h.archiveETag(ctx, keys, req.Gzip)
+		if err != nil {
+			// The archive is still served, just not revalidatable
+			slog.Warn("Failed to compute archive ETag", "error", err,
+				"upstream_request_id", storage.RequestID(err))
+		} else {
+			w.Header().Set("ETag", etag)
+			if notModified(r, etag) {
+				writeNotModified(w)
+				return
+			}
+		}
+	}
+
+	filename, contentType := "files.tar", "application/x-tar"
+	if req.Gzip {
+		filename, contentType = "files.tar.gz", "application/gzip"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+	if !r.ProtoAtLeast(1, 1) {
+		w.Header().Set("Connection", "close")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	var out io.Writer = w
+	var zw *gzip.Writer
+	if req.Gzip {
+		zw = gzip.NewWriter(w)
+		out = zw
+	}
+	tw := tar.NewWriter(out)
+
+	written := 0
+	for _, key := range keys {
+		ok, err := h.writeArchiveEntry(ctx, tw, key)
+		if err != nil {
+			slog.Error("Aborting archive", "filename", key, "error", err)
+			panic(http.ErrAbortHandler)
+		}
+		if ok {
+			written++
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		slog.Error("Failed to finish archive", "error", err)
+		return
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			slog.Error("Failed to finish archive", "error", err)
+			return
+		}
+	}
+	slog.Info("Served archive", "files", written, "requested", len(keys), "gzip", req.Gzip)
+}
+
+// writeArchiveEntry adds key to the archive, reporting false if it was
+// skipped. An error means the archive can't be completed.
+func (h *FileHandler) writeArchiveEntry(ctx context.Context, tw *tar.Writer, key string) (bool, error) {
+	if !validObjectKey(key) || !h.keyAllowed(key) {
+		slog.Info("Skipping invalid archive key", "filename", key)
+		return false, nil
+	}
+
+	if h.requiredTagKey != "" {
+		allowed, err := h.tagAllowed(ctx, key)
+		if err != nil && !isNotFoundError(err) {
+			return false, err
+		}
+		if err != nil || !allowed {
+			slog.Info("Skipping archive key", "filename", key)
+			return false, nil
+		}
+	}
+
+	obj, err := h.fetch(ctx, key)
+	if err != nil {
+		if isNotFoundError(err) {
+			slog.Info("Skipping missing archive key", "filename", key)
+			return false, nil
+		}
+		return false, err
+	}
+
+	var body io.Reader
+	var size int64
+	if obj.body != nil {
+		defer obj.body.Close()
+		body, size = obj.body, obj.size
+	} else {
+		body, size = bytes.NewReader(obj.Data), int64(len(obj.Data))
+	}
+
+	// Stored bytes are archived as-is, so compressed objects keep a
+	// suffix saying how to read them
+	name := key
+	if obj.ContentEncoding == "gzip" {
+		name += ".gz"
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     size,
+		ModTime:  h.clock.Now(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	buf := streamBuffers.Get().(*[]byte)
+	defer streamBuffers.Put(buf)
+	if _, err := io.CopyBuffer(tw, body, *buf); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// archiveETag derives the ETag of an archive of keys from the SHA-256 of,
+// for each member in ascending key order, its key, a NUL byte, its ETag
+// without quotes and a newline, followed by whether it's gzipped. Keys
+// that are invalid, blocked, untagged or missing are left out, as they are
+// from the archive. The tar headers carry the time of the request, so the bytes
+// differ between downloads and the ETag is weak.
+func (h *FileHandler) archiveETag(ctx context.Context, keys []string, gzipped bool) (string, error) {
+	sorted := slices.Clone(keys)
+	slices.Sort(sorted)
+
+	sum := sha256.New()
+	for _, key := range sorted {
+		if !validObjectKey(key) || !h.keyAllowed(key) {
+			continue
+		}
+		if h.requiredTagKey != "" {
+			allowed, err := h.tagAllowed(ctx, key)
+			if err != nil && !isNotFoundError(err) {
+				return "", err
+			}
+			if err != nil || !allowed {
+				continue
+			}
+		}
+		info, err := h.storage.StatObject(ctx, key)
+		if err != nil {
+			if isNotFoundError(err) {
+				continue
+			}
+			return "", err
+		}
+		if info.ETag == "" {
+			return "", fmt.Errorf("%w: %s", errNoMemberETag, key)
+		}
+		sum.Write([]byte(key))
+		sum.Write([]byte{0})
+		sum.Write([]byte(strings.Trim(info.ETag, `"`)))
+		sum.Write([]byte{'\n'})
+	}
+	if gzipped {
+		sum.Write([]byte("gzip"))
+	}
+	return `W/"` + hex.EncodeToString(sum.Sum(nil)[:16]) + `"`, nil
+}
+
+// dedupeKeys returns keys without repeats, in their original order
+func dedupeKeys(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
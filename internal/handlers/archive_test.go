@@ -0,0 +1,229 @@
+package handlers_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+func tarArchive(handler *handlers.FileHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/files/tar", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.TarArchive(rec, req)
+	return rec
+}
+
+// readTar returns the archive's entries by name, failing on a truncated
+// archive
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	entries := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Failed to read entry %s: %v", hdr.Name, err)
+		}
+		entries[hdr.Name] = string(data)
+	}
+}
+
+func TestTarArchive(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockCache.SetData("a.txt", []byte("from cache"))
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("dir/b.txt", []byte("from storage"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage)
+
+	rec := tarArchive(handler, `{"keys":["a.txt","dir/b.txt","missing.txt","../etc/passwd","a.txt"]}`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/x-tar" {
+		t.Errorf("Expected Content-Type 'application/x-tar', got '%s'", got)
+	}
+
+	entries := readTar(t, rec.Body)
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 entries, got %d: %v", len(entries), entries)
+	}
+	if entries["a.txt"] != "from cache" {
+		t.Errorf("Expected a.txt from cache, got '%s'", entries["a.txt"])
+	}
+	if entries["dir/b.txt"] != "from storage" {
+		t.Errorf("Expected dir/b.txt from storage, got '%s'", entries["dir/b.txt"])
+	}
+}
+
+func TestTarArchive_Gzip(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	mockStorage.SetObject("c.txt", []byte("compressed"))
+	mockStorage.SetObjectInfo("c.txt", storage.ObjectInfo{ContentEncoding: "gzip"})
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	rec := tarArchive(handler, `{"keys":["a.txt","c.txt"],"gzip":true}`)
+
+	if got := rec.Header().Get("Content-Disposition"); !strings.Contains(got, "files.tar.gz") {
+		t.Errorf("Expected a .tar.gz filename, got '%s'", got)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Expected a gzip body: %v", err)
+	}
+	entries := readTar(t, zr)
+	if entries["a.txt"] != "hello" {
+		t.Errorf("Expected a.txt, got '%s'", entries["a.txt"])
+	}
+	if _, ok := entries["c.txt.gz"]; !ok {
+		t.Errorf("Expected the gzip-stored object as c.txt.gz, got %v", entries)
+	}
+}
+
+func TestTarArchive_KeyCount(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, mocks.NewMockStorage(), handlers.WithArchiveMaxFiles(2))
+
+	for _, body := range []string{`{"keys":[]}`, `{"keys":["a","b","c"]}`, `not json`} {
+		if rec := tarArchive(handler, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", body, rec.Code)
+		}
+	}
+}
+
+func TestTarArchive_StorageErrorAborts(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockCache.SetData("a.txt", []byte("cached"))
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.GetError = errors.New("connection reset")
+	handler := handlers.NewFileHandler(mockCache, mockStorage)
+
+	server := httptest.NewServer(http.HandlerFunc(handler.TarArchive))
+	defer server.Close()
+
+	// Depending on how much was flushed, the failure shows up either on
+	// the response or while reading the body
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"keys":["a.txt","b.txt"]}`))
+	if err == nil {
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+	}
+	if err == nil {
+		t.Error("Expected the download to fail")
+	}
+}
+
+func tarArchiveIfNoneMatch(handler *handlers.FileHandler, body, etag string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/files/tar", strings.NewReader(body))
+	req.Header.Set("If-None-Match", etag)
+	rec := httptest.NewRecorder()
+	handler.TarArchive(rec, req)
+	return rec
+}
+
+func TestTarArchive_ETag(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("alpha"))
+	mockStorage.SetObject("b.txt", []byte("bravo"))
+	mockStorage.SetObjectInfo("a.txt", storage.ObjectInfo{ETag: `"a1"`})
+	mockStorage.SetObjectInfo("b.txt", storage.ObjectInfo{ETag: `"b1"`})
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithArchiveETags(true))
+
+	etag := tarArchive(handler, `{"keys":["a.txt","b.txt","missing.txt"]}`).Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("Expected a weak archive ETag, got %q", etag)
+	}
+
+	// Member order doesn't matter
+	rec := tarArchiveIfNoneMatch(handler, `{"keys":["b.txt","a.txt"]}`, etag)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("Expected no body with 304, got %d bytes", rec.Body.Len())
+	}
+
+	// Compressing or changing a member changes the ETag
+	if got := tarArchive(handler, `{"keys":["a.txt","b.txt"],"gzip":true}`).Header().Get("ETag"); got == etag {
+		t.Errorf("Expected a gzipped archive to get a different ETag, both got %q", etag)
+	}
+	mockStorage.SetObjectInfo("b.txt", storage.ObjectInfo{ETag: `"b2"`})
+	rec = tarArchiveIfNoneMatch(handler, `{"keys":["a.txt","b.txt"]}`, etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d after a member changed, got %d", http.StatusOK, rec.Code)
+	}
+	if entries := readTar(t, rec.Body); entries["b.txt"] != "bravo" {
+		t.Errorf("Expected the archive to be sent, got entries %v", entries)
+	}
+}
+
+func TestTarArchive_ETag_RequiredTag(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("public.txt", []byte("alpha"))
+	mockStorage.SetObject("private.txt", []byte("bravo"))
+	mockStorage.SetObjectInfo("public.txt", storage.ObjectInfo{ETag: `"a1"`})
+	mockStorage.SetObjectInfo("private.txt", storage.ObjectInfo{ETag: `"b1"`})
+	mockStorage.SetTags("public.txt", map[string]string{"visibility": "public"})
+	mockStorage.SetTags("private.txt", map[string]string{"visibility": "private"})
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithArchiveETags(true), handlers.WithRequiredTag("visibility", "public"))
+
+	etag := tarArchive(handler, `{"keys":["public.txt","private.txt"]}`).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an archive ETag")
+	}
+
+	// Untagged members are left out of the ETag as they are of the archive
+	mockStorage.SetObjectInfo("private.txt", storage.ObjectInfo{ETag: `"b2"`})
+	rec := tarArchiveIfNoneMatch(handler, `{"keys":["public.txt","private.txt"]}`, etag)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, rec.Code)
+	}
+	for _, key := range mockStorage.StatCalls {
+		if key == "private.txt" {
+			t.Errorf("Expected untagged member not to be looked up, got stat calls %v", mockStorage.StatCalls)
+			break
+		}
+	}
+}
+
+func TestTarArchive_ETag_Unavailable(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("alpha"))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithArchiveETags(true))
+
+	// A member without an ETag can't be hashed, so the archive has none
+	rec := tarArchiveIfNoneMatch(handler, `{"keys":["a.txt"]}`, "*")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if got := rec.Header().Get("ETag"); got != "" {
+		t.Errorf("Expected no ETag, got %q", got)
+	}
+
+	// Nor is one sent when disabled
+	mockStorage.SetObjectInfo("a.txt", storage.ObjectInfo{ETag: `"a1"`})
+	handler = handlers.NewFileHandler(nil, mockStorage)
+	if got := tarArchive(handler, `{"keys":["a.txt"]}`).Header().Get("ETag"); got != "" {
+		t.Errorf("Expected no ETag when disabled, got %q", got)
+	}
+	if got := len(mockStorage.StatCalls); got != 1 {
+		t.Errorf("Expected 1 stat call, got %d", got)
+	}
+}
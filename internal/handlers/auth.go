@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// RequireBearerToken wraps next so it only runs for requests carrying
+// "Authorization: Bearer <token>". Other requests get 401.
+func RequireBearerToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasBearerToken(r, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeJSON(w, http.StatusUnauthorized, Response{
+				Success: false,
+				Message: "unauthorized",
+			})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// hasBearerToken reports whether r carries "Authorization: Bearer <token>".
+// An empty token never matches.
+func hasBearerToken(r *http.Request, token string) bool {
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// originPullHeader carries the secret a CDN sends when pulling from origin
+const originPullHeader = "X-Origin-Pull-Secret"
+
+// RequireOriginPullSecret wraps next so it only runs for requests carrying
+// the shared secret in X-Origin-Pull-Secret, so data routes can only be
+// reached through the CDN. Other requests get 403. An empty secret returns
+// next unchanged.
+func RequireOriginPullSecret(secret string, next http.HandlerFunc) http.HandlerFunc {
+	if secret == "" {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		given := r.Header.Get(originPullHeader)
+		if subtle.ConstantTimeCompare([]byte(given), []byte(secret)) != 1 {
+			writeJSON(w, http.StatusForbidden, Response{
+				Success: false,
+				Message: "forbidden",
+			})
+			return
+		}
+		next(w, r)
+	}
+}
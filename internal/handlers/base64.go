@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// defaultBase64MaxSize is the largest object returned in a JSON envelope
+const defaultBase64MaxSize = 1 << 20
+
+// WithBase64MaxSize sets the largest object, in stored bytes, that clients
+// can get base64-encoded in a JSON envelope; larger ones get 406. 0
+// disables envelopes, so every object is served as raw bytes.
+func WithBase64MaxSize(n int64) Option {
+	return func(h *FileHandler) {
+		h.base64MaxSize = max(n, 0)
+	}
+}
+
+// wantsBase64 reports whether envelopes are enabled and r asks for one, by
+// sending both "?encode=base64" and an Accept header allowing JSON
+func (h *FileHandler) wantsBase64(r *http.Request) bool {
+	if h.base64MaxSize <= 0 || r.URL.Query().Get("encode") != "base64" {
+		return false
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		t, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && t == "application/json" && params["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// base64Content is the envelope payload for an object
+type base64Content struct {
+	Content         string `json:"content"`
+	ContentType     string `json:"content_type"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+}
+
+// writeBase64 serves obj base64-encoded in a JSON envelope, or 406 when it
+// is over the size limit. Objects stored compressed are sent as stored,
+// with their content encoding in the envelope.
+func (h *FileHandler) writeBase64(w http.ResponseWriter, filename, contentType string, obj *entry) {
+	w.Header().Del("Content-Disposition")
+	addVary(w, "Accept")
+
+	data := obj.Data
+	size := int64(len(data))
+	if obj.body != nil {
+		size = obj.size
+	}
+	if size > h.base64MaxSize {
+		writeJSON(w, http.StatusNotAcceptable, Response{
+			Success: false,
+			Message: "file too large to encode; request it without encode=base64",
+		})
+		return
+	}
+
+	if obj.body != nil {
+		var err error
+		if data, err = io.ReadAll(obj.body); err != nil {
+			slog.Error("Failed to read object body", "filename", filename, "error", err)
+			writeJSON(w, http.StatusInternalServerError, Response{
+				Success: false,
+				Message: "Failed to retrieve file",
+			})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: base64Content{
+			Content:         base64.StdEncoding.EncodeToString(data),
+			ContentType:     contentType,
+			ContentEncoding: obj.ContentEncoding,
+		},
+	})
+}
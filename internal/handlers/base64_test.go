@@ -0,0 +1,104 @@
+package handlers_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+// getFileBase64 requests name with "?encode=base64" and the given Accept
+func getFileBase64(handler *handlers.FileHandler, name, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/files/"+name+"?encode=base64", nil)
+	req.SetPathValue("name", name)
+	req.Header.Set("Accept", accept)
+	rec := httptest.NewRecorder()
+	handler.GetFile(rec, req)
+	return rec
+}
+
+type base64Envelope struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Content         string `json:"content"`
+		ContentType     string `json:"content_type"`
+		ContentEncoding string `json:"content_encoding"`
+	} `json:"data"`
+}
+
+func TestGetFile_Base64Envelope(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	rec := getFileBase64(handler, "a.txt", "application/json")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected Content-Type 'application/json', got '%s'", got)
+	}
+	var env base64Envelope
+	if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+		t.Fatalf("Failed to parse envelope: %v", err)
+	}
+	content, _ := base64.StdEncoding.DecodeString(env.Data.Content)
+	if !env.Success || string(content) != "hello" {
+		t.Errorf("Expected content 'hello', got '%s'", content)
+	}
+	if env.Data.ContentType != "text/plain; charset=utf-8" {
+		t.Errorf("Expected content_type 'text/plain; charset=utf-8', got '%s'", env.Data.ContentType)
+	}
+}
+
+func TestGetFile_Base64Envelope_RawByDefault(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	tests := []struct {
+		name    string
+		accept  string
+		handler *handlers.FileHandler
+	}{
+		{"no JSON accept", "*/*", handler},
+		{"JSON refused", "application/json;q=0", handler},
+		{"envelopes disabled", "application/json", handlers.NewFileHandler(nil, mockStorage, handlers.WithBase64MaxSize(0))},
+	}
+
+	for _, tt := range tests {
+		rec := getFileBase64(tt.handler, "a.txt", tt.accept)
+		if rec.Body.String() != "hello" {
+			t.Errorf("%s: expected the raw body, got '%s'", tt.name, rec.Body.String())
+		}
+	}
+}
+
+func TestGetFile_Base64Envelope_TooLarge(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("big.bin", []byte(strings.Repeat("x", 100)))
+
+	tests := []struct {
+		name string
+		opts []handlers.Option
+	}{
+		{"buffered", []handlers.Option{handlers.WithBase64MaxSize(10)}},
+		{"streamed", []handlers.Option{handlers.WithBase64MaxSize(10), handlers.WithMaxBufferedSize(50)}},
+		{"not redirected", []handlers.Option{handlers.WithBase64MaxSize(10), handlers.WithStorageRedirect(50, time.Minute)}},
+	}
+
+	for _, tt := range tests {
+		handler := handlers.NewFileHandler(nil, mockStorage, tt.opts...)
+		rec := getFileBase64(handler, "big.bin", "application/json")
+		if rec.Code != http.StatusNotAcceptable {
+			t.Errorf("%s: expected status 406, got %d", tt.name, rec.Code)
+		}
+	}
+}
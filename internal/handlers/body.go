@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// bodyRateGrace is how long a body may take to start flowing before the
+// minimum read rate applies
+const bodyRateGrace = time.Second
+
+// errBodyTooSlow is returned by body reads that outlast LimitBodyReadTime
+var errBodyTooSlow = errors.New("request body read too slowly")
+
+// LimitBody wraps next so request bodies over limit bytes are rejected with
+// 413. A declared Content-Length over the limit is rejected before the body
+// is read, so clients sending "Expect: 100-continue" never get the go-ahead
+// to upload it. Bodies without a Content-Length are cut off at the limit and
+// the handler reports the failure through writeBodyError.
+func LimitBody(limit int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			writeBodyTooLarge(w, limit)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next(w, r)
+	}
+}
+
+// LimitBodyReadTime wraps next so a request body must be read within
+// maxDuration and, after the first second, at no less than minRate bytes
+// per second on average. Reads past either deadline fail and the handler
+// responds with 408 through writeBodyError, so a client trickling a body
+// can't hold the connection and whatever the handler has reserved for it.
+// Zero disables either check.
+func LimitBodyReadTime(maxDuration time.Duration, minRate int64, next http.HandlerFunc) http.HandlerFunc {
+	if maxDuration <= 0 && minRate <= 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		body := &deadlineBody{
+			ReadCloser: r.Body,
+			rc:         http.NewResponseController(w),
+			start:      time.Now(),
+			minRate:    minRate,
+		}
+		if maxDuration > 0 {
+			body.deadline = body.start.Add(maxDuration)
+		}
+		r.Body = body
+		next(w, r)
+	}
+}
+
+// deadlineBody moves the connection's read deadline before each read to
+// the earliest time the body would break its LimitBodyReadTime limits
+type deadlineBody struct {
+	io.ReadCloser
+	rc       *http.ResponseController
+	start    time.Time
+	deadline time.Time
+	minRate  int64
+	read     int64
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	deadline := b.deadline
+	if b.minRate > 0 {
+		// The next byte is due when the average would drop below minRate
+		due := b.start.Add(bodyRateGrace + time.Duration(b.read+1)*time.Second/time.Duration(b.minRate))
+		if deadline.IsZero() || due.Before(deadline) {
+			deadline = due
+		}
+	}
+
+	// Connections that don't support deadlines, e.g. HTTP/2 streams on
+	// older transports, are only checked between reads
+	if err := b.rc.SetReadDeadline(deadline); err != nil && time.Now().After(deadline) {
+		return 0, errBodyTooSlow
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return n, errBodyTooSlow
+	}
+	if err == io.EOF {
+		// Later reads of the connection belong to the next request
+		b.rc.SetReadDeadline(time.Time{})
+	}
+	return n, err
+}
+
+// writeBodyError responds to a failure to read or decode a request body,
+// with 413 if the body was over the LimitBody limit, 408 if it was read too
+// slowly for LimitBodyReadTime and 400 otherwise
+func writeBodyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errBodyTooSlow) {
+		// The connection's read deadline has passed, so it can't be reused
+		w.Header().Set("Connection", "close")
+		writeJSON(w, http.StatusRequestTimeout, Response{
+			Success: false,
+			Message: "request body read timed out",
+		})
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeBodyTooLarge(w, tooLarge.Limit)
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, Response{
+		Success: false,
+		Message: "invalid request body",
+	})
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	// The rest of the body is left unread, so the connection can't be reused
+	w.Header().Set("Connection", "close")
+	writeJSON(w, http.StatusRequestEntityTooLarge, Response{
+		Success: false,
+		Message: "request body too large (max " + strconv.FormatInt(limit, 10) + " bytes)",
+	})
+}
@@ -0,0 +1,160 @@
+package handlers_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestLimitBody_DeclaredLengthTooLarge(t *testing.T) {
+	called := false
+	limited := handlers.LimitBody(10, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/cache/warm", strings.NewReader(strings.Repeat("x", 11)))
+	rec := httptest.NewRecorder()
+	limited(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+	if called {
+		t.Error("Expected the handler not to run")
+	}
+}
+
+func TestLimitBody_UndeclaredLengthTooLarge(t *testing.T) {
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mocks.NewMockStorage())
+	limited := handlers.LimitBody(16, handler.WarmCache)
+
+	body := `{"keys":["a.txt","b.txt","c.txt"]}`
+	req := httptest.NewRequest(http.MethodPost, "/admin/cache/warm", strings.NewReader(body))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	limited(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
+
+func TestLimitBody_WithinLimit(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mockStorage)
+	limited := handlers.LimitBody(1024, handler.WarmCache)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/cache/warm", strings.NewReader(`{"keys":["a.txt"]}`))
+	rec := httptest.NewRecorder()
+	limited(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestLimitBody_RejectsBeforeContinue(t *testing.T) {
+	server := httptest.NewServer(handlers.LimitBody(10, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// Only the headers are sent; the server must answer without asking
+	// for the body
+	fmt.Fprint(conn, "POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 1000\r\nExpect: 100-continue\r\n\r\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, resp.StatusCode)
+	}
+}
+
+// trickleBody sends the headers of a warm request declaring a 100-byte body
+// but only a few bytes of it, and returns the response the server sends
+func trickleBody(t *testing.T, handler http.HandlerFunc) *http.Response {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	fmt.Fprint(conn, "POST /admin/cache/warm HTTP/1.1\r\nHost: test\r\nContent-Length: 100\r\n\r\n{\"keys\"")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestLimitBodyReadTime_MaxDuration(t *testing.T) {
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mocks.NewMockStorage())
+
+	start := time.Now()
+	resp := trickleBody(t, handlers.LimitBodyReadTime(100*time.Millisecond, 0, handler.WarmCache))
+
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestTimeout, resp.StatusCode)
+	}
+	if !resp.Close {
+		t.Error("Expected the connection to be closed")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the read to be cut off after 100ms, took %v", elapsed)
+	}
+}
+
+func TestLimitBodyReadTime_MinRate(t *testing.T) {
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mocks.NewMockStorage())
+
+	resp := trickleBody(t, handlers.LimitBodyReadTime(0, 1000, handler.WarmCache))
+
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestTimeout, resp.StatusCode)
+	}
+}
+
+func TestLimitBodyReadTime_WithinLimits(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mockStorage)
+	server := httptest.NewServer(handlers.LimitBodyReadTime(time.Second, 1000, handler.WarmCache))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"keys":["a.txt"]}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Conditional requests are evaluated in the order RFC 9110 section 13.2.2
+// gives: If-None-Match first, which may end the request with 304, then
+// If-Range, which decides whether Range applies at all, and only then the
+// range itself, which may be unsatisfiable (416).
+
+// notModified reports whether If-None-Match matches etag, comparing weakly
+// as RFC 9110 requires. "*" matches any existing object.
+func notModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if etag != "" && strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeApplies reports whether the request's Range header should be
+// honored given If-Range. If-Range needs a strong match with etag; a date
+// never matches since objects are served without Last-Modified, and
+// neither does anything when there is no etag. A failed If-Range means
+// the full body is sent.
+func rangeApplies(r *http.Request, etag string) bool {
+	ifRange := strings.TrimSpace(r.Header.Get("If-Range"))
+	if ifRange == "" {
+		return true
+	}
+	return etag != "" && !strings.HasPrefix(etag, "W/") && ifRange == etag
+}
+
+// writeNotModified ends a request whose If-None-Match matched. Headers
+// already set for a 200, like ETag, Cache-Control and Vary, are kept.
+func writeNotModified(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotModified)
+}
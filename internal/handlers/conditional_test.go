@@ -0,0 +1,103 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+func TestGetFile_ConditionalPrecedence(t *testing.T) {
+	const etag = `"abc"`
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		ifRange     string
+		rangeHeader string
+		wantStatus  int
+		wantBody    string
+	}{
+		// If-None-Match is evaluated first and wins over everything else
+		{"inm match", etag, "", "", http.StatusNotModified, ""},
+		{"inm match with range", etag, "", "bytes=0-3", http.StatusNotModified, ""},
+		{"inm match with unsatisfiable range", etag, "", "bytes=50-", http.StatusNotModified, ""},
+		{"inm match with if-range mismatch", etag, `"other"`, "bytes=0-3", http.StatusNotModified, ""},
+		{"inm weak match", `W/"abc"`, "", "", http.StatusNotModified, ""},
+		{"inm in list", `"x", "abc"`, "", "", http.StatusNotModified, ""},
+		{"inm wildcard", "*", "", "bytes=0-3", http.StatusNotModified, ""},
+		{"inm mismatch", `"other"`, "", "", http.StatusOK, "0123456789"},
+
+		// If-Range then decides whether Range applies
+		{"if-range match", `"other"`, etag, "bytes=0-3", http.StatusPartialContent, "0123"},
+		{"if-range match without inm", "", etag, "bytes=2-4", http.StatusPartialContent, "234"},
+		{"if-range mismatch", "", `"other"`, "bytes=0-3", http.StatusOK, "0123456789"},
+		{"if-range weak", "", `W/"abc"`, "bytes=0-3", http.StatusOK, "0123456789"},
+		{"if-range date", "", "Wed, 21 Oct 2015 07:28:00 GMT", "bytes=0-3", http.StatusOK, "0123456789"},
+		{"if-range without range", "", etag, "", http.StatusOK, "0123456789"},
+
+		// Satisfiability is only checked once the range applies
+		{"unsatisfiable range", "", "", "bytes=50-", http.StatusRequestedRangeNotSatisfiable, ""},
+		{"if-range match unsatisfiable", "", etag, "bytes=50-", http.StatusRequestedRangeNotSatisfiable, ""},
+		{"if-range mismatch unsatisfiable", "", `"other"`, "bytes=50-", http.StatusOK, "0123456789"},
+		{"inm mismatch unsatisfiable", `"other"`, "", "bytes=50-", http.StatusRequestedRangeNotSatisfiable, ""},
+	}
+
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("doc.txt", []byte("0123456789"))
+	mockStorage.SetObjectInfo("doc.txt", storage.ObjectInfo{ETag: etag})
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/files/doc.txt", nil)
+			req.SetPathValue("name", "doc.txt")
+			for name, value := range map[string]string{
+				"If-None-Match": tt.ifNoneMatch,
+				"If-Range":      tt.ifRange,
+				"Range":         tt.rangeHeader,
+			} {
+				if value != "" {
+					req.Header.Set(name, value)
+				}
+			}
+			rec := httptest.NewRecorder()
+			handler.GetFile(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("Expected body '%s', got '%s'", tt.wantBody, rec.Body.String())
+			}
+			if rec.Code == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("Expected no body with 304, got %d bytes", rec.Body.Len())
+			}
+			if got := rec.Header().Get("ETag"); got != etag {
+				t.Errorf("Expected ETag %s, got '%s'", etag, got)
+			}
+		})
+	}
+}
+
+func TestGetFile_ConditionalWithoutETag(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("doc.txt", []byte("0123456789"))
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	req := httptest.NewRequest(http.MethodGet, "/files/doc.txt", nil)
+	req.SetPathValue("name", "doc.txt")
+	req.Header.Set("If-None-Match", `"abc"`)
+	req.Header.Set("If-Range", `"abc"`)
+	req.Header.Set("Range", "bytes=0-3")
+	rec := httptest.NewRecorder()
+	handler.GetFile(rec, req)
+
+	// Nothing can be validated, so the full body is sent
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// UnsafeTypeAction controls how objects with a blocked content type are
+// served
+type UnsafeTypeAction string
+
+const (
+	// UnsafeTypeAttachment serves them as application/octet-stream with
+	// "Content-Disposition: attachment", so browsers download rather than
+	// render them. This is the default.
+	UnsafeTypeAttachment UnsafeTypeAction = "attachment"
+
+	// UnsafeTypePlain serves them as text/plain
+	UnsafeTypePlain UnsafeTypeAction = "plain"
+
+	// UnsafeTypeReject refuses them with 403
+	UnsafeTypeReject UnsafeTypeAction = "reject"
+)
+
+// WithUnsafeContentTypes blocks objects whose content type, e.g.
+// "text/html", is one of types from being served as-is, so user uploads
+// can't run scripts on this origin. action picks what happens instead.
+// Types are matched without parameters and case-insensitively.
+func WithUnsafeContentTypes(types []string, action UnsafeTypeAction) Option {
+	return func(h *FileHandler) {
+		h.unsafeTypes = make(map[string]bool, len(types))
+		for _, t := range types {
+			h.unsafeTypes[mediaType(t)] = true
+		}
+		h.unsafeTypeAction = action
+	}
+}
+
+// WithStoredContentTypes serves objects with the Content-Type they were
+// uploaded with instead of one guessed from their extension, which is then
+// only used for objects stored without a specific type. Enabled by default.
+func WithStoredContentTypes(enabled bool) Option {
+	return func(h *FileHandler) {
+		h.storedContentTypes = enabled
+	}
+}
+
+// specificContentType returns contentType, or "" if it says nothing about
+// the object. Storage falls back to a generic binary type for uploads that
+// didn't set one.
+func specificContentType(contentType string) string {
+	switch mediaType(contentType) {
+	case "", "application/octet-stream", "binary/octet-stream":
+		return ""
+	}
+	return contentType
+}
+
+// contentTypeOf returns the type to serve obj as filename with: the one it
+// was stored with if known and enabled, otherwise a guess from filename's
+// extension, unless sniffing the content disagrees with it
+func (h *FileHandler) contentTypeOf(obj *entry, filename string) string {
+	if h.storedContentTypes && obj.ContentType != "" {
+		return obj.ContentType
+	}
+	contentType := contentTypeFor(filename)
+	if sniffed := h.sniffContentType(obj, filename); sniffed != "" && mediaType(sniffed) != mediaType(contentType) {
+		return sniffed
+	}
+	return contentType
+}
+
+// setResponseType sets the Content-Disposition for serving obj as filename
+// and returns the Content-Type to serve it with. ok is false when the type
+// is blocked outright and nothing was set. Blocked types forced to download
+// stay attachments whatever disposition r asks for.
+func (h *FileHandler) setResponseType(w http.ResponseWriter, r *http.Request, filename string, obj *entry) (contentType string, ok bool) {
+	contentType, disposition := h.contentTypeOf(obj, filename), h.dispositionFor(r, filename)
+	if h.unsafeTypes[mediaType(contentType)] {
+		switch h.unsafeTypeAction {
+		case UnsafeTypeReject:
+			return "", false
+		case UnsafeTypePlain:
+			contentType = "text/plain; charset=utf-8"
+		default:
+			contentType, disposition = "application/octet-stream", "attachment"
+		}
+		// Keep browsers from sniffing the real type back out of the body
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+	}
+
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, filename))
+	return contentType, true
+}
+
+// writeBlockedType refuses an object whose content type is blocked
+func writeBlockedType(w http.ResponseWriter) {
+	writeJSON(w, http.StatusForbidden, Response{
+		Success: false,
+		Message: "content type not allowed",
+	})
+}
+
+// mediaType strips parameters from a content type and lowercases it
+func mediaType(contentType string) string {
+	if t, _, err := mime.ParseMediaType(contentType); err == nil {
+		return t
+	}
+	t, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(t))
+}
@@ -0,0 +1,221 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+func TestGetFile_UnsafeContentTypes(t *testing.T) {
+	tests := []struct {
+		name            string
+		key             string
+		action          handlers.UnsafeTypeAction
+		wantStatus      int
+		wantType        string
+		wantDisposition string
+	}{
+		{"attachment", "page.html", handlers.UnsafeTypeAttachment, http.StatusOK,
+			"application/octet-stream", `attachment; filename="page.html"`},
+		{"plain", "page.html", handlers.UnsafeTypePlain, http.StatusOK,
+			"text/plain; charset=utf-8", `inline; filename="page.html"`},
+		{"reject", "page.html", handlers.UnsafeTypeReject, http.StatusForbidden, "application/json", ""},
+		{"other types unchanged", "doc.pdf", handlers.UnsafeTypeReject, http.StatusOK,
+			"application/pdf", `inline; filename="doc.pdf"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject(tt.key, []byte("<script>alert(1)</script>"))
+			handler := handlers.NewFileHandler(nil, mockStorage,
+				handlers.WithUnsafeContentTypes([]string{"TEXT/HTML", "image/svg+xml"}, tt.action))
+
+			rec := getFile(handler, tt.key)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.wantType, got)
+			}
+			if got := rec.Header().Get("Content-Disposition"); got != tt.wantDisposition {
+				t.Errorf("Expected Content-Disposition %q, got %q", tt.wantDisposition, got)
+			}
+		})
+	}
+}
+
+func TestGetFile_UnsafeContentTypes_Nosniff(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("page.html", []byte("<html></html>"))
+	mockStorage.SetObject("doc.pdf", []byte("%PDF"))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithUnsafeContentTypes([]string{"text/html"}, handlers.UnsafeTypePlain))
+
+	if got := getFile(handler, "page.html").Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("Expected nosniff for a forced type, got %q", got)
+	}
+	if got := getFile(handler, "doc.pdf").Header().Get("X-Content-Type-Options"); got != "" {
+		t.Errorf("Expected no X-Content-Type-Options for an allowed type, got %q", got)
+	}
+}
+
+func TestGetFile_UnsafeContentTypes_Default(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("page.html", []byte("<html></html>"))
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	rec := getFile(handler, "page.html")
+	if got := rec.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Expected text/html by default, got %q", got)
+	}
+}
+
+func TestGetFile_UnsafeContentTypes_NotRedirected(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("big.html", []byte(strings.Repeat("x", 100)))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithStorageRedirect(50, time.Minute),
+		handlers.WithUnsafeContentTypes([]string{"text/html"}, handlers.UnsafeTypeReject))
+
+	rec := getFile(handler, "big.html")
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", rec.Code)
+	}
+	if len(mockStorage.PresignGetCalls) != 0 {
+		t.Errorf("Expected no presigned URL, got %v", mockStorage.PresignGetCalls)
+	}
+}
+
+func TestGetFile_StoredContentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		stored   string
+		enabled  bool
+		wantType string
+	}{
+		{"no extension", "report", "application/pdf", true, "application/pdf"},
+		{"stored wins over extension", "data.txt", "application/json", true, "application/json"},
+		{"generic stored type", "doc.pdf", "binary/octet-stream", true, "application/pdf"},
+		{"no stored type", "doc.pdf", "", true, "application/pdf"},
+		{"disabled", "data.txt", "application/json", false, "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject(tt.key, []byte("content"))
+			mockStorage.SetObjectInfo(tt.key, storage.ObjectInfo{ContentType: tt.stored})
+			handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithStoredContentTypes(tt.enabled))
+
+			rec := getFile(handler, tt.key)
+
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.wantType, got)
+			}
+		})
+	}
+}
+
+func TestGetFile_StoredContentType_CacheHit(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("report", []byte("%PDF"))
+	mockStorage.SetObjectInfo("report", storage.ObjectInfo{ContentType: "application/pdf"})
+	handler := handlers.NewFileHandler(mockCache, mockStorage)
+
+	getFile(handler, "report")
+	waitFor(t, func() bool { return mockCache.SetCallCount() == 1 })
+	mockStorage.ClearObjects()
+
+	rec := getFile(handler, "report")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("Expected the stored type on a cache hit, got %q", got)
+	}
+}
+
+func TestGetFile_StoredContentType_Blocked(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("upload", []byte("<script></script>"))
+	mockStorage.SetObjectInfo("upload", storage.ObjectInfo{ContentType: "text/html"})
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithUnsafeContentTypes([]string{"text/html"}, handlers.UnsafeTypeReject))
+
+	if rec := getFile(handler, "upload"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a blocked stored type, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestGetFile_ContentSniffing(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 600)
+
+	tests := []struct {
+		name      string
+		key       string
+		body      string
+		maxBuffer int64
+		wantType  string
+	}{
+		{"buffered", "image.dat", png, 0, "image/png"},
+		{"streamed", "image.DAT", png, 10, "image/png"},
+		{"unidentified", "blob.dat", "\x00\x01\x02", 0, "application/octet-stream"},
+		{"extension not configured", "image.bin", png, 0, "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject(tt.key, []byte(tt.body))
+			handler := handlers.NewFileHandler(nil, mockStorage,
+				handlers.WithMaxBufferedSize(tt.maxBuffer),
+				handlers.WithContentSniffing([]string{"dat"}, nil),
+			)
+
+			rec := getFile(handler, tt.key)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.wantType, got)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("Expected the whole body after sniffing, got %d bytes", rec.Body.Len())
+			}
+		})
+	}
+}
+
+func TestGetFile_ContentSniffing_StoredTypeWins(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("image.dat", []byte("\x89PNG\r\n\x1a\n"))
+	mockStorage.SetObjectInfo("image.dat", storage.ObjectInfo{ContentType: "image/x-custom"})
+	sniffed := false
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithContentSniffing([]string{".dat"}, func(head []byte) string {
+			sniffed = true
+			return http.DetectContentType(head)
+		}),
+	)
+
+	rec := getFile(handler, "image.dat")
+
+	if got := rec.Header().Get("Content-Type"); got != "image/x-custom" {
+		t.Errorf("Expected the stored type, got %q", got)
+	}
+	if sniffed {
+		t.Error("Expected no sniffing when a stored type is used")
+	}
+}
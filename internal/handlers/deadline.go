@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultRequestTimeout bounds how long a file request may take
+const defaultRequestTimeout = 30 * time.Second
+
+// timeoutHeader carries the caller's remaining time budget in milliseconds
+const timeoutHeader = "X-Timeout-Ms"
+
+// WithRequestTimeout sets the maximum time a file request may take. A
+// shorter budget sent by the caller in X-Timeout-Ms takes precedence.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(h *FileHandler) {
+		if d > 0 {
+			h.requestTimeout = d
+		}
+	}
+}
+
+// WithMinRequestTimeout raises X-Timeout-Ms budgets shorter than d to d,
+// so a caller sending an impossibly small budget still gets a chance to
+// be served. It never raises a budget above the request timeout.
+func WithMinRequestTimeout(d time.Duration) Option {
+	return func(h *FileHandler) {
+		if d > 0 {
+			h.minRequestTimeout = d
+		}
+	}
+}
+
+// requestContext derives the context for handling r, with a deadline of
+// the caller's X-Timeout-Ms budget capped at the configured timeout and
+// raised to the configured floor. Invalid or non-positive budgets are
+// ignored.
+func (h *FileHandler) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	timeout := h.requestTimeout
+	if ms, err := strconv.ParseInt(r.Header.Get(timeoutHeader), 10, 64); err == nil && ms > 0 {
+		if budget := time.Duration(ms) * time.Millisecond; budget < timeout {
+			timeout = max(budget, min(h.minRequestTimeout, timeout))
+			if timeout > budget {
+				h.clampWarning.Do(func() {
+					slog.Warn("Raising X-Timeout-Ms budget to the configured minimum; further clamps are not logged",
+						"budget", budget,
+						"minimum", timeout,
+					)
+				})
+			}
+		}
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
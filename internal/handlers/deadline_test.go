@@ -0,0 +1,94 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// deadlineStorage records the deadline of the context storage is called with
+type deadlineStorage struct {
+	*mocks.MockStorage
+	remaining time.Duration
+}
+
+func (s *deadlineStorage) GetObjectWithInfo(ctx context.Context, key string) ([]byte, storage.ObjectInfo, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		s.remaining = time.Until(deadline)
+	}
+	return s.MockStorage.GetObjectWithInfo(ctx, key)
+}
+
+func TestGetFile_TimeoutHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		min    time.Duration
+		max    time.Duration
+	}{
+		{"no header uses configured timeout", "", 9 * time.Second, 10 * time.Second},
+		{"shorter budget wins", "2000", 1 * time.Second, 2 * time.Second},
+		{"longer budget is capped", "60000", 9 * time.Second, 10 * time.Second},
+		{"invalid budget ignored", "soon", 9 * time.Second, 10 * time.Second},
+		{"zero budget ignored", "0", 9 * time.Second, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &deadlineStorage{MockStorage: mocks.NewMockStorage()}
+			s.SetObject("test.txt", []byte("data"))
+			handler := handlers.NewFileHandler(nil, s, handlers.WithRequestTimeout(10*time.Second))
+
+			req := httptest.NewRequest(http.MethodGet, "/files/test.txt", nil)
+			req.SetPathValue("name", "test.txt")
+			if tt.header != "" {
+				req.Header.Set("X-Timeout-Ms", tt.header)
+			}
+			handler.GetFile(httptest.NewRecorder(), req)
+
+			if s.remaining <= tt.min || s.remaining > tt.max {
+				t.Errorf("Expected remaining time in (%v, %v], got %v", tt.min, tt.max, s.remaining)
+			}
+		})
+	}
+}
+
+func TestGetFile_TimeoutHeader_Floor(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		header  string
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"tiny budget raised to floor", 10 * time.Second, "1", 400 * time.Millisecond, 500 * time.Millisecond},
+		{"budget above floor kept", 10 * time.Second, "2000", 1 * time.Second, 2 * time.Second},
+		{"floor capped at timeout", 200 * time.Millisecond, "1", 100 * time.Millisecond, 200 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &deadlineStorage{MockStorage: mocks.NewMockStorage()}
+			s.SetObject("test.txt", []byte("data"))
+			handler := handlers.NewFileHandler(nil, s,
+				handlers.WithRequestTimeout(tt.timeout),
+				handlers.WithMinRequestTimeout(500*time.Millisecond),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/files/test.txt", nil)
+			req.SetPathValue("name", "test.txt")
+			req.Header.Set("X-Timeout-Ms", tt.header)
+			handler.GetFile(httptest.NewRecorder(), req)
+
+			if s.remaining <= tt.min || s.remaining > tt.max {
+				t.Errorf("Expected remaining time in (%v, %v], got %v", tt.min, tt.max, s.remaining)
+			}
+		})
+	}
+}
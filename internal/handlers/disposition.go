@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// WithDispositionDefaults sets the Content-Disposition type, "inline" or
+// "attachment", that files are served with by extension, e.g.
+// {".zip": "attachment"}. Extensions are matched case-insensitively and
+// unlisted ones are served inline.
+func WithDispositionDefaults(defaults map[string]string) Option {
+	return func(h *FileHandler) {
+		h.dispositionDefaults = make(map[string]string, len(defaults))
+		for ext, disposition := range defaults {
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			if validDisposition(disposition) {
+				h.dispositionDefaults[strings.ToLower(ext)] = disposition
+			}
+		}
+	}
+}
+
+// dispositionFor returns the Content-Disposition type for serving filename:
+// the request's "?disposition=" if valid, otherwise the extension's
+// default, otherwise inline
+func (h *FileHandler) dispositionFor(r *http.Request, filename string) string {
+	if requested := r.URL.Query().Get("disposition"); validDisposition(requested) {
+		return requested
+	}
+	if disposition, ok := h.dispositionDefaults[strings.ToLower(filepath.Ext(filename))]; ok {
+		return disposition
+	}
+	return "inline"
+}
+
+func validDisposition(disposition string) bool {
+	return disposition == "inline" || disposition == "attachment"
+}
@@ -0,0 +1,63 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestGetFile_DispositionDefaults(t *testing.T) {
+	defaults := map[string]string{".zip": "attachment", "pdf": "inline", ".bin": "download"}
+
+	tests := []struct {
+		name  string
+		key   string
+		query string
+		want  string
+	}{
+		{"listed attachment", "a.zip", "", `attachment; filename="a.zip"`},
+		{"extension without dot", "a.pdf", "", `inline; filename="a.pdf"`},
+		{"case-insensitive", "A.ZIP", "", `attachment; filename="A.ZIP"`},
+		{"unlisted is inline", "a.txt", "", `inline; filename="a.txt"`},
+		{"invalid default ignored", "a.bin", "", `inline; filename="a.bin"`},
+		{"query overrides default", "a.zip", "?disposition=inline", `inline; filename="a.zip"`},
+		{"query forces attachment", "a.txt", "?disposition=attachment", `attachment; filename="a.txt"`},
+		{"invalid query ignored", "a.zip", "?disposition=evil", `attachment; filename="a.zip"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject(tt.key, []byte("content"))
+			handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithDispositionDefaults(defaults))
+
+			req := httptest.NewRequest(http.MethodGet, "/files/"+tt.key+tt.query, nil)
+			req.SetPathValue("name", tt.key)
+			rec := httptest.NewRecorder()
+			handler.GetFile(rec, req)
+
+			if got := rec.Header().Get("Content-Disposition"); got != tt.want {
+				t.Errorf("Expected Content-Disposition %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetFile_DispositionQuery_UnsafeTypeStaysAttachment(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("page.html", []byte("<html></html>"))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithUnsafeContentTypes([]string{"text/html"}, handlers.UnsafeTypeAttachment))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/page.html?disposition=inline", nil)
+	req.SetPathValue("name", "page.html")
+	rec := httptest.NewRecorder()
+	handler.GetFile(rec, req)
+
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="page.html"` {
+		t.Errorf("Expected a forced attachment, got %q", got)
+	}
+}
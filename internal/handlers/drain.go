@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultDrainGracePeriod is how long a draining instance keeps serving
+// before it is considered safe to stop
+const defaultDrainGracePeriod = 30 * time.Second
+
+// WithDrainGracePeriod sets how long an instance keeps serving after
+// POST /admin/drain before it is reported safe to terminate. It should
+// cover the time the load balancer needs to notice /readyz failing.
+// Values below 1ns keep the default.
+func WithDrainGracePeriod(d time.Duration) Option {
+	return func(h *FileHandler) {
+		if d > 0 {
+			h.drainGracePeriod = d
+		}
+	}
+}
+
+// drainState records whether the instance was asked to drain, and when
+type drainState struct {
+	mu    sync.Mutex
+	since time.Time
+}
+
+// start marks the instance draining as of now, unless it already is, and
+// returns when draining started
+func (d *drainState) start(now time.Time) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.since.IsZero() {
+		d.since = now
+	}
+	return d.since
+}
+
+// started returns when draining started, or the zero time if it hasn't
+func (d *drainState) started() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.since
+}
+
+// Drain handles requests to take the instance out of rotation ahead of a
+// shutdown. /readyz fails from then on so the load balancer stops sending
+// traffic, while requests that still arrive are served as usual. The
+// response says when the grace period ends and the process can be stopped.
+// Draining can't be undone; restart the instance instead.
+func (h *FileHandler) Drain(w http.ResponseWriter, r *http.Request) {
+	since := h.drain.start(h.clock.Now())
+	safeAt := since.Add(h.drainGracePeriod)
+	slog.Warn("Draining instance", "since", since, "safe_to_terminate_at", safeAt)
+
+	writeJSON(w, http.StatusAccepted, Response{
+		Success: true,
+		Message: "Draining",
+		Data: map[string]string{
+			"draining_since":       since.UTC().Format(time.RFC3339),
+			"safe_to_terminate_at": safeAt.UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// Ready handles readiness probes. It fails once the instance is draining
+// and otherwise reports the same as /health.
+func (h *FileHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	since := h.drain.started()
+	if since.IsZero() {
+		h.Health(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusServiceUnavailable, Response{
+		Success: false,
+		Message: "Service is draining",
+		Data: map[string]string{
+			"status":               "draining",
+			"draining_since":       since.UTC().Format(time.RFC3339),
+			"safe_to_terminate_at": since.Add(h.drainGracePeriod).UTC().Format(time.RFC3339),
+		},
+	})
+}
@@ -0,0 +1,85 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestReady_HealthyBeforeDrain(t *testing.T) {
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mocks.NewMockStorage())
+
+	rec := httptest.NewRecorder()
+	handler.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if resp := parseResponse(t, rec.Body.Bytes()); resp.Data["status"] != "healthy" {
+		t.Errorf("Expected status 'healthy', got '%s'", resp.Data["status"])
+	}
+}
+
+func TestDrain_FailsReadinessButKeepsServing(t *testing.T) {
+	clk := mocks.NewMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mockStorage,
+		handlers.WithClock(clk), handlers.WithDrainGracePeriod(time.Minute))
+
+	rec := httptest.NewRecorder()
+	handler.Drain(rec, httptest.NewRequest(http.MethodPost, "/admin/drain", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+	resp := parseResponse(t, rec.Body.Bytes())
+	if resp.Data["safe_to_terminate_at"] != "2024-01-01T00:01:00Z" {
+		t.Errorf("Expected safe_to_terminate_at 2024-01-01T00:01:00Z, got %q", resp.Data["safe_to_terminate_at"])
+	}
+
+	rec = httptest.NewRecorder()
+	handler.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "" {
+		t.Errorf("Expected no Retry-After while draining, got %q", got)
+	}
+	if resp := parseResponse(t, rec.Body.Bytes()); resp.Data["status"] != "draining" {
+		t.Errorf("Expected status 'draining', got '%s'", resp.Data["status"])
+	}
+
+	// Liveness and file requests are unaffected
+	rec = httptest.NewRecorder()
+	handler.Health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected /health status %d while draining, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := getFile(handler, "a.txt"); rec.Code != http.StatusOK {
+		t.Errorf("Expected file status %d while draining, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestDrain_RepeatKeepsFirstDeadline(t *testing.T) {
+	clk := mocks.NewMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mocks.NewMockStorage(),
+		handlers.WithClock(clk), handlers.WithDrainGracePeriod(time.Minute))
+
+	handler.Drain(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/admin/drain", nil))
+	clk.Advance(30 * time.Second)
+
+	rec := httptest.NewRecorder()
+	handler.Drain(rec, httptest.NewRequest(http.MethodPost, "/admin/drain", nil))
+
+	resp := parseResponse(t, rec.Body.Bytes())
+	if resp.Data["draining_since"] != "2024-01-01T00:00:00Z" {
+		t.Errorf("Expected draining_since to stay 2024-01-01T00:00:00Z, got %q", resp.Data["draining_since"])
+	}
+	if resp.Data["safe_to_terminate_at"] != "2024-01-01T00:01:00Z" {
+		t.Errorf("Expected safe_to_terminate_at to stay 2024-01-01T00:01:00Z, got %q", resp.Data["safe_to_terminate_at"])
+	}
+}
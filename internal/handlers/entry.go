@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/cache"
+)
+
+// entryMagic marks cache values that carry metadata. Values without it
+// are raw bodies cached by older versions and are served as-is.
+var entryMagic = []byte("\x00fcs-entry\x01")
+
+var errCorruptEntry = errors.New("corrupt cache entry")
+
+// crc32c is the table for entry body checksums
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// entry is a file body together with the metadata needed to serve it.
+// It is what gets cached, so cache hits are served exactly like misses.
+type entry struct {
+	Data            []byte `json:"-"`
+	ContentType     string `json:"content_type,omitempty"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	CacheControl    string `json:"cache_control,omitempty"`
+	ETag            string `json:"etag,omitempty"`
+
+	// Checksum is the hex CRC-32C of Data, set when the entry is encoded
+	// and checked when it is decoded. Entries cached before it was added
+	// have none and aren't checked.
+	Checksum string `json:"crc32c,omitempty"`
+
+	// CachedAt is when the entry was written to the cache, in Unix seconds
+	CachedAt int64 `json:"cached_at,omitempty"`
+
+	// modified is when the object was last written in storage. It is only
+	// known for objects just fetched, not for cache hits.
+	modified time.Time
+
+	// storageClass is the storage class of an object just fetched; it
+	// isn't cached since hits don't pay the class's retrieval cost
+	storageClass string
+
+	// age is how long a cache hit has been cached, sent as the Age header.
+	// It is 0 for objects fetched from storage and -1 when unknown.
+	age time.Duration
+
+	// stale is set for a fallback copy served because storage failed
+	stale bool
+
+	// storageKey is the key an object just fetched was found under when
+	// that isn't the requested key, e.g. under a fallback prefix
+	storageKey string
+
+	// body is set instead of Data for objects too large to buffer. It is
+	// size bytes long and must be closed once served.
+	body io.ReadCloser
+	size int64
+
+	// window is set when body holds only that range of the size-byte
+	// object, read from storage for a range request
+	window *byteRange
+}
+
+// version returns the storage revision e was fetched as
+func (e *entry) version() cache.Version {
+	return cache.Version{ETag: e.ETag, Modified: e.modified}
+}
+
+// cachedAge returns how long e had been cached at now, or -1 for entries
+// cached without a timestamp
+func (e *entry) cachedAge(now time.Time) time.Duration {
+	if e.CachedAt <= 0 {
+		return -1
+	}
+	return max(now.Sub(time.Unix(e.CachedAt, 0)), 0)
+}
+
+// encodeEntry serializes e as the magic marker, a length-prefixed JSON
+// header with the metadata and body checksum, and the raw body
+func encodeEntry(e *entry) ([]byte, error) {
+	e.Checksum = fmt.Sprintf("%08x", crc32.Checksum(e.Data, crc32c))
+	header, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	buf := make([]byte, 0, len(entryMagic)+4+len(header)+len(e.Data))
+	buf = append(buf, entryMagic...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(header)))
+	buf = append(buf, header...)
+	buf = append(buf, e.Data...)
+	return buf, nil
+}
+
+// decodeEntry parses a cached value written by encodeEntry
+func decodeEntry(value []byte) (*entry, error) {
+	rest, ok := bytes.CutPrefix(value, entryMagic)
+	if !ok {
+		return &entry{Data: value}, nil
+	}
+
+	if len(rest) < 4 {
+		return nil, errCorruptEntry
+	}
+	n := binary.BigEndian.Uint32(rest)
+	rest = rest[4:]
+	if uint64(len(rest)) < uint64(n) {
+		return nil, errCorruptEntry
+	}
+
+	var e entry
+	if err := json.Unmarshal(rest[:n], &e); err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorruptEntry, err)
+	}
+	e.Data = rest[n:]
+	if e.Checksum != "" && e.Checksum != fmt.Sprintf("%08x", crc32.Checksum(e.Data, crc32c)) {
+		return nil, fmt.Errorf("%w: checksum mismatch", errCorruptEntry)
+	}
+	return &e, nil
+}
@@ -0,0 +1,83 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+func TestGetFile_GeneratedETag_CachedAndMatched(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithGeneratedETags(true))
+
+	rec := getFile(handler, "a.txt")
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("Expected a weak generated ETag, got %q", etag)
+	}
+
+	// The cache hit carries the same ETag, so revalidation gets a 304
+	waitFor(t, func() bool { return cached(mockCache, "a.txt") })
+	rec = getFileWithHeader(handler, "a.txt", http.Header{"If-None-Match": {etag}})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, rec.Code)
+	}
+	if got := len(mockStorage.GetCalls); got != 1 {
+		t.Errorf("Expected 1 storage read, got %d", got)
+	}
+
+	// Different content gets a different ETag
+	mockStorage.SetObject("b.txt", []byte("world"))
+	if other := getFile(handler, "b.txt").Header().Get("ETag"); other == etag {
+		t.Errorf("Expected different content to get a different ETag, both got %q", etag)
+	}
+}
+
+func TestGetFile_GeneratedETag_Streamed(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("big.bin", make([]byte, 32))
+	mockStorage.SetObjectInfo("big.bin", storage.ObjectInfo{LastModified: modified})
+	mockStorage.SetObject("undated.bin", make([]byte, 32))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithGeneratedETags(true),
+		handlers.WithMaxBufferedSize(16),
+	)
+
+	rec := getFile(handler, "big.bin")
+	if want := `W/"20-17a6101701650000"`; rec.Header().Get("ETag") != want {
+		t.Errorf("Expected ETag %q, got %q", want, rec.Header().Get("ETag"))
+	}
+
+	if etag := getFile(handler, "undated.bin").Header().Get("ETag"); etag != "" {
+		t.Errorf("Expected no ETag without a modification time, got %q", etag)
+	}
+}
+
+func TestGetFile_GeneratedETag_KeepsStorageETag(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	mockStorage.SetObjectInfo("a.txt", storage.ObjectInfo{ETag: `"abc"`})
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithGeneratedETags(true))
+
+	if etag := getFile(handler, "a.txt").Header().Get("ETag"); etag != `"abc"` {
+		t.Errorf("Expected storage ETag %q, got %q", `"abc"`, etag)
+	}
+}
+
+func TestGetFile_GeneratedETag_Disabled(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	if etag := getFile(handler, "a.txt").Header().Get("ETag"); etag != "" {
+		t.Errorf("Expected no ETag, got %q", etag)
+	}
+}
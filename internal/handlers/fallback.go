@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// maxFallbackPrefixes caps the fallback prefixes tried for one key, so a
+// miss costs at most that many extra storage reads
+const maxFallbackPrefixes = 3
+
+// fallbackRule retries keys starting with prefix under each of fallbacks
+type fallbackRule struct {
+	prefix    string
+	fallbacks []string
+}
+
+// WithFallbackPrefixes retries objects missing from storage under other
+// prefixes, for keys moving between prefixes during a migration: with
+// {"assets/v2/": {"assets/v1/"}}, a missing "assets/v2/logo.png" is looked
+// up as "assets/v1/logo.png". Fallbacks are tried in order and the first
+// object found is served and cached under the requested key. The longest
+// matching prefix wins, and an empty prefix matches every key. At most
+// maxFallbackPrefixes fallbacks are tried per key; further ones are
+// ignored.
+func WithFallbackPrefixes(rules map[string][]string) Option {
+	return func(h *FileHandler) {
+		h.fallbackRules = make([]fallbackRule, 0, len(rules))
+		for prefix, fallbacks := range rules {
+			if len(fallbacks) == 0 {
+				continue
+			}
+			if len(fallbacks) > maxFallbackPrefixes {
+				slog.Warn("Ignoring fallback prefixes past the limit",
+					"prefix", prefix, "configured", len(fallbacks), "limit", maxFallbackPrefixes)
+				fallbacks = fallbacks[:maxFallbackPrefixes]
+			}
+			h.fallbackRules = append(h.fallbackRules, fallbackRule{
+				prefix:    strings.TrimPrefix(prefix, "/"),
+				fallbacks: fallbacks,
+			})
+		}
+		sort.Slice(h.fallbackRules, func(i, j int) bool {
+			return len(h.fallbackRules[i].prefix) > len(h.fallbackRules[j].prefix)
+		})
+	}
+}
+
+// fallbackKeys returns the keys to try, in order, when key isn't found
+func (h *FileHandler) fallbackKeys(key string) []string {
+	for _, rule := range h.fallbackRules {
+		rest, ok := strings.CutPrefix(key, rule.prefix)
+		if !ok {
+			continue
+		}
+		keys := make([]string, 0, len(rule.fallbacks))
+		for _, fallback := range rule.fallbacks {
+			if candidate := strings.TrimPrefix(fallback, "/") + rest; candidate != key {
+				keys = append(keys, candidate)
+			}
+		}
+		return keys
+	}
+	return nil
+}
+
+// retryFallbackPrefixes looks key up under its fallback prefixes after err
+// reported it missing. The original error is returned if every fallback
+// is missing too.
+func (h *FileHandler) retryFallbackPrefixes(ctx context.Context, key string, err error) (*entry, error) {
+	if !isNotFoundError(err) {
+		return nil, err
+	}
+
+	for _, fallback := range h.fallbackKeys(key) {
+		obj, fallbackErr := h.getCheckedObject(ctx, fallback)
+		if fallbackErr == nil {
+			obj.storageKey = fallback
+			slog.InfoContext(ctx, "Found object under fallback prefix", "filename", key, "fallback", fallback)
+			return obj, nil
+		}
+		if !isNotFoundError(fallbackErr) {
+			return nil, fallbackErr
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,96 @@
+package handlers_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestGetFile_FallbackPrefixes(t *testing.T) {
+	rules := map[string][]string{
+		"assets/v3/": {"assets/v2/", "assets/v1/"},
+		"assets/":    {"legacy/"},
+	}
+
+	tests := []struct {
+		name       string
+		stored     []string
+		request    string
+		wantStatus int
+		wantReads  []string
+	}{
+		{"primary found", []string{"assets/v3/a.png", "assets/v2/a.png"}, "assets/v3/a.png",
+			http.StatusOK, []string{"assets/v3/a.png"}},
+		{"first fallback", []string{"assets/v2/a.png", "assets/v1/a.png"}, "assets/v3/a.png",
+			http.StatusOK, []string{"assets/v3/a.png", "assets/v2/a.png"}},
+		{"second fallback", []string{"assets/v1/a.png"}, "assets/v3/a.png",
+			http.StatusOK, []string{"assets/v3/a.png", "assets/v2/a.png", "assets/v1/a.png"}},
+		{"longest prefix wins", []string{"legacy/v3/a.png"}, "assets/v3/a.png",
+			http.StatusNotFound, []string{"assets/v3/a.png", "assets/v2/a.png", "assets/v1/a.png"}},
+		{"shorter prefix", []string{"legacy/img/a.png"}, "assets/img/a.png",
+			http.StatusOK, []string{"assets/img/a.png", "legacy/img/a.png"}},
+		{"no matching rule", []string{"legacy/a.png"}, "other/a.png",
+			http.StatusNotFound, []string{"other/a.png"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			for _, key := range tt.stored {
+				mockStorage.SetObject(key, []byte(key))
+			}
+			handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithFallbackPrefixes(rules))
+
+			rec := getFile(handler, tt.request)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if !slices.Equal(mockStorage.GetCalls, tt.wantReads) {
+				t.Errorf("Expected reads %q, got %q", tt.wantReads, mockStorage.GetCalls)
+			}
+		})
+	}
+}
+
+func TestGetFile_FallbackPrefixes_CachedUnderRequestedKey(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("old/a.txt", []byte("moved"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithFallbackPrefixes(map[string][]string{"new/": {"old/"}}),
+	)
+
+	if rec := getFile(handler, "new/a.txt"); rec.Code != http.StatusOK || rec.Body.String() != "moved" {
+		t.Fatalf("Expected the fallback object, got %d %q", rec.Code, rec.Body.String())
+	}
+	waitFor(t, func() bool { return cached(mockCache, "new/a.txt") })
+
+	if rec := getFile(handler, "new/a.txt"); rec.Body.String() != "moved" {
+		t.Errorf("Expected the cached fallback object, got %q", rec.Body.String())
+	}
+	if got := len(mockStorage.GetCalls); got != 2 {
+		t.Errorf("Expected only the first request to read storage, got %d reads", got)
+	}
+	if cached(mockCache, "old/a.txt") {
+		t.Error("Expected nothing cached under the fallback key")
+	}
+}
+
+func TestGetFile_FallbackPrefixes_Bounded(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithFallbackPrefixes(map[string][]string{
+		"": {"a/", "b/", "c/", "d/", "e/"},
+	}))
+
+	if rec := getFile(handler, "x.txt"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	want := []string{"x.txt", "a/x.txt", "b/x.txt", "c/x.txt"}
+	if !slices.Equal(mockStorage.GetCalls, want) {
+		t.Errorf("Expected reads %q, got %q", want, mockStorage.GetCalls)
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// streamChunkSize is the size of the buffer streamed responses are copied
+// through, which bounds the memory they use beyond the source itself
+const streamChunkSize = 32 << 10
+
+// defaultGzipRangeLimit is the largest inflated size for which ranged
+// requests on gzip-stored objects are served from an in-memory copy
+const defaultGzipRangeLimit = 8 << 20
+
+// defaultGzipMaxInflatedSize is the largest size a gzip-stored object may
+// inflate to when decompressed on the fly
+const defaultGzipMaxInflatedSize = 1 << 30
+
+var streamBuffers = sync.Pool{
+	New: func() any {
+		buf := make([]byte, streamChunkSize)
+		return &buf
+	},
+}
+
+// WithGzipDecompression inflates gzip-stored objects on the fly for clients
+// that don't accept gzip. Clients that do accept it always get the stored
+// bytes with Content-Encoding: gzip.
+func WithGzipDecompression(enabled bool) Option {
+	return func(h *FileHandler) {
+		h.gzipDecompress = enabled
+	}
+}
+
+// WithGzipRangeLimit sets the largest inflated size, in bytes, for which a
+// Range request on a gzip-stored object is served by decompressing it in
+// memory. Larger objects ignore the Range header and are streamed whole,
+// since compressed bytes can't be seeked into. 0 disables ranged responses
+// for decompressed objects.
+func WithGzipRangeLimit(n int64) Option {
+	return func(h *FileHandler) {
+		if n >= 0 {
+			h.gzipRangeLimit = n
+		}
+	}
+}
+
+// WithGzipMaxInflatedSize sets the largest size, in bytes, a gzip-stored
+// object may inflate to when decompressed on the fly. A response that would
+// inflate past it is aborted, so a small gzip bomb can't tie up the service
+// producing an unbounded body. 0 removes the limit.
+func WithGzipMaxInflatedSize(n int64) Option {
+	return func(h *FileHandler) {
+		if n >= 0 {
+			h.gzipMaxInflated = n
+		}
+	}
+}
+
+// inflateLimited decompresses gzip data that inflates to at most limit
+// bytes. It reports false for larger or invalid data.
+func inflateLimited(data []byte, limit int64) ([]byte, bool) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	defer zr.Close()
+
+	plain, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil || int64(len(plain)) > limit {
+		return nil, false
+	}
+	return plain, true
+}
+
+// gzipInflatedSize returns the inflated size recorded in the trailer of
+// gzip data. The trailer holds the size modulo 2^32 of the last member
+// only, so it is a hint rather than a guarantee.
+func gzipInflatedSize(data []byte) int64 {
+	if len(data) < 4 {
+		return math.MaxInt64
+	}
+	return int64(binary.LittleEndian.Uint32(data[len(data)-4:]))
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows a gzip
+// response. An explicit "gzip;q=0" wins over a wildcard.
+func acceptsGzip(r *http.Request) bool {
+	gzipQ, wildcardQ := -1.0, -1.0
+
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip", "x-gzip":
+			gzipQ = q
+		case "*":
+			wildcardQ = q
+		}
+	}
+
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return wildcardQ > 0
+}
+
+// writeDecompressed streams the inflated form of a gzip body in chunks of
+// streamChunkSize. The inflated length isn't known up front, so no
+// Content-Length is set and net/http uses chunked encoding. HTTP/1.0 has
+// no chunked encoding, so those clients are told the body ends when the
+// connection closes. Bodies inflating past the max inflated size are cut
+// off there and the response is aborted, so the client sees an error
+// rather than a truncated file.
+func (h *FileHandler) writeDecompressed(w http.ResponseWriter, r *http.Request, filename, contentType string, body io.Reader) {
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		slog.Error("Invalid gzip object", "filename", filename, "error", err)
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Message: "Failed to retrieve file",
+		})
+		return
+	}
+	defer zr.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	if !r.ProtoAtLeast(1, 1) {
+		w.Header().Set("Connection", "close")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	buf := streamBuffers.Get().(*[]byte)
+	defer streamBuffers.Put(buf)
+
+	var plain io.Reader = zr
+	if h.gzipMaxInflated > 0 {
+		plain = io.LimitReader(zr, h.gzipMaxInflated)
+	}
+
+	// Hide any ReadFrom on w so the copy always goes through buf. Headers
+	// are already sent, so a failure here can only be logged.
+	if _, err := io.CopyBuffer(struct{ io.Writer }{w}, plain, *buf); err != nil {
+		slog.Error("Failed to decompress object", "filename", filename, "error", err)
+		return
+	}
+
+	if h.gzipMaxInflated > 0 {
+		if n, _ := zr.Read((*buf)[:1]); n > 0 {
+			slog.Error("Aborting decompression past the max inflated size",
+				"filename", filename, "limit", h.gzipMaxInflated)
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
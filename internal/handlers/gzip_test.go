@@ -0,0 +1,250 @@
+package handlers_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("Failed to gzip data: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to gzip data: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newGzipStorage(t *testing.T, plain []byte) (*mocks.MockStorage, []byte) {
+	t.Helper()
+	compressed := gzipBytes(t, plain)
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("page.html", compressed)
+	mockStorage.SetObjectInfo("page.html", storage.ObjectInfo{ContentEncoding: "gzip"})
+	return mockStorage, compressed
+}
+
+func TestGetFile_Gzip_PassThroughForGzipClient(t *testing.T) {
+	mockStorage, compressed := newGzipStorage(t, []byte("<html>hello</html>"))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithGzipDecompression(true))
+
+	rec := getFileWithHeader(handler, "page.html", http.Header{"Accept-Encoding": {"br, gzip;q=0.8"}})
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected Content-Encoding 'gzip', got '%s'", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary 'Accept-Encoding', got '%s'", rec.Header().Get("Vary"))
+	}
+	if !bytes.Equal(rec.Body.Bytes(), compressed) {
+		t.Error("Expected stored gzip bytes to be served unchanged")
+	}
+}
+
+func TestGetFile_Gzip_DecompressesForOtherClients(t *testing.T) {
+	plain := []byte("<html>hello</html>")
+	mockStorage, _ := newGzipStorage(t, plain)
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithGzipDecompression(true))
+
+	tests := []string{"", "identity", "gzip;q=0", "gzip;q=0, *"}
+	for _, acceptEncoding := range tests {
+		rec := getFileWithHeader(handler, "page.html", http.Header{"Accept-Encoding": {acceptEncoding}})
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%q: expected status %d, got %d", acceptEncoding, http.StatusOK, rec.Code)
+		}
+		if rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%q: expected no Content-Encoding, got '%s'", acceptEncoding, rec.Header().Get("Content-Encoding"))
+		}
+		if rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("%q: expected Content-Type 'text/html; charset=utf-8', got '%s'", acceptEncoding, rec.Header().Get("Content-Type"))
+		}
+		if rec.Body.String() != string(plain) {
+			t.Errorf("%q: expected body '%s', got '%s'", acceptEncoding, plain, rec.Body.String())
+		}
+	}
+}
+
+func TestGetFile_Gzip_DisabledPassesThrough(t *testing.T) {
+	mockStorage, compressed := newGzipStorage(t, []byte("<html>hello</html>"))
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	rec := getFile(handler, "page.html")
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected Content-Encoding 'gzip', got '%s'", rec.Header().Get("Content-Encoding"))
+	}
+	if !bytes.Equal(rec.Body.Bytes(), compressed) {
+		t.Error("Expected stored gzip bytes to be served unchanged")
+	}
+}
+
+func TestGetFile_Gzip_EncodingSurvivesCache(t *testing.T) {
+	plain := []byte("<html>hello</html>")
+	mockStorage, _ := newGzipStorage(t, plain)
+	mockCache := mocks.NewMockCache()
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithGzipDecompression(true))
+
+	getFileWithHeader(handler, "page.html", http.Header{"Accept-Encoding": {"gzip"}})
+	waitFor(t, func() bool { return mockCache.SetCallCount() == 1 })
+
+	rec := getFile(handler, "page.html")
+
+	if len(mockStorage.GetCalls) != 1 {
+		t.Errorf("Expected 1 storage get call, got %d", len(mockStorage.GetCalls))
+	}
+	if rec.Body.String() != string(plain) {
+		t.Errorf("Expected body '%s', got '%s'", plain, rec.Body.String())
+	}
+}
+
+func TestGetFile_Gzip_DecompressedForHTTP10ClosesConnection(t *testing.T) {
+	mockStorage, _ := newGzipStorage(t, []byte("<html>hello</html>"))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithGzipDecompression(true))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/page.html", nil)
+	req.SetPathValue("name", "page.html")
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/1.0", 1, 0
+	rec := httptest.NewRecorder()
+	handler.GetFile(rec, req)
+
+	if rec.Header().Get("Connection") != "close" {
+		t.Errorf("Expected Connection 'close', got '%s'", rec.Header().Get("Connection"))
+	}
+
+	// HTTP/1.1 clients keep the connection and get a chunked body
+	rec = getFile(handler, "page.html")
+	if rec.Header().Get("Connection") != "" {
+		t.Errorf("Expected no Connection header, got '%s'", rec.Header().Get("Connection"))
+	}
+}
+
+// countingWriter is a ResponseWriter that discards the body, so that only
+// the handler's own allocations are measured
+type countingWriter struct {
+	header http.Header
+	n      int64
+}
+
+func (c *countingWriter) Header() http.Header         { return c.header }
+func (c *countingWriter) WriteHeader(int)             {}
+func (c *countingWriter) Write(p []byte) (int, error) { c.n += int64(len(p)); return len(p), nil }
+
+func TestGetFile_Gzip_DecompressionMemoryBounded(t *testing.T) {
+	const inflatedSize = 64 << 20
+	mockStorage, _ := newGzipStorage(t, make([]byte, inflatedSize))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithGzipDecompression(true))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/page.html", nil)
+	req.SetPathValue("name", "page.html")
+	w := &countingWriter{header: make(http.Header)}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	handler.GetFile(w, req)
+	runtime.ReadMemStats(&after)
+
+	if w.n != inflatedSize {
+		t.Fatalf("Expected %d bytes written, got %d", inflatedSize, w.n)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4<<20 {
+		t.Errorf("Expected under 4 MiB allocated for a 64 MiB body, got %d bytes", allocated)
+	}
+}
+
+func TestGetFile_Gzip_RangeOnSmallObjectUsesInflatedBytes(t *testing.T) {
+	mockStorage, _ := newGzipStorage(t, []byte("0123456789abcdefghij"))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithGzipDecompression(true))
+
+	rec := getFileWithHeader(handler, "page.html", http.Header{"Range": {"bytes=10-12"}})
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusPartialContent, rec.Code)
+	}
+	if rec.Body.String() != "abc" {
+		t.Errorf("Expected body 'abc', got '%s'", rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 10-12/20" {
+		t.Errorf("Expected Content-Range 'bytes 10-12/20', got '%s'", got)
+	}
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected no Content-Encoding, got '%s'", rec.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestGetFile_Gzip_RangeOverLimitStreamsWhole(t *testing.T) {
+	plain := []byte("0123456789abcdefghij")
+	mockStorage, _ := newGzipStorage(t, plain)
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithGzipDecompression(true),
+		handlers.WithGzipRangeLimit(10),
+	)
+
+	rec := getFileWithHeader(handler, "page.html", http.Header{"Range": {"bytes=10-12"}})
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Body.String() != string(plain) {
+		t.Errorf("Expected body '%s', got '%s'", plain, rec.Body.String())
+	}
+}
+
+func TestGetFile_Gzip_BombAborted(t *testing.T) {
+	const limit = 1 << 20
+	// 64 MiB of zeros compresses to about 64 KiB
+	mockStorage, compressed := newGzipStorage(t, make([]byte, 64<<20))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithGzipDecompression(true),
+		handlers.WithGzipMaxInflatedSize(limit),
+	)
+	if len(compressed) >= limit {
+		t.Fatalf("Expected the compressed payload under the limit, got %d bytes", len(compressed))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/files/page.html", nil)
+	req.SetPathValue("name", "page.html")
+	w := &countingWriter{header: make(http.Header)}
+
+	defer func() {
+		if got := recover(); got != http.ErrAbortHandler {
+			t.Errorf("Expected the response to be aborted, got %v", got)
+		}
+		if w.n != limit {
+			t.Errorf("Expected %d bytes written before aborting, got %d", limit, w.n)
+		}
+	}()
+	handler.GetFile(w, req)
+}
+
+func TestGetFile_Gzip_AtMaxInflatedSizeServed(t *testing.T) {
+	const limit = 1 << 20
+	mockStorage, _ := newGzipStorage(t, make([]byte, limit))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithGzipDecompression(true),
+		handlers.WithGzipMaxInflatedSize(limit),
+	)
+
+	rec := getFile(handler, "page.html")
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Body.Len() != limit {
+		t.Errorf("Expected %d bytes, got %d", limit, rec.Body.Len())
+	}
+}
@@ -2,12 +2,16 @@ package handlers_test
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/ch374n/file-downloader/internal/handlers"
 	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
 )
 
 type TestResponse struct {
@@ -50,6 +54,46 @@ func TestRootHandler(t *testing.T) {
 	}
 }
 
+func TestRootHandler_HealthMode(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithRootMode(handlers.RootModeHealth, ""))
+
+	rec := httptest.NewRecorder()
+	handler.Root(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Body.String() != "OK\n" {
+		t.Errorf("Expected body 'OK', got '%s'", rec.Body.String())
+	}
+	if mockStorage.HealthCheckCalls != 0 {
+		t.Errorf("Expected no storage health check, got %d", mockStorage.HealthCheckCalls)
+	}
+}
+
+func TestRootHandler_RedirectMode(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, mocks.NewMockStorage(),
+		handlers.WithRootMode(handlers.RootModeRedirect, "https://docs.example.com/"))
+
+	rec := httptest.NewRecorder()
+	handler.Root(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusFound {
+		t.Errorf("Expected status %d, got %d", http.StatusFound, rec.Code)
+	}
+	if got := rec.Header().Get("Location"); got != "https://docs.example.com/" {
+		t.Errorf("Expected Location 'https://docs.example.com/', got '%s'", got)
+	}
+
+	// Only "/" itself is redirected
+	rec = httptest.NewRecorder()
+	handler.Root(rec, httptest.NewRequest(http.MethodGet, "/other", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d for other paths, got %d", http.StatusOK, rec.Code)
+	}
+}
+
 func TestHealthHandler_AllHealthy(t *testing.T) {
 	mockCache := mocks.NewMockCache()
 	mockStorage := mocks.NewMockStorage()
@@ -479,3 +523,455 @@ func BenchmarkGetFile_CacheMiss(b *testing.B) {
 		handler.GetFile(rec, req)
 	}
 }
+
+func TestGetFile_NotFoundKey_ServesErrorPage(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithNotFoundKey("errors/404.html"))
+
+	page := []byte("<html>not here</html>")
+	mockStorage.SetObject("errors/404.html", page)
+
+	req := httptest.NewRequest(http.MethodGet, "/files/missing.txt", nil)
+	req.SetPathValue("name", "missing.txt")
+	rec := httptest.NewRecorder()
+
+	handler.GetFile(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec.Body.String() != string(page) {
+		t.Errorf("Expected body '%s', got '%s'", page, rec.Body.String())
+	}
+	contentType := rec.Header().Get("Content-Type")
+	if contentType != "text/html; charset=utf-8" {
+		t.Errorf("Expected Content-Type 'text/html; charset=utf-8', got '%s'", contentType)
+	}
+}
+
+func TestGetFile_NotFoundKey_CachesErrorPage(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithNotFoundKey("errors/404.html"))
+
+	page := []byte("<html>cached</html>")
+	mockCache.SetData("errors/404.html", page)
+
+	req := httptest.NewRequest(http.MethodGet, "/files/missing.txt", nil)
+	req.SetPathValue("name", "missing.txt")
+	rec := httptest.NewRecorder()
+
+	handler.GetFile(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec.Body.String() != string(page) {
+		t.Errorf("Expected body '%s', got '%s'", page, rec.Body.String())
+	}
+
+	// Only the requested file should have gone to storage
+	if len(mockStorage.GetCalls) != 1 {
+		t.Errorf("Expected 1 storage get call, got %d", len(mockStorage.GetCalls))
+	}
+}
+
+func TestGetFile_NotFoundKey_MissingPageFallsBackToJSON(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithNotFoundKey("errors/404.html"))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/missing.txt", nil)
+	req.SetPathValue("name", "missing.txt")
+	rec := httptest.NewRecorder()
+
+	handler.GetFile(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	resp := parseResponse(t, rec.Body.Bytes())
+	if resp.Message != "File not found" {
+		t.Errorf("Expected message 'File not found', got '%s'", resp.Message)
+	}
+}
+
+// waitFor polls cond until it returns true or the deadline passes
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGetFile_RequiredTag_Match(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithRequiredTag("visibility", "public"))
+
+	mockStorage.SetObject("public.txt", []byte("public content"))
+	mockStorage.SetTags("public.txt", map[string]string{"visibility": "public"})
+
+	req := httptest.NewRequest(http.MethodGet, "/files/public.txt", nil)
+	req.SetPathValue("name", "public.txt")
+	rec := httptest.NewRecorder()
+
+	handler.GetFile(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Body.String() != "public content" {
+		t.Errorf("Expected body 'public content', got '%s'", rec.Body.String())
+	}
+}
+
+func TestGetFile_RequiredTag_Mismatch(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithRequiredTag("visibility", "public"))
+
+	mockStorage.SetObject("private.txt", []byte("secret"))
+	mockStorage.SetTags("private.txt", map[string]string{"visibility": "private"})
+
+	req := httptest.NewRequest(http.MethodGet, "/files/private.txt", nil)
+	req.SetPathValue("name", "private.txt")
+	rec := httptest.NewRecorder()
+
+	handler.GetFile(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	// The object body must never be fetched
+	if len(mockStorage.GetCalls) != 0 {
+		t.Errorf("Expected 0 storage get calls, got %d", len(mockStorage.GetCalls))
+	}
+}
+
+func TestGetFile_RequiredTag_DecisionNotShadowedByObjectKey(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithRequiredTag("visibility", "public"))
+
+	mockStorage.SetObject("private.txt", []byte("secret"))
+	mockStorage.SetTags("private.txt", map[string]string{"visibility": "private"})
+	// What caching an object named "tag-decision:private.txt" would leave
+	mockCache.SetData("tag-decision:private.txt", []byte("1"))
+
+	if rec := getFile(handler, "private.txt"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestGetFile_RequiredTag_Untagged(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithRequiredTag("visibility", "public"))
+
+	mockStorage.SetObject("untagged.txt", []byte("content"))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/untagged.txt", nil)
+	req.SetPathValue("name", "untagged.txt")
+	rec := httptest.NewRecorder()
+
+	handler.GetFile(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestGetFile_RequiredTag_DecisionCached(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithRequiredTag("visibility", "public"))
+
+	mockStorage.SetObject("public.txt", []byte("public content"))
+	mockStorage.SetTags("public.txt", map[string]string{"visibility": "public"})
+
+	req := httptest.NewRequest(http.MethodGet, "/files/public.txt", nil)
+	req.SetPathValue("name", "public.txt")
+	handler.GetFile(httptest.NewRecorder(), req)
+
+	// Wait for the tag decision and the object to be cached
+	waitFor(t, func() bool { return mockCache.SetCallCount() == 2 })
+
+	req = httptest.NewRequest(http.MethodGet, "/files/public.txt", nil)
+	req.SetPathValue("name", "public.txt")
+	rec := httptest.NewRecorder()
+	handler.GetFile(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if len(mockStorage.TaggingCalls) != 1 {
+		t.Errorf("Expected 1 tagging call, got %d", len(mockStorage.TaggingCalls))
+	}
+}
+
+func TestGetFile_MissStorm_ShedsOverThreshold(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithMissStormProtection(1, 1.0, 0),
+	)
+	mockStorage.SetObject("a.txt", []byte("a"))
+	mockStorage.SetObject("b.txt", []byte("b"))
+
+	codes := make([]int, 0, 2)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		req := httptest.NewRequest(http.MethodGet, "/files/"+name, nil)
+		req.SetPathValue("name", name)
+		rec := httptest.NewRecorder()
+		handler.GetFile(rec, req)
+		codes = append(codes, rec.Code)
+
+		if rec.Code == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+			t.Error("Expected Retry-After header on shed response")
+		}
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusServiceUnavailable {
+		t.Errorf("Expected [200 503], got %v", codes)
+	}
+	if len(mockStorage.GetCalls) != 1 {
+		t.Errorf("Expected 1 storage get call, got %d", len(mockStorage.GetCalls))
+	}
+}
+
+func TestGetFile_MissStorm_DelaysWithoutShedding(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithMissStormProtection(1, 0, time.Millisecond),
+	)
+	mockStorage.SetObject("a.txt", []byte("a"))
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/files/a.txt", nil)
+		req.SetPathValue("name", "a.txt")
+		rec := httptest.NewRecorder()
+		mockCache.ClearData()
+		handler.GetFile(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("Request %d: expected status %d, got %d", i, http.StatusOK, rec.Code)
+		}
+	}
+}
+
+func TestGetFile_MissStorm_CacheHitsUnaffected(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithMissStormProtection(1, 1.0, 0),
+	)
+	mockCache.SetData("hot.txt", []byte("hot"))
+
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/files/hot.txt", nil)
+		req.SetPathValue("name", "hot.txt")
+		rec := httptest.NewRecorder()
+		handler.GetFile(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("Request %d: expected status %d, got %d", i, http.StatusOK, rec.Code)
+		}
+	}
+}
+
+func TestGetFile_StoredCacheControl(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("logo.png", []byte("png"))
+	mockStorage.SetObjectInfo("logo.png", storage.ObjectInfo{CacheControl: "public, max-age=86400"})
+	handler := handlers.NewFileHandler(mockCache, mockStorage)
+
+	// The second request is served from the cache entry
+	for i := 0; i < 2; i++ {
+		rec := getFile(handler, "logo.png")
+
+		if got := rec.Header().Get("Cache-Control"); got != "public, max-age=86400" {
+			t.Errorf("Request %d: expected Cache-Control 'public, max-age=86400', got '%s'", i, got)
+		}
+		waitFor(t, func() bool { return mockCache.SetCallCount() == 1 })
+	}
+
+	if len(mockStorage.GetCalls) != 1 {
+		t.Errorf("Expected 1 storage get call, got %d", len(mockStorage.GetCalls))
+	}
+}
+
+func TestGetFile_NoStoredCacheControl(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("logo.png", []byte("png"))
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	rec := getFile(handler, "logo.png")
+
+	if got := rec.Header().Get("Cache-Control"); got != "" {
+		t.Errorf("Expected no Cache-Control, got '%s'", got)
+	}
+}
+
+func TestGetFile_MissStorm_WindowResetsWithClock(t *testing.T) {
+	clk := mocks.NewMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("a"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithClock(clk),
+		handlers.WithMissStormProtection(1, 1.0, 0),
+	)
+
+	if rec := getFile(handler, "a.txt"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := getFile(handler, "a.txt"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d within the window, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	clk.Advance(time.Second)
+
+	if rec := getFile(handler, "a.txt"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d in the next window, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestGetFile_SetsContentLength(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("test.txt", []byte("hello world"))
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	rec := getFile(handler, "test.txt")
+
+	if rec.Header().Get("Content-Length") != "11" {
+		t.Errorf("Expected Content-Length '11', got '%s'", rec.Header().Get("Content-Length"))
+	}
+}
+
+func TestGetFile_KeyPatterns(t *testing.T) {
+	allow := regexp.MustCompile(`^public/`)
+	deny := regexp.MustCompile(`(^|/)\.`)
+
+	tests := []struct {
+		key        string
+		wantStatus int
+	}{
+		{"public/logo.png", http.StatusOK},
+		{"private/logo.png", http.StatusNotFound},
+		{"public/.env", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		mockStorage := mocks.NewMockStorage()
+		mockStorage.SetObject(tt.key, []byte("data"))
+		handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithKeyPatterns(allow, deny))
+
+		rec := getFile(handler, tt.key)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.key, tt.wantStatus, rec.Code)
+		}
+		if tt.wantStatus == http.StatusNotFound && len(mockStorage.GetCalls) != 0 {
+			t.Errorf("%s: expected no storage get calls, got %d", tt.key, len(mockStorage.GetCalls))
+		}
+	}
+}
+
+func TestGetFile_PrivatePrefixes(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("logs/access/1.ndjson", []byte("{}"))
+	mockStorage.SetObject("logs.txt", []byte("public"))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithKeyPatterns(regexp.MustCompile(`.*`), nil),
+		handlers.WithPrivatePrefixes("logs/access/", ""),
+	)
+
+	if rec := getFile(handler, "logs/access/1.ndjson"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected a private key to be refused with %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := getFile(handler, "logs.txt"); rec.Code != http.StatusOK {
+		t.Errorf("Expected other keys to be served, got %d", rec.Code)
+	}
+}
+
+func TestGetFile_KeyPatterns_DenyOnly(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("report.pdf", []byte("data"))
+	mockStorage.SetObject("backup.sql", []byte("data"))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithKeyPatterns(nil, regexp.MustCompile(`\.sql$`)),
+	)
+
+	if rec := getFile(handler, "report.pdf"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := getFile(handler, "backup.sql"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+// upstreamError mimics an SDK response error carrying a request ID
+type upstreamError struct {
+	msg       string
+	requestID string
+}
+
+func (e *upstreamError) Error() string            { return e.msg }
+func (e *upstreamError) ServiceRequestID() string { return e.requestID }
+
+func TestGetFile_StorageError_UpstreamRequestID(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.GetError = fmt.Errorf("failed to get object test.txt: %w",
+		&upstreamError{msg: "InternalError", requestID: "req-123"})
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	rec := getFile(handler, "test.txt")
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+	if got := rec.Header().Get("X-Upstream-Request-ID"); got != "req-123" {
+		t.Errorf("Expected X-Upstream-Request-ID 'req-123', got '%s'", got)
+	}
+}
+
+func TestGetFile_NotFound_NoUpstreamRequestID(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.GetError = &upstreamError{msg: "NoSuchKey", requestID: "req-456"}
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	rec := getFile(handler, "test.txt")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if got := rec.Header().Get("X-Upstream-Request-ID"); got != "" {
+		t.Errorf("Expected no X-Upstream-Request-ID, got '%s'", got)
+	}
+}
+
+func TestGetFile_WriteOnMissDisabled(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockCache.SetData("warm.txt", []byte("warmed"))
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("cold.txt", []byte("cold"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithCacheWriteOnMiss(false))
+
+	if rec := getFile(handler, "cold.txt"); rec.Code != http.StatusOK || rec.Body.String() != "cold" {
+		t.Errorf("Expected the miss served from storage, got %d '%s'", rec.Code, rec.Body.String())
+	}
+	if rec := getFile(handler, "warm.txt"); rec.Body.String() != "warmed" {
+		t.Errorf("Expected the warmed entry served from cache, got '%s'", rec.Body.String())
+	}
+
+	// Give a background set the chance to run
+	time.Sleep(20 * time.Millisecond)
+	if mockCache.SetCallCount() != 0 {
+		t.Errorf("Expected no cache writes, got %d", mockCache.SetCallCount())
+	}
+}
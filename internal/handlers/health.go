@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// defaultHealthCheckTimeout bounds each dependency check in /health
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// WithHealthCheckTimeout sets how long /health waits for each dependency
+// before reporting it unhealthy, so a hung Redis or R2 can't make the
+// health endpoint itself slow
+func WithHealthCheckTimeout(d time.Duration) Option {
+	return func(h *FileHandler) {
+		if d > 0 {
+			h.healthCheckTimeout = d
+		}
+	}
+}
+
+// dependencyCheck is the outcome of one dependency check in /health
+type dependencyCheck struct {
+	err     error
+	latency time.Duration
+}
+
+// checkDependency runs check with the health check timeout. It returns
+// once the timeout passes even if check ignores its context and hangs.
+func (h *FileHandler) checkDependency(ctx context.Context, check func(context.Context) error) dependencyCheck {
+	ctx, cancel := context.WithTimeout(ctx, h.healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %v", h.healthCheckTimeout)
+	}
+	return dependencyCheck{err: err, latency: time.Since(start)}
+}
+
+// formatLatency renders a check latency in milliseconds for the payload
+func formatLatency(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 1, 64)
+}
@@ -0,0 +1,102 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+// hungStorage never answers health checks, ignoring their context
+type hungStorage struct {
+	*mocks.MockStorage
+	unblock chan struct{}
+}
+
+func (s *hungStorage) HealthCheck(ctx context.Context) error {
+	<-s.unblock
+	return nil
+}
+
+// hungCache never answers pings, ignoring their context
+type hungCache struct {
+	*mocks.MockCache
+	unblock chan struct{}
+}
+
+func (c *hungCache) Ping(ctx context.Context) error {
+	<-c.unblock
+	return nil
+}
+
+func checkHealth(t *testing.T, handler *handlers.FileHandler) (*httptest.ResponseRecorder, time.Duration) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	handler.Health(rec, req)
+	return rec, time.Since(start)
+}
+
+func TestHealthHandler_StorageTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	t.Cleanup(func() { close(unblock) })
+	s := &hungStorage{MockStorage: mocks.NewMockStorage(), unblock: unblock}
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), s,
+		handlers.WithHealthCheckTimeout(50*time.Millisecond))
+
+	rec, took := checkHealth(t, handler)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", rec.Code)
+	}
+	if took > time.Second {
+		t.Errorf("Expected a prompt response, took %v", took)
+	}
+	resp := parseResponse(t, rec.Body.Bytes())
+	if !strings.Contains(resp.Data["r2"], "timed out") {
+		t.Errorf("Expected r2 to have timed out, got '%s'", resp.Data["r2"])
+	}
+	if resp.Data["redis"] != "healthy" {
+		t.Errorf("Expected redis 'healthy', got '%s'", resp.Data["redis"])
+	}
+}
+
+func TestHealthHandler_CacheTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	t.Cleanup(func() { close(unblock) })
+	c := &hungCache{MockCache: mocks.NewMockCache(), unblock: unblock}
+	handler := handlers.NewFileHandler(c, mocks.NewMockStorage(),
+		handlers.WithHealthCheckTimeout(50*time.Millisecond))
+
+	rec, took := checkHealth(t, handler)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if took > time.Second {
+		t.Errorf("Expected a prompt response, took %v", took)
+	}
+	resp := parseResponse(t, rec.Body.Bytes())
+	if !strings.HasPrefix(resp.Data["redis"], "unhealthy: timed out") {
+		t.Errorf("Expected redis to have timed out, got '%s'", resp.Data["redis"])
+	}
+}
+
+func TestHealthHandler_ReportsLatency(t *testing.T) {
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mocks.NewMockStorage())
+
+	rec, _ := checkHealth(t, handler)
+
+	resp := parseResponse(t, rec.Body.Bytes())
+	for _, key := range []string{"redis_latency_ms", "r2_latency_ms"} {
+		if resp.Data[key] == "" {
+			t.Errorf("Expected %s in the payload", key)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import "strings"
+
+// WithIndexFile serves the object named name under a prefix for requests
+// for the prefix itself, i.e. keys ending in "/", like a static web server
+// serving index.html for a directory. A missing index object is a 404
+// like any other missing key. An empty name, or one containing "/",
+// disables it.
+func WithIndexFile(name string) Option {
+	return func(h *FileHandler) {
+		if !strings.Contains(name, "/") {
+			h.indexFile = name
+		}
+	}
+}
+
+// indexKey returns the key to serve for a request for key: the index
+// object for a prefix, and key itself otherwise
+func (h *FileHandler) indexKey(key string) string {
+	if h.indexFile == "" || !strings.HasSuffix(key, "/") {
+		return key
+	}
+	return key + h.indexFile
+}
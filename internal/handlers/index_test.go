@@ -0,0 +1,71 @@
+package handlers_test
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestGetFile_IndexFile(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("docs/index.html", []byte("<h1>docs</h1>"))
+	mockStorage.SetObject("errors/404.html", []byte("<h1>missing</h1>"))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithIndexFile("index.html"),
+		handlers.WithNotFoundKey("errors/404.html"),
+	)
+
+	rec := getFile(handler, "docs/")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Body.String() != "<h1>docs</h1>" {
+		t.Errorf("Expected the index object, got %q", rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Expected the index object's Content-Type, got %q", got)
+	}
+
+	// A prefix without an index gets the usual 404 page
+	rec = getFile(handler, "images/")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec.Body.String() != "<h1>missing</h1>" {
+		t.Errorf("Expected the 404 page, got %q", rec.Body.String())
+	}
+
+	// Other keys are served as they are
+	if rec := getFile(handler, "docs/index.html"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestGetFile_IndexFile_Disabled(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("docs/index.html", []byte("<h1>docs</h1>"))
+	mockStorage.SetObject("docs/", []byte("marker"))
+
+	for _, opts := range [][]handlers.Option{nil, {handlers.WithIndexFile("sub/index.html")}} {
+		handler := handlers.NewFileHandler(nil, mockStorage, opts...)
+		if got := getFile(handler, "docs/").Body.String(); got != "marker" {
+			t.Errorf("Expected the prefix key to be served as is, got %q", got)
+		}
+	}
+}
+
+func TestGetFile_IndexFile_KeyPatterns(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("docs/index.html", []byte("<h1>docs</h1>"))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithIndexFile("index.html"),
+		handlers.WithKeyPatterns(nil, regexp.MustCompile(`index\.html$`)),
+	)
+
+	if rec := getFile(handler, "docs/"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected a denied index object to get %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// KeyDecoding controls how the {name} path value is turned into a storage key
+type KeyDecoding string
+
+const (
+	// KeyDecodingPath uses the path value as decoded once by the router:
+	// "%20" is a space and "+" is a literal plus. This is the default.
+	KeyDecodingPath KeyDecoding = "path"
+
+	// KeyDecodingPlus decodes the raw path segment with query-string rules:
+	// "+" and "%20" are both a space, and a literal plus must be sent as "%2B".
+	KeyDecodingPlus KeyDecoding = "plus"
+
+	// KeyDecodingDouble removes a second layer of percent-encoding for
+	// clients that escape keys twice ("%2520" -> " "). Keys that literally
+	// contain a valid "%XX" sequence can't be requested in this mode.
+	KeyDecodingDouble KeyDecoding = "double"
+)
+
+// WithKeyDecoding sets how request paths are decoded into storage keys
+func WithKeyDecoding(mode KeyDecoding) Option {
+	return func(h *FileHandler) {
+		h.keyDecoding = mode
+	}
+}
+
+// KeyNormalization selects the Unicode normalization form applied to keys
+type KeyNormalization string
+
+const (
+	// KeyNormalizationNone uses keys exactly as requested. This is the
+	// default.
+	KeyNormalizationNone KeyNormalization = "none"
+
+	// KeyNormalizationNFC composes keys, so "e" followed by a combining
+	// acute accent is looked up as the single character "é"
+	KeyNormalizationNFC KeyNormalization = "nfc"
+
+	// KeyNormalizationNFD decomposes keys, so "é" is looked up as "e"
+	// followed by a combining acute accent
+	KeyNormalizationNFD KeyNormalization = "nfd"
+)
+
+// WithKeyNormalization normalizes requested keys to the given Unicode form
+// before they are looked up in storage or used as cache keys, so both
+// forms of a visually identical name resolve to the same object. Objects
+// must be stored under keys in the same form for lookups to find them.
+func WithKeyNormalization(form KeyNormalization) Option {
+	return func(h *FileHandler) {
+		h.keyNormalization = form
+	}
+}
+
+// normalizeKey returns key in the configured normalization form
+func (h *FileHandler) normalizeKey(key string) string {
+	switch h.keyNormalization {
+	case KeyNormalizationNFC:
+		return norm.NFC.String(key)
+	case KeyNormalizationNFD:
+		return norm.NFD.String(key)
+	default:
+		return key
+	}
+}
+
+// internalKeyPrefix starts the cache keys the handler keeps for its own
+// bookkeeping rather than for objects. Keys with control characters are
+// refused wherever a client names one, so no request can read or write
+// these entries.
+const internalKeyPrefix = "\x00fcs:"
+
+// errControlCharacters rejects keys with control characters such as CR and
+// LF, which could otherwise end up splitting headers or log lines
+var errControlCharacters = errors.New("key contains control characters")
+
+// decodeKey returns the storage key for the named path wildcard according
+// to the configured decoding mode, in the configured normalization form.
+// Keys containing control characters are rejected with errControlCharacters.
+func (h *FileHandler) decodeKey(r *http.Request, name string) (string, error) {
+	key, err := h.decodePathValue(r, name)
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsFunc(key, unicode.IsControl) {
+		return "", errControlCharacters
+	}
+	return h.normalizeKey(key), nil
+}
+
+// decodePathValue decodes the named wildcard per the key decoding mode
+func (h *FileHandler) decodePathValue(r *http.Request, name string) (string, error) {
+	value := r.PathValue(name)
+
+	switch h.keyDecoding {
+	case KeyDecodingPlus:
+		raw, ok := rawPathValue(r, name)
+		if !ok {
+			return value, nil
+		}
+		return url.QueryUnescape(raw)
+	case KeyDecodingDouble:
+		// A value that isn't valid percent-encoding was only encoded once
+		if decoded, err := url.PathUnescape(value); err == nil {
+			return decoded, nil
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+// rawPathValue returns the still-escaped form of the named wildcard. The
+// router only exposes decoded values, so the wildcard is located in the
+// escaped path by its segment position in the matched route pattern.
+func rawPathValue(r *http.Request, name string) (string, bool) {
+	// Pattern looks like "GET /files/{name}"; drop the method
+	pattern := r.Pattern
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		pattern = pattern[i+1:]
+	}
+
+	position, rest := -1, false
+	for i, segment := range strings.Split(pattern, "/") {
+		if segment == "{"+name+"}" || segment == "{"+name+"...}" {
+			position, rest = i, segment == "{"+name+"...}"
+			break
+		}
+	}
+	if position < 0 {
+		return "", false
+	}
+
+	segments := strings.Split(r.URL.EscapedPath(), "/")
+	if len(segments) <= position {
+		return "", false
+	}
+	// Only a {name...} wildcard spans the segments after it
+	if rest {
+		return strings.Join(segments[position:], "/"), true
+	}
+	return segments[position], true
+}
+
+// keyErrorMessage describes a decodeKey error for the client
+func keyErrorMessage(err error) string {
+	if errors.Is(err, errControlCharacters) {
+		return "filename must not contain control characters"
+	}
+	return "invalid filename encoding"
+}
+
+// contentDisposition formats a Content-Disposition header value for
+// filename as a quoted string, escaping quotes and backslashes so a key
+// can't add parameters, and dropping any control characters
+func contentDisposition(disposition, filename string) string {
+	var b strings.Builder
+	b.WriteString(disposition)
+	b.WriteString(`; filename="`)
+	for _, r := range filename {
+		switch {
+		case unicode.IsControl(r):
+			continue
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,212 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestGetFile_KeyDecoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    handlers.KeyDecoding
+		path    string
+		wantKey string
+	}{
+		// Default: the router decodes once and "+" is literal
+		{"path/percent space", handlers.KeyDecodingPath, "/files/my%20file.pdf", "my file.pdf"},
+		{"path/literal plus", handlers.KeyDecodingPath, "/files/my+file.pdf", "my+file.pdf"},
+		{"path/encoded plus", handlers.KeyDecodingPath, "/files/my%2Bfile.pdf", "my+file.pdf"},
+		{"path/double encoded stays encoded", handlers.KeyDecodingPath, "/files/my%2520file.pdf", "my%20file.pdf"},
+		{"path/duplicate slashes kept", handlers.KeyDecodingPath, "/files/a%2F%2Fb.txt", "a//b.txt"},
+
+		// Plus: query-string rules on the raw segment
+		{"plus/plus is space", handlers.KeyDecodingPlus, "/files/my+file.pdf", "my file.pdf"},
+		{"plus/percent space", handlers.KeyDecodingPlus, "/files/my%20file.pdf", "my file.pdf"},
+		{"plus/encoded plus is literal", handlers.KeyDecodingPlus, "/files/my%2Bfile.pdf", "my+file.pdf"},
+		{"plus/mixed", handlers.KeyDecodingPlus, "/files/a+b%2Bc%20d.txt", "a b+c d.txt"},
+
+		// Double: a second percent-decoding pass
+		{"double/double encoded space", handlers.KeyDecodingDouble, "/files/my%2520file.pdf", "my file.pdf"},
+		{"double/single encoded space", handlers.KeyDecodingDouble, "/files/my%20file.pdf", "my file.pdf"},
+		{"double/literal plus", handlers.KeyDecodingDouble, "/files/my+file.pdf", "my+file.pdf"},
+		{"double/lone percent kept", handlers.KeyDecodingDouble, "/files/100%25.txt", "100%.txt"},
+		{"double/duplicate slashes kept", handlers.KeyDecodingDouble, "/files/a%252F%252Fb.txt", "a//b.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject(tt.wantKey, []byte("content"))
+			handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithKeyDecoding(tt.mode))
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /files/{name}", handler.GetFile)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if len(mockStorage.GetCalls) != 1 || mockStorage.GetCalls[0] != tt.wantKey {
+				t.Errorf("Expected storage lookup of %q, got %q", tt.wantKey, mockStorage.GetCalls)
+			}
+		})
+	}
+}
+
+func TestGetFile_KeyDecoding_PlusWithoutPattern(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("my+file.pdf", []byte("content"))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithKeyDecoding(handlers.KeyDecodingPlus))
+
+	// Called directly, without a router, there's no raw segment to decode
+	req := httptest.NewRequest(http.MethodGet, "/files/my+file.pdf", nil)
+	req.SetPathValue("name", "my+file.pdf")
+	rec := httptest.NewRecorder()
+
+	handler.GetFile(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestGetFile_KeyNormalization(t *testing.T) {
+	const (
+		composed   = "caf\u00e9.txt"
+		decomposed = "cafe\u0301.txt"
+	)
+
+	tests := []struct {
+		name    string
+		form    handlers.KeyNormalization
+		key     string
+		wantKey string
+	}{
+		{"none/composed kept", handlers.KeyNormalizationNone, composed, composed},
+		{"none/decomposed kept", handlers.KeyNormalizationNone, decomposed, decomposed},
+		{"nfc/composed kept", handlers.KeyNormalizationNFC, composed, composed},
+		{"nfc/decomposed composed", handlers.KeyNormalizationNFC, decomposed, composed},
+		{"nfd/composed decomposed", handlers.KeyNormalizationNFD, composed, decomposed},
+		{"nfd/decomposed kept", handlers.KeyNormalizationNFD, decomposed, decomposed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject(tt.wantKey, []byte("content"))
+			handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithKeyNormalization(tt.form))
+
+			req := httptest.NewRequest(http.MethodGet, "/files/"+url.PathEscape(tt.key), nil)
+			req.SetPathValue("name", tt.key)
+			rec := httptest.NewRecorder()
+			handler.GetFile(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if len(mockStorage.GetCalls) != 1 || mockStorage.GetCalls[0] != tt.wantKey {
+				t.Errorf("Expected storage lookup of %q, got %q", tt.wantKey, mockStorage.GetCalls)
+			}
+		})
+	}
+}
+
+func TestGetFile_RejectsControlCharacters(t *testing.T) {
+	tests := []struct {
+		name string
+		mode handlers.KeyDecoding
+		path string
+	}{
+		{"path/CRLF", handlers.KeyDecodingPath, "/files/a%0D%0ASet-Cookie:%20x=1"},
+		{"path/NUL", handlers.KeyDecodingPath, "/files/a%00.txt"},
+		{"plus/CRLF", handlers.KeyDecodingPlus, "/files/a%0D%0Ab.txt"},
+		{"double/double encoded CRLF", handlers.KeyDecodingDouble, "/files/a%250D%250Ab.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithKeyDecoding(tt.mode))
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /files/{name}", handler.GetFile)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", rec.Code)
+			}
+			if len(mockStorage.GetCalls) != 0 {
+				t.Errorf("Expected no storage lookup, got %q", mockStorage.GetCalls)
+			}
+		})
+	}
+}
+
+func TestGetFile_HeaderSafeFilenames(t *testing.T) {
+	tests := []struct {
+		name            string
+		key             string
+		wantDisposition string
+	}{
+		{"semicolon", "a;b.txt", `inline; filename="a;b.txt"`},
+		{"injected parameter", `a.txt"; filename*=UTF-8''evil.html`, `inline; filename="a.txt\"; filename*=UTF-8''evil.html"`},
+		{"backslash", `a\b.txt`, `inline; filename="a\\b.txt"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject(tt.key, []byte("content"))
+			handler := handlers.NewFileHandler(nil, mockStorage)
+
+			req := httptest.NewRequest(http.MethodGet, "/files/"+url.PathEscape(tt.key), nil)
+			req.SetPathValue("name", tt.key)
+			rec := httptest.NewRecorder()
+			handler.GetFile(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Disposition"); got != tt.wantDisposition {
+				t.Errorf("Expected Content-Disposition %q, got %q", tt.wantDisposition, got)
+			}
+		})
+	}
+}
+
+func TestKeyDecoding_PlusOnSuffixedRoutes(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithKeyDecoding(handlers.KeyDecodingPlus),
+		handlers.WithUploadURLs([]string{"image/png"}, 10*time.Minute),
+		handlers.WithManifestMaxObjects(10),
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /files/{name}/upload-url", handler.UploadURL)
+	mux.HandleFunc("GET /manifest/{prefix}/checksum", handler.ManifestChecksum)
+
+	req := httptest.NewRequest(http.MethodPost, "/files/a+b.png/upload-url", strings.NewReader(`{"content_type":"image/png","size":3}`))
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+	if len(mockStorage.PresignCalls) != 1 || mockStorage.PresignCalls[0].Key != "a b.png" {
+		t.Errorf("Expected an upload URL for %q, got %+v", "a b.png", mockStorage.PresignCalls)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/manifest/my+dir/checksum", nil)
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+	if len(mockStorage.ListCalls) != 1 || mockStorage.ListCalls[0] != "my dir" {
+		t.Errorf("Expected a listing of %q, got %q", "my dir", mockStorage.ListCalls)
+	}
+}
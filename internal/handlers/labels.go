@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Cache results recorded for metrics
+const (
+	cacheResultHit      = "hit"
+	cacheResultMiss     = "miss"
+	cacheResultDisabled = "disabled"
+)
+
+// requestLabelsKey is the context key for a request's *requestLabels
+type requestLabelsKey struct{}
+
+// requestLabels collects metric labels discovered while a request is
+// handled, for the metrics middleware to read afterwards
+type requestLabels struct {
+	mu    sync.Mutex
+	cache string
+}
+
+func (l *requestLabels) cacheResult() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cache
+}
+
+// recordCacheResult notes how the request's object was served. Only the
+// first result counts, so a 404 page fetched afterwards doesn't replace
+// the result for the requested file.
+func recordCacheResult(ctx context.Context, result string) {
+	labels, ok := ctx.Value(requestLabelsKey{}).(*requestLabels)
+	if !ok {
+		return
+	}
+
+	labels.mu.Lock()
+	defer labels.mu.Unlock()
+	if labels.cache == "" {
+		labels.cache = result
+	}
+}
+
+// routeTemplate returns the route pattern the request matched, without its
+// method, e.g. "/files/{name}"
+func routeTemplate(r *http.Request) string {
+	pattern := r.Pattern
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = path
+	}
+	if pattern == "" {
+		return "unmatched"
+	}
+	return pattern
+}
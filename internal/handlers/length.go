@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// errLengthMismatch means storage sent a different number of bytes than
+// its Content-Length announced, e.g. because the transfer was cut short
+var errLengthMismatch = errors.New("object body doesn't match its Content-Length")
+
+// WithContentLengthCheck sets whether object bodies read from storage are
+// checked against the Content-Length storage reported for them. A body
+// that comes up short or runs long fails the request with 500 instead of
+// serving, and caching, a partial file. A streamed body that turns out
+// short can only be cut off, since its headers are already sent.
+func WithContentLengthCheck(enabled bool) Option {
+	return func(h *FileHandler) {
+		h.lengthCheck = enabled
+	}
+}
+
+// checkLength returns an error, and logs the discrepancy, when got bytes
+// were read of key where want were announced
+func (h *FileHandler) checkLength(ctx context.Context, key string, got, want int64) error {
+	if !h.lengthCheck || got == want {
+		return nil
+	}
+	slog.ErrorContext(ctx, "Object body length doesn't match Content-Length",
+		"filename", key, "expected", want, "actual", got)
+	return fmt.Errorf("%w: %s: expected %d bytes, got %d", errLengthMismatch, key, want, got)
+}
+
+// checkedBody wraps body so reading it fails once it is known not to hold
+// exactly want bytes
+func (h *FileHandler) checkedBody(ctx context.Context, key string, body io.ReadCloser, want int64) io.ReadCloser {
+	if !h.lengthCheck {
+		return body
+	}
+	return &lengthCheckedBody{ReadCloser: body, h: h, ctx: ctx, key: key, want: want}
+}
+
+// lengthCheckedBody counts the bytes read from a storage body, turning an
+// early EOF or extra bytes into errLengthMismatch
+type lengthCheckedBody struct {
+	io.ReadCloser
+	h    *FileHandler
+	ctx  context.Context
+	key  string
+	want int64
+	read int64
+}
+
+func (b *lengthCheckedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.want || (err == io.EOF && b.read != b.want) {
+		return n, b.h.checkLength(b.ctx, b.key, b.read, b.want)
+	}
+	return n, err
+}
@@ -0,0 +1,124 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// shortStorage delivers short bytes fewer than the Content-Length it
+// reports, like a transfer cut short
+type shortStorage struct {
+	*mocks.MockStorage
+	short int
+}
+
+func (s *shortStorage) GetObjectWithInfo(ctx context.Context, key string) ([]byte, storage.ObjectInfo, error) {
+	data, info, err := s.MockStorage.GetObjectWithInfo(ctx, key)
+	if err != nil {
+		return nil, info, err
+	}
+	return data[:len(data)-s.short], info, nil
+}
+
+func (s *shortStorage) GetObjectStream(ctx context.Context, key string) (io.ReadCloser, storage.ObjectInfo, error) {
+	data, info, err := s.GetObjectWithInfo(ctx, key)
+	if err != nil {
+		return nil, info, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), info, nil
+}
+
+func (s *shortStorage) GetObjectRange(ctx context.Context, key string, start, length int64) (io.ReadCloser, storage.ObjectInfo, error) {
+	body, info, err := s.MockStorage.GetObjectRange(ctx, key, start, length)
+	if err != nil {
+		return nil, info, err
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	return io.NopCloser(bytes.NewReader(data[:len(data)-s.short])), info, nil
+}
+
+func TestGetFile_ContentLengthMismatch(t *testing.T) {
+	small := strings.Repeat("x", 20)
+	tests := []struct {
+		name   string
+		opts   []handlers.Option
+		header http.Header
+	}{
+		{"buffered", nil, nil},
+		{"read from a stream", []handlers.Option{handlers.WithMaxBufferedSize(50)}, nil},
+		{"shared range", []handlers.Option{handlers.WithMaxBufferedSize(10), handlers.WithRangeCoalescing(10)},
+			http.Header{"Range": {"bytes=0-9"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCache := mocks.NewMockCache()
+			mockStorage := &shortStorage{MockStorage: mocks.NewMockStorage(), short: 3}
+			mockStorage.SetObject("a.bin", []byte(small))
+			handler := handlers.NewFileHandler(mockCache, mockStorage, tt.opts...)
+
+			rec := getFileWithHeader(handler, "a.bin", tt.header)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+			}
+			time.Sleep(20 * time.Millisecond)
+			if mockCache.SetCallCount() != 0 {
+				t.Errorf("Expected the truncated body not to be cached, got %d sets", mockCache.SetCallCount())
+			}
+		})
+	}
+}
+
+func TestGetFile_ContentLengthMismatch_StreamCutOff(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	tests := []struct {
+		name      string
+		header    http.Header
+		wantBytes string
+	}{
+		{"whole object", nil, "100"},
+		{"range", http.Header{"Range": {"bytes=0-59"}}, "60"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &shortStorage{MockStorage: mocks.NewMockStorage(), short: 3}
+			mockStorage.SetObject("a.bin", []byte(body))
+			handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithMaxBufferedSize(50))
+
+			rec := getFileWithHeader(handler, "a.bin", tt.header)
+
+			// Headers went out before the body fell short
+			if got := rec.Header().Get("Content-Length"); got != tt.wantBytes {
+				t.Errorf("Expected the announced Content-Length %s, got %q", tt.wantBytes, got)
+			}
+			if got := strconv.Itoa(rec.Body.Len()); got == tt.wantBytes {
+				t.Errorf("Expected the body to be cut off, got %s bytes", got)
+			}
+		})
+	}
+}
+
+func TestGetFile_ContentLengthCheckDisabled(t *testing.T) {
+	mockStorage := &shortStorage{MockStorage: mocks.NewMockStorage(), short: 3}
+	mockStorage.SetObject("a.bin", []byte("0123456789"))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithContentLengthCheck(false))
+
+	rec := getFile(handler, "a.bin")
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456" {
+		t.Errorf("Expected the short body served as is, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/metrics"
+)
+
+// errConcurrencyLimit is returned when a request arrives while the adaptive
+// concurrency limit is reached
+var errConcurrencyLimit = fmt.Errorf("%w: concurrency limit reached", errLoadShed)
+
+// WithAdaptiveConcurrency bounds the file fetches in flight at once. The
+// limit starts at maxLimit and adapts to the observed fetch latency: while
+// it stays under target the limit grows back by about one per limit
+// requests, and when it rises above target the limit is cut in proportion
+// (by at most half, and at most once per target interval), but never below
+// minLimit. Requests over the limit are rejected with 503 instead of
+// queueing until they time out. A maxLimit of 0 disables the limit.
+func WithAdaptiveConcurrency(minLimit, maxLimit int, target time.Duration) Option {
+	return func(h *FileHandler) {
+		if maxLimit <= 0 || target <= 0 {
+			h.limiter = nil
+			return
+		}
+		minLimit = max(1, min(minLimit, maxLimit))
+		h.limiter = &concurrencyLimiter{
+			min:    float64(minLimit),
+			max:    float64(maxLimit),
+			target: target,
+			limit:  float64(maxLimit),
+		}
+	}
+}
+
+// concurrencyLimiter is an AIMD limiter on in-flight fetches. By Little's
+// Law the concurrency a backend sustains at the target latency is its
+// throughput times that latency, so when latency doubles the limit halves.
+type concurrencyLimiter struct {
+	min, max float64
+	target   time.Duration
+
+	mu        sync.Mutex
+	limit     float64
+	inflight  int
+	decreased time.Time
+}
+
+// acquire reserves a slot, reporting false when the limit is reached. A
+// successful acquire must be followed by release.
+func (l *concurrencyLimiter) acquire() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inflight >= int(l.limit) {
+		return false
+	}
+	l.inflight++
+	return true
+}
+
+// release frees a slot and adjusts the limit for a fetch that took latency
+func (l *concurrencyLimiter) release(now time.Time, latency time.Duration, m metrics.Metrics) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inflight--
+	switch {
+	case latency > l.target:
+		// Slow fetches complete in bursts; react to the first of each
+		if now.Sub(l.decreased) < l.target {
+			return
+		}
+		l.decreased = now
+		ratio := math.Max(0.5, float64(l.target)/float64(latency))
+		l.limit = math.Max(l.min, l.limit*ratio)
+	default:
+		l.limit = math.Min(l.max, l.limit+1/l.limit)
+	}
+	m.SetGauge(metrics.ConcurrencyLimit, math.Floor(l.limit), nil)
+}
@@ -0,0 +1,101 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/metrics"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// slowStorage advances clk by delay on every fetch
+type slowStorage struct {
+	*mocks.MockStorage
+	clk   *mocks.MockClock
+	delay time.Duration
+}
+
+func (s *slowStorage) GetObjectWithInfo(ctx context.Context, key string) ([]byte, storage.ObjectInfo, error) {
+	s.clk.Advance(s.delay)
+	return s.MockStorage.GetObjectWithInfo(ctx, key)
+}
+
+// stalledStorage blocks fetches until release is closed
+type stalledStorage struct {
+	*mocks.MockStorage
+	started atomic.Int32
+	release chan struct{}
+}
+
+func (s *stalledStorage) GetObjectWithInfo(ctx context.Context, key string) ([]byte, storage.ObjectInfo, error) {
+	s.started.Add(1)
+	<-s.release
+	return s.MockStorage.GetObjectWithInfo(ctx, key)
+}
+
+func TestGetFile_AdaptiveConcurrency_ShedsOverLimit(t *testing.T) {
+	mockStorage := &stalledStorage{MockStorage: mocks.NewMockStorage(), release: make(chan struct{})}
+	mockStorage.SetObject("file.txt", []byte("content"))
+	m := mocks.NewMockMetrics()
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithMetrics(m),
+		handlers.WithAdaptiveConcurrency(1, 1, time.Second),
+	)
+
+	done := make(chan int)
+	go func() { done <- getFile(handler, "file.txt").Code }()
+	waitFor(t, func() bool { return mockStorage.started.Load() == 1 })
+
+	rec := getFile(handler, "file.txt")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+	if shed := m.Counter(metrics.ConcurrencyShedTotal, nil); shed != 1 {
+		t.Errorf("Expected 1 shed request, got %v", shed)
+	}
+
+	close(mockStorage.release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("Expected in-flight request to succeed with %d, got %d", http.StatusOK, code)
+	}
+	if rec := getFile(handler, "file.txt"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d once the slot is free, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestGetFile_AdaptiveConcurrency_FollowsLatency(t *testing.T) {
+	clk := mocks.NewMockClock(time.Unix(1_700_000_000, 0))
+	mockStorage := &slowStorage{MockStorage: mocks.NewMockStorage(), clk: clk, delay: 400 * time.Millisecond}
+	mockStorage.SetObject("file.txt", []byte("content"))
+	m := mocks.NewMockMetrics()
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithClock(clk),
+		handlers.WithMetrics(m),
+		handlers.WithAdaptiveConcurrency(2, 8, 100*time.Millisecond),
+	)
+
+	// Each fetch at 4x the target cuts the limit by at most half
+	for _, want := range []float64{4, 2, 2} {
+		getFile(handler, "file.txt")
+		if got := m.Gauge(metrics.ConcurrencyLimit, nil); got != want {
+			t.Fatalf("Expected limit %v under slow fetches, got %v", want, got)
+		}
+	}
+
+	// Fast fetches grow it back by about one per limit requests
+	mockStorage.delay = 0
+	for _, want := range []float64{2, 2, 3} {
+		getFile(handler, "file.txt")
+		if got := m.Gauge(metrics.ConcurrencyLimit, nil); got != want {
+			t.Fatalf("Expected limit %v after recovery, got %v", want, got)
+		}
+	}
+}
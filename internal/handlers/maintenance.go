@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultMaintenanceMessage is sent when no message is configured
+	defaultMaintenanceMessage = "Service is under maintenance, please retry later"
+
+	// defaultMaintenanceRetryAfter is sent as Retry-After during maintenance
+	defaultMaintenanceRetryAfter = 5 * time.Minute
+)
+
+// errMaintenance is returned for storage reads refused during maintenance
+var errMaintenance = errors.New("storage unavailable during maintenance")
+
+// WithMaintenanceMode configures maintenance mode. While it is on, routes
+// wrapped with Maintenance answer 503 with message and a Retry-After of
+// retryAfter instead of reaching storage. With serveCached, file requests
+// are still served from the cache and only misses get the 503. enabled
+// sets whether the service starts in maintenance; it can be switched at
+// runtime with SetMaintenance. An empty message or retryAfter below 1s
+// keeps the defaults.
+func WithMaintenanceMode(enabled bool, message string, retryAfter time.Duration, serveCached bool) Option {
+	return func(h *FileHandler) {
+		h.maintenance.on.Store(enabled)
+		if message != "" {
+			h.maintenance.message = message
+		}
+		if retryAfter >= time.Second {
+			h.maintenance.retryAfter = retryAfter
+		}
+		h.maintenance.serveCached = serveCached
+	}
+}
+
+// maintenanceMode is the maintenance state and how requests are answered
+// while it is on
+type maintenanceMode struct {
+	on          atomic.Bool
+	message     string
+	retryAfter  time.Duration
+	serveCached bool
+}
+
+// Maintenance wraps a data route so it answers 503 while maintenance mode
+// is on. Routes that read objects through the cache pass cached as true,
+// which lets them keep serving cache hits when maintenance is configured
+// to serve cached objects.
+func (h *FileHandler) Maintenance(next http.HandlerFunc, cached bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.maintenance.on.Load() && !(cached && h.maintenance.serveCached && h.cache != nil) {
+			h.writeMaintenance(w)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// writeMaintenance responds with the maintenance 503
+func (h *FileHandler) writeMaintenance(w http.ResponseWriter) {
+	writeUnavailable(w, withRetryAfter(errMaintenance, h.maintenance.retryAfter), h.maintenance.message, nil)
+}
+
+// checkMaintenance returns errMaintenance if storage must not be read
+// because maintenance mode is on
+func (h *FileHandler) checkMaintenance(key string) error {
+	if h.maintenance.on.Load() {
+		slog.Info("Refusing storage read during maintenance", "filename", key)
+		return errMaintenance
+	}
+	return nil
+}
+
+// maintenanceRequest is the body of a maintenance toggle request
+type maintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// SetMaintenance handles requests to switch maintenance mode on or off at
+// runtime, with a body of {"enabled": true} or {"enabled": false}. The
+// switch applies to this instance only and lasts until it restarts.
+func (h *FileHandler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req maintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if req.Enabled == nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: "enabled is required",
+		})
+		return
+	}
+
+	was := h.maintenance.on.Swap(*req.Enabled)
+	if was != *req.Enabled {
+		slog.Warn("Maintenance mode switched", "enabled", *req.Enabled)
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: "Maintenance mode updated",
+		Data:    map[string]bool{"enabled": *req.Enabled},
+	})
+}
@@ -0,0 +1,162 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func setMaintenance(handler *handlers.FileHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/admin/maintenance", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.SetMaintenance(rec, req)
+	return rec
+}
+
+func TestMaintenance_RejectsDataRoutes(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mockStorage,
+		handlers.WithMaintenanceMode(true, "Back at 14:00 UTC", 10*time.Minute, false))
+
+	rec := getFile(handler, "a.txt")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "600" {
+		t.Errorf("Expected Retry-After 600, got %q", got)
+	}
+	if resp := parseResponse(t, rec.Body.Bytes()); resp.Message != "Back at 14:00 UTC" {
+		t.Errorf("Expected maintenance message, got %q", resp.Message)
+	}
+	if len(mockStorage.GetCalls) != 0 {
+		t.Errorf("Expected no storage reads, got %v", mockStorage.GetCalls)
+	}
+
+	// Probes stay healthy so pods aren't restarted or pulled from rotation
+	for _, probe := range []http.HandlerFunc{handler.Health, handler.Ready} {
+		rec := httptest.NewRecorder()
+		probe(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected probe status %d, got %d", http.StatusOK, rec.Code)
+		}
+	}
+}
+
+func TestMaintenance_ServeCached(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	mockStorage.SetObject("b.txt", []byte("world"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithMaintenanceMode(false, "", 0, true))
+
+	getFile(handler, "a.txt")
+	waitFor(t, func() bool { return cached(mockCache, "a.txt") })
+	setMaintenance(handler, `{"enabled":true}`)
+
+	if rec := getFile(handler, "a.txt"); rec.Code != http.StatusOK {
+		t.Errorf("Expected cache hit status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	rec := getFile(handler, "b.txt")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected cache miss status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "300" {
+		t.Errorf("Expected default Retry-After 300, got %q", got)
+	}
+	if got := len(mockStorage.GetCalls); got != 1 {
+		t.Errorf("Expected only the read before maintenance, got %d", got)
+	}
+}
+
+func TestSetMaintenance(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantFile   int
+	}{
+		{"enable", `{"enabled":true}`, http.StatusOK, http.StatusServiceUnavailable},
+		{"missing field", `{}`, http.StatusBadRequest, http.StatusServiceUnavailable},
+		{"disable", `{"enabled":false}`, http.StatusOK, http.StatusOK},
+		{"invalid body", `{`, http.StatusBadRequest, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := setMaintenance(handler, tt.body); rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if rec := getFile(handler, "a.txt"); rec.Code != tt.wantFile {
+				t.Errorf("Expected file status %d, got %d", tt.wantFile, rec.Code)
+			}
+		})
+	}
+}
+
+func TestMaintenance_HealthSkipsStorage(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.HealthCheckError = errors.New("r2 is down for maintenance")
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithMaintenanceMode(true, "", 0, false))
+
+	rec := httptest.NewRecorder()
+	handler.Health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if mockStorage.HealthCheckCalls != 0 {
+		t.Errorf("Expected storage not to be probed, got %d checks", mockStorage.HealthCheckCalls)
+	}
+	data := parseResponse(t, rec.Body.Bytes()).Data
+	if data["status"] != "degraded" {
+		t.Errorf("Expected degraded status, got %v", data["status"])
+	}
+
+	// Leaving maintenance checks storage again
+	setMaintenance(handler, `{"enabled":false}`)
+	rec = httptest.NewRecorder()
+	handler.Health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d after maintenance, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
+
+func TestMaintenance_NoPrefetchOrWarm(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("intro.mp4.vtt", []byte("captions"))
+	mockCache.SetData("intro.mp4", []byte("video"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithMaintenanceMode(true, "", 0, true),
+		handlers.WithPrefetch(map[string][]string{"intro.mp4": {"intro.mp4.vtt"}}, 2),
+	)
+
+	if rec := getFile(handler, "intro.mp4"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected cache hit status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := warmCache(handler, `{"keys":["intro.mp4.vtt"]}`); !strings.Contains(rec.Body.String(), "maintenance") {
+		t.Errorf("Expected warming to be refused during maintenance, got %s", rec.Body.String())
+	}
+
+	// Give a prefetch time to show up if there were one
+	time.Sleep(20 * time.Millisecond)
+	if len(mockStorage.GetCalls) != 0 {
+		t.Errorf("Expected no storage reads during maintenance, got %v", mockStorage.GetCalls)
+	}
+	if cached(mockCache, "intro.mp4.vtt") {
+		t.Error("Expected the related key not to be warmed during maintenance")
+	}
+}
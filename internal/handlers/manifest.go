@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// defaultManifestMaxObjects caps the objects hashed by one checksum request
+const defaultManifestMaxObjects = 10000
+
+// errTooManyObjects stops a listing that passed the manifest limit
+var errTooManyObjects = errors.New("too many objects under prefix")
+
+// WithManifestMaxObjects sets how many objects a prefix checksum may cover.
+// Values below 1 keep the default.
+func WithManifestMaxObjects(n int) Option {
+	return func(h *FileHandler) {
+		if n > 0 {
+			h.manifestMaxObjects = n
+		}
+	}
+}
+
+// ManifestChecksum handles requests for an aggregate checksum of every
+// object under a prefix, so clients can tell whether anything under it
+// changed without downloading it. The checksum is the hex SHA-256 of, for
+// each object in ascending key byte order, its key, a NUL byte, its ETag
+// without quotes, a NUL byte, its size in decimal and a newline. Keys
+// blocked by the key patterns or without the required tag are left out.
+// With a required tag, each object's tag decision is looked up, costing a
+// tagging call per object not already decided in the cache.
+func (h *FileHandler) ManifestChecksum(w http.ResponseWriter, r *http.Request) {
+	prefix, err := h.decodeKey(r, "prefix")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: keyErrorMessage(err),
+		})
+		return
+	}
+
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+
+	sum := sha256.New()
+	listed, count := 0, 0
+	err = h.storage.ListObjects(ctx, prefix, func(obj storage.ListedObject) error {
+		if !h.keyAllowed(obj.Key) {
+			return nil
+		}
+		// Untagged objects count towards the limit too, so it also bounds
+		// the tagging calls
+		if listed++; listed > h.manifestMaxObjects {
+			return errTooManyObjects
+		}
+		if h.requiredTagKey != "" {
+			allowed, err := h.tagAllowed(ctx, obj.Key)
+			if err != nil && !isNotFoundError(err) {
+				return err
+			}
+			if err != nil || !allowed {
+				return nil
+			}
+		}
+		count++
+		sum.Write([]byte(obj.Key))
+		sum.Write([]byte{0})
+		sum.Write([]byte(strings.Trim(obj.ETag, `"`)))
+		sum.Write([]byte{0})
+		sum.Write([]byte(strconv.FormatInt(obj.Size, 10)))
+		sum.Write([]byte{'\n'})
+		return nil
+	})
+	if errors.Is(err, errTooManyObjects) {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Message: fmt.Sprintf("more than %d objects under prefix", h.manifestMaxObjects),
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to list prefix", "prefix", prefix, "error", err,
+			"upstream_request_id", storage.RequestID(err))
+		h.writeFetchError(ctx, w, err)
+		return
+	}
+
+	// The ETag lets tooling poll with If-None-Match
+	checksum := hex.EncodeToString(sum.Sum(nil))
+	etag := `"` + checksum + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if notModified(r, etag) {
+		writeNotModified(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]any{
+			"prefix":    prefix,
+			"algorithm": "sha256",
+			"checksum":  checksum,
+			"objects":   count,
+		},
+	})
+}
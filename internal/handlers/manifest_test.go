@@ -0,0 +1,137 @@
+package handlers_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+type checksumResponse struct {
+	Data struct {
+		Checksum string `json:"checksum"`
+		Objects  int    `json:"objects"`
+	} `json:"data"`
+}
+
+func parseChecksum(t *testing.T, rec *httptest.ResponseRecorder) checksumResponse {
+	t.Helper()
+	var resp checksumResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return resp
+}
+
+func getChecksum(handler *handlers.FileHandler, prefix, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/manifest/prefix/checksum", nil)
+	req.SetPathValue("prefix", prefix)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	rec := httptest.NewRecorder()
+	handler.ManifestChecksum(rec, req)
+	return rec
+}
+
+func newManifestStorage() *mocks.MockStorage {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("releases/b.bin", []byte("bb"))
+	mockStorage.SetObjectInfo("releases/b.bin", storage.ObjectInfo{ETag: `"etag-b"`})
+	mockStorage.SetObject("releases/a.txt", []byte("a"))
+	mockStorage.SetObjectInfo("releases/a.txt", storage.ObjectInfo{ETag: `"etag-a"`})
+	mockStorage.SetObject("other/c.txt", []byte("c"))
+	return mockStorage
+}
+
+func TestManifestChecksum_Documented(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, newManifestStorage())
+
+	rec := getChecksum(handler, "releases/", "")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	want := sha256.Sum256([]byte("releases/a.txt\x00etag-a\x001\nreleases/b.bin\x00etag-b\x002\n"))
+	resp := parseChecksum(t, rec)
+	if resp.Data.Checksum != hex.EncodeToString(want[:]) {
+		t.Errorf("Expected checksum %x, got %s", want, resp.Data.Checksum)
+	}
+	if resp.Data.Objects != 2 {
+		t.Errorf("Expected 2 objects, got %d", resp.Data.Objects)
+	}
+	if got := rec.Header().Get("ETag"); got != `"`+hex.EncodeToString(want[:])+`"` {
+		t.Errorf("Expected the checksum as ETag, got %s", got)
+	}
+}
+
+func TestManifestChecksum_ChangesWithObjects(t *testing.T) {
+	mockStorage := newManifestStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage)
+	before := getChecksum(handler, "releases/", "").Header().Get("ETag")
+
+	if again := getChecksum(handler, "releases/", "").Header().Get("ETag"); again != before {
+		t.Errorf("Expected a stable checksum, got %s then %s", before, again)
+	}
+
+	mockStorage.SetObjectInfo("releases/a.txt", storage.ObjectInfo{ETag: `"etag-a2"`})
+	if after := getChecksum(handler, "releases/", "").Header().Get("ETag"); after == before {
+		t.Error("Expected the checksum to change with an object's ETag")
+	}
+}
+
+func TestManifestChecksum_NotModified(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, newManifestStorage())
+	etag := getChecksum(handler, "releases/", "").Header().Get("ETag")
+
+	rec := getChecksum(handler, "releases/", etag)
+
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, rec.Code)
+	}
+}
+
+func TestManifestChecksum_SkipsBlockedKeys(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, newManifestStorage(),
+		handlers.WithKeyPatterns(nil, regexp.MustCompile(`\.bin$`)))
+
+	resp := parseChecksum(t, getChecksum(handler, "releases/", ""))
+
+	if resp.Data.Objects != 1 {
+		t.Errorf("Expected 1 object, got %d", resp.Data.Objects)
+	}
+}
+
+func TestManifestChecksum_SkipsUntaggedKeys(t *testing.T) {
+	mockStorage := newManifestStorage()
+	mockStorage.SetTags("releases/a.txt", map[string]string{"visibility": "public"})
+	mockStorage.SetTags("releases/b.bin", map[string]string{"visibility": "private"})
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithRequiredTag("visibility", "public"))
+
+	resp := parseChecksum(t, getChecksum(handler, "releases/", ""))
+
+	want := sha256.Sum256([]byte("releases/a.txt\x00etag-a\x001\n"))
+	if resp.Data.Checksum != hex.EncodeToString(want[:]) {
+		t.Errorf("Expected checksum %x, got %s", want, resp.Data.Checksum)
+	}
+	if resp.Data.Objects != 1 {
+		t.Errorf("Expected 1 object, got %d", resp.Data.Objects)
+	}
+}
+
+func TestManifestChecksum_TooManyObjects(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, newManifestStorage(), handlers.WithManifestMaxObjects(1))
+
+	rec := getChecksum(handler, "releases/", "")
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	appmetrics "github.com/ch374n/file-downloader/internal/metrics"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// memorySampleInterval is how long a memory reading is reused
+const memorySampleInterval = time.Second
+
+// WithMemoryShedding rejects cache misses for objects of at least
+// largeObjectBytes with 503 while the process uses more than limitBytes of
+// memory. Cache hits and smaller objects are still served. A limit of 0
+// disables the check.
+func WithMemoryShedding(limitBytes uint64, largeObjectBytes int64) Option {
+	return func(h *FileHandler) {
+		if limitBytes == 0 {
+			h.memory = nil
+			return
+		}
+		h.memory = &memoryGuard{
+			limit:       limitBytes,
+			largeObject: largeObjectBytes,
+		}
+	}
+}
+
+// memoryGuard tracks process memory usage, sampled at most once per
+// memorySampleInterval so that checks stay cheap on the request path
+type memoryGuard struct {
+	limit       uint64
+	largeObject int64
+
+	mu        sync.Mutex
+	sampledAt time.Time
+	pressure  bool
+}
+
+// underPressure reports whether memory usage is above the limit
+func (g *memoryGuard) underPressure(now time.Time, m appmetrics.Metrics) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.sampledAt) < memorySampleInterval {
+		return g.pressure
+	}
+	g.sampledAt = now
+	g.pressure = memoryInUse() > g.limit
+
+	if g.pressure {
+		m.SetGauge(appmetrics.MemoryPressure, 1, nil)
+	} else {
+		m.SetGauge(appmetrics.MemoryPressure, 0, nil)
+	}
+	return g.pressure
+}
+
+// memoryInUse returns the memory mapped by the Go runtime that hasn't been
+// returned to the OS, which tracks RSS closely without stopping the world
+func memoryInUse() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
+
+// getCheckedObject reads key from storage once checkMemory allows it. A
+// fetch reads storage only through it, so retries under recent-write and
+// fallback keys are held to the same limit, and a key checkMemory finds
+// missing still reaches them.
+func (h *FileHandler) getCheckedObject(ctx context.Context, key string) (*entry, error) {
+	if err := h.checkMemory(ctx, key); err != nil {
+		return nil, err
+	}
+	return h.getObject(ctx, key)
+}
+
+// checkMemory rejects the fetch of a large object under memory pressure.
+// The object's size is only looked up while under pressure.
+func (h *FileHandler) checkMemory(ctx context.Context, key string) error {
+	if h.memory == nil || !h.memory.underPressure(h.clock.Now(), h.metrics) {
+		return nil
+	}
+
+	info, err := h.storage.StatObject(ctx, key)
+	if err != nil {
+		if isNotFoundError(err) {
+			return err
+		}
+		// Let the fetch itself surface storage problems
+		slog.Warn("Failed to stat object under memory pressure",
+			"filename", key, "error", err, "upstream_request_id", storage.RequestID(err))
+		return nil
+	}
+
+	if info.Size >= h.memory.largeObject {
+		h.metrics.IncCounter(appmetrics.MemoryShedTotal, nil)
+		slog.Warn("Shedding large object under memory pressure", "filename", key, "size", info.Size)
+		// Pressure is re-evaluated on the next sample at the earliest
+		return withRetryAfter(fmt.Errorf("%w: memory pressure", errLoadShed), memorySampleInterval)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/cache"
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func getFile(handler *handlers.FileHandler, name string) *httptest.ResponseRecorder {
+	return getFileWithHeader(handler, name, nil)
+}
+
+// getFileWithHeader requests name with header through GetFile wrapped as
+// app mounts it, so maintenance mode applies
+func getFileWithHeader(handler *handlers.FileHandler, name string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/files/"+name, nil)
+	req.SetPathValue("name", name)
+	if header != nil {
+		req.Header = header
+	}
+	rec := httptest.NewRecorder()
+	handler.Maintenance(handler.GetFile, true)(rec, req)
+	return rec
+}
+
+// A 1-byte limit puts the handler under memory pressure permanently
+func newPressuredHandler(c cache.Cache, s *mocks.MockStorage) *handlers.FileHandler {
+	return handlers.NewFileHandler(c, s, handlers.WithMemoryShedding(1, 100))
+}
+
+func TestGetFile_MemoryPressure_ShedsLargeObjects(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("large.bin", make([]byte, 100))
+	handler := newPressuredHandler(nil, mockStorage)
+
+	rec := getFile(handler, "large.bin")
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+	if len(mockStorage.GetCalls) != 0 {
+		t.Errorf("Expected no storage get calls, got %d", len(mockStorage.GetCalls))
+	}
+}
+
+func TestGetFile_MemoryPressure_ServesSmallObjects(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("small.txt", []byte("small"))
+	handler := newPressuredHandler(nil, mockStorage)
+
+	rec := getFile(handler, "small.txt")
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Body.String() != "small" {
+		t.Errorf("Expected body 'small', got '%s'", rec.Body.String())
+	}
+}
+
+func TestGetFile_MemoryPressure_ServesCacheHits(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockCache.SetData("large.bin", make([]byte, 100))
+	handler := newPressuredHandler(mockCache, mockStorage)
+
+	rec := getFile(handler, "large.bin")
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if len(mockStorage.StatCalls) != 0 {
+		t.Errorf("Expected no storage stat calls, got %d", len(mockStorage.StatCalls))
+	}
+}
+
+func TestGetFile_MemoryPressure_MissingObject(t *testing.T) {
+	handler := newPressuredHandler(nil, mocks.NewMockStorage())
+
+	rec := getFile(handler, "missing.bin")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestGetFile_MemoryPressure_FallbackPrefixes(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		wantStatus int
+	}{
+		{"small fallback served", 5, http.StatusOK},
+		{"large fallback shed", 100, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject("old/f.bin", make([]byte, tt.size))
+			handler := handlers.NewFileHandler(nil, mockStorage,
+				handlers.WithMemoryShedding(1, 100),
+				handlers.WithFallbackPrefixes(map[string][]string{"new/": {"old/"}}),
+			)
+
+			rec := getFile(handler, "new/f.bin")
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestWarmCache_MemoryPressure_ShedsLargeObjects(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("large.bin", make([]byte, 100))
+	handler := newPressuredHandler(mockCache, mockStorage)
+
+	rec := warmCache(handler, `{"keys":["large.bin"]}`)
+
+	if !strings.Contains(rec.Body.String(), "memory pressure") {
+		t.Errorf("Expected the large object to be shed, got %s", rec.Body.String())
+	}
+	if len(mockStorage.GetCalls) != 0 {
+		t.Errorf("Expected no storage get calls, got %d", len(mockStorage.GetCalls))
+	}
+}
+
+func TestGetFile_MemoryShedding_NoPressure(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("large.bin", make([]byte, 100))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithMemoryShedding(1<<62, 100))
+
+	rec := getFile(handler, "large.bin")
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if len(mockStorage.StatCalls) != 0 {
+		t.Errorf("Expected no storage stat calls, got %d", len(mockStorage.StatCalls))
+	}
+}
+
+func TestHealthHandler_MemoryPressure(t *testing.T) {
+	handler := newPressuredHandler(nil, mocks.NewMockStorage())
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	rec := httptest.NewRecorder()
+	handler.Health(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	resp := parseResponse(t, rec.Body.Bytes())
+	if resp.Data["memory"] != "pressure" {
+		t.Errorf("Expected memory 'pressure', got '%s'", resp.Data["memory"])
+	}
+}
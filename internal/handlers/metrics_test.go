@@ -0,0 +1,74 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/metrics"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestMetricsMiddleware_RouteAndCacheLabels(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockCache.SetData("labels-test.txt", []byte("cached"))
+	handler := handlers.NewFileHandler(mockCache, mocks.NewMockStorage())
+	m := mocks.NewMockMetrics()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /files/{name}", handlers.MetricsMiddleware(m, handler.GetFile))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/labels-test.txt", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	hits := m.Counter(metrics.HTTPRequestsTotal, metrics.Labels{
+		"method": "GET", "path": "/files/{name}", "status": "200", "cache": "hit",
+	})
+	if hits != 1 {
+		t.Errorf("Expected 1 request labelled with the route template and cache hit, got %v", hits)
+	}
+	durations := m.Observations(metrics.HTTPRequestDuration, metrics.Labels{
+		"method": "GET", "path": "/files/{name}", "cache": "hit",
+	})
+	if len(durations) != 1 {
+		t.Errorf("Expected 1 duration observation, got %d", len(durations))
+	}
+}
+
+func TestMetricsMiddleware_LabelsDisabled(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, mocks.NewMockStorage())
+	m := mocks.NewMockMetrics()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /files/{name}", handlers.NewMetricsMiddleware(m, false)(handler.GetFile))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/missing.txt", nil))
+
+	unlabelled := m.Counter(metrics.HTTPRequestsTotal, metrics.Labels{
+		"method": "GET", "path": "", "status": "404", "cache": "",
+	})
+	if unlabelled != 1 {
+		t.Errorf("Expected 1 request without route or cache labels, got %v", unlabelled)
+	}
+}
+
+func TestGetFile_RecordsCacheAndStorageMetrics(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	m := mocks.NewMockMetrics()
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mockStorage, handlers.WithMetrics(m))
+
+	getFile(handler, "a.txt")
+
+	if got := m.Counter(metrics.CacheMissesTotal, nil); got != 1 {
+		t.Errorf("Expected 1 cache miss, got %v", got)
+	}
+	if got := m.Counter(metrics.R2RequestsTotal, metrics.Labels{"operation": "get", "status": "success"}); got != 1 {
+		t.Errorf("Expected 1 successful R2 get, got %v", got)
+	}
+}
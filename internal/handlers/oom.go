@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// oomLogInterval is the least time between logged Redis out-of-memory
+// errors; the ones in between are only counted
+const oomLogInterval = 30 * time.Second
+
+// WithCacheOOMCooldown stops cache writes for cooldown once Redis reports
+// it is out of memory, leaving the cache read-only until memory frees up.
+// Cache hits are still served meanwhile. 0 keeps attempting every write.
+func WithCacheOOMCooldown(cooldown time.Duration) Option {
+	return func(h *FileHandler) {
+		h.cacheOOM.cooldown = cooldown
+	}
+}
+
+// oomGuard tracks Redis out-of-memory failures on cache writes
+type oomGuard struct {
+	cooldown time.Duration
+
+	mu            sync.Mutex
+	readOnlyUntil time.Time
+	lastLogged    time.Time
+	suppressed    int
+}
+
+// readOnly reports whether cache writes are paused after an OOM error
+func (g *oomGuard) readOnly(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return now.Before(g.readOnlyUntil)
+}
+
+// record notes a write of key that failed with an OOM error, starting the
+// cooldown and logging at most once per oomLogInterval
+func (g *oomGuard) record(ctx context.Context, now time.Time, key string, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.cooldown > 0 {
+		g.readOnlyUntil = now.Add(g.cooldown)
+	}
+
+	if !g.lastLogged.IsZero() && now.Sub(g.lastLogged) < oomLogInterval {
+		g.suppressed++
+		return
+	}
+	slog.WarnContext(ctx, "Redis is out of memory, cache writes are failing",
+		"filename", key,
+		"error", err,
+		"suppressed", g.suppressed,
+		"read_only_for", g.cooldown,
+	)
+	g.lastLogged = now
+	g.suppressed = 0
+}
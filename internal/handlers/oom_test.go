@@ -0,0 +1,78 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+// oomError is Redis's reply when maxmemory is reached under noeviction
+type oomError struct{}
+
+func (oomError) Error() string { return "OOM command not allowed when used memory > 'maxmemory'." }
+func (oomError) RedisError()   {}
+
+func redisHealth(t *testing.T, handler *handlers.FileHandler) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.Health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	return parseResponse(t, rec.Body.Bytes()).Data["redis"]
+}
+
+func TestCacheOOMCooldown(t *testing.T) {
+	clk := mocks.NewMockClock(time.Unix(1000, 0))
+	mockCache := mocks.NewMockCache()
+	mockCache.SetError = oomError{}
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("a"))
+	mockStorage.SetObject("b.txt", []byte("b"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithClock(clk),
+		handlers.WithCacheOOMCooldown(time.Minute),
+	)
+
+	getFile(handler, "a.txt")
+	waitFor(t, func() bool { return redisHealth(t, handler) != "healthy" })
+
+	if got := redisHealth(t, handler); got != "degraded: read-only after running out of memory" {
+		t.Errorf("Expected redis to be reported degraded, got '%s'", got)
+	}
+
+	// Writes are skipped during the cooldown, but files are still served
+	rec := getFile(handler, "b.txt")
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if mockCache.SetCallCount() != 1 {
+		t.Errorf("Expected no cache write during the cooldown, got %d", mockCache.SetCallCount())
+	}
+
+	clk.Advance(time.Minute)
+	if got := redisHealth(t, handler); got != "healthy" {
+		t.Errorf("Expected redis healthy after the cooldown, got '%s'", got)
+	}
+	getFile(handler, "b.txt")
+	waitFor(t, func() bool { return mockCache.SetCallCount() == 2 })
+}
+
+func TestCacheOOM_NoCooldownKeepsWriting(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockCache.SetError = oomError{}
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("a"))
+	mockStorage.SetObject("b.txt", []byte("b"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage)
+
+	getFile(handler, "a.txt")
+	waitFor(t, func() bool { return mockCache.SetCallCount() == 1 })
+	getFile(handler, "b.txt")
+	waitFor(t, func() bool { return mockCache.SetCallCount() == 2 })
+
+	if got := redisHealth(t, handler); got != "healthy" {
+		t.Errorf("Expected redis healthy without a cooldown, got '%s'", got)
+	}
+}
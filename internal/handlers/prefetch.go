@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/ch374n/file-downloader/internal/metrics"
+)
+
+// defaultPrefetchConcurrency is how many related keys are prefetched at once
+const defaultPrefetchConcurrency = 2
+
+// WithPrefetch warms related keys into the cache in the background whenever
+// a key in related is served, e.g. a video's captions after the video. At
+// most concurrency prefetches run at once; further ones are skipped rather
+// than queued, so prefetch never backs up behind slow storage. An empty map
+// disables prefetch; concurrency below 1 keeps the default.
+func WithPrefetch(related map[string][]string, concurrency int) Option {
+	return func(h *FileHandler) {
+		if len(related) == 0 {
+			return
+		}
+		if concurrency < 1 {
+			concurrency = defaultPrefetchConcurrency
+		}
+		h.prefetch = &prefetcher{
+			related: related,
+			sem:     make(chan struct{}, concurrency),
+		}
+	}
+}
+
+// prefetcher warms the keys configured as related to a served key
+type prefetcher struct {
+	related map[string][]string
+	sem     chan struct{}
+
+	// inFlight holds the keys being prefetched, so concurrent serves of the
+	// same key don't fetch its related keys twice
+	inFlight sync.Map
+}
+
+// prefetchRelated starts warming the keys related to key that aren't
+// cached yet. It returns without waiting for them. Nothing is prefetched
+// during maintenance, when cache hits may still be served.
+func (h *FileHandler) prefetchRelated(key string) {
+	if h.prefetch == nil || h.cache == nil || h.maintenance.on.Load() {
+		return
+	}
+
+	for _, related := range h.prefetch.related[key] {
+		if _, loaded := h.prefetch.inFlight.LoadOrStore(related, struct{}{}); loaded {
+			continue
+		}
+		select {
+		case h.prefetch.sem <- struct{}{}:
+		default:
+			h.prefetch.inFlight.Delete(related)
+			h.metrics.IncCounter(metrics.PrefetchTotal, metrics.Labels{"status": "skipped"})
+			continue
+		}
+
+		go func() {
+			defer h.prefetch.inFlight.Delete(related)
+			defer func() { <-h.prefetch.sem }()
+			h.metrics.IncCounter(metrics.PrefetchTotal, metrics.Labels{"status": h.prefetchKey(related)})
+		}()
+	}
+}
+
+// prefetchKey warms key unless it is already cached, and returns the
+// outcome as a metric status
+func (h *FileHandler) prefetchKey(key string) string {
+	// The request that triggered the prefetch may already be done
+	ctx := context.Background()
+
+	if _, found, err := h.cache.Get(ctx, key); err == nil && found {
+		return "cached"
+	}
+	if err := h.warmKey(ctx, key); err != nil {
+		slog.Warn("Failed to prefetch related key", "filename", key, "error", err)
+		return "error"
+	}
+	slog.Info("Prefetched related key", "filename", key)
+	return "warmed"
+}
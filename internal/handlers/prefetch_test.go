@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/metrics"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func cached(c *mocks.MockCache, key string) bool {
+	_, found, _ := c.Get(context.Background(), key)
+	return found
+}
+
+func TestGetFile_Prefetch_WarmsRelatedKeys(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("intro.mp4", []byte("video"))
+	mockStorage.SetObject("intro.mp4.vtt", []byte("captions"))
+	mockStorage.SetObject("intro.jpg", []byte("poster"))
+	m := mocks.NewMockMetrics()
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithMetrics(m),
+		handlers.WithPrefetch(map[string][]string{"intro.mp4": {"intro.mp4.vtt", "intro.jpg"}}, 2),
+	)
+
+	if rec := getFile(handler, "intro.mp4"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	waitFor(t, func() bool { return cached(mockCache, "intro.mp4.vtt") && cached(mockCache, "intro.jpg") })
+	waitFor(t, func() bool { return m.Counter(metrics.PrefetchTotal, metrics.Labels{"status": "warmed"}) == 2 })
+
+	hits := m.Counter(metrics.CacheHitsTotal, nil)
+	getFile(handler, "intro.mp4.vtt")
+	if got := m.Counter(metrics.CacheHitsTotal, nil); got != hits+1 {
+		t.Error("Expected prefetched key to be a cache hit")
+	}
+}
+
+func TestGetFile_Prefetch_SkipsCachedKeys(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("intro.mp4", []byte("video"))
+	mockStorage.SetObject("intro.mp4.vtt", []byte("captions"))
+	m := mocks.NewMockMetrics()
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithMetrics(m),
+		handlers.WithPrefetch(map[string][]string{"intro.mp4": {"intro.mp4.vtt"}}, 2),
+	)
+	getFile(handler, "intro.mp4.vtt")
+	waitFor(t, func() bool { return cached(mockCache, "intro.mp4.vtt") })
+	sets := mockCache.SetCallCount()
+
+	getFile(handler, "intro.mp4")
+	waitFor(t, func() bool { return m.Counter(metrics.PrefetchTotal, metrics.Labels{"status": "cached"}) == 1 })
+
+	// Only the video itself is written back on its miss
+	waitFor(t, func() bool { return cached(mockCache, "intro.mp4") })
+	if got := mockCache.SetCallCount(); got != sets+1 {
+		t.Errorf("Expected 1 more cache write, got %d", got-sets)
+	}
+}
+
+func TestGetFile_Prefetch_SkipsOverConcurrency(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := &stalledStorage{MockStorage: mocks.NewMockStorage(), release: make(chan struct{})}
+	mockStorage.SetObject("a.jpg", []byte("a"))
+	mockStorage.SetObject("b.jpg", []byte("b"))
+	m := mocks.NewMockMetrics()
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithMetrics(m),
+		handlers.WithPrefetch(map[string][]string{"page.html": {"a.jpg", "b.jpg"}}, 1),
+	)
+	// Serve the trigger from the cache so only prefetches reach storage
+	mockCache.SetData("page.html", []byte("page"))
+
+	if rec := getFile(handler, "page.html"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	waitFor(t, func() bool { return mockStorage.started.Load() == 1 })
+
+	if skipped := m.Counter(metrics.PrefetchTotal, metrics.Labels{"status": "skipped"}); skipped != 1 {
+		t.Errorf("Expected 1 skipped prefetch, got %v", skipped)
+	}
+
+	close(mockStorage.release)
+	waitFor(t, func() bool { return m.Counter(metrics.PrefetchTotal, metrics.Labels{"status": "warmed"}) == 1 })
+}
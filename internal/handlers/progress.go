@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxProgressTokenLength caps client-provided progress tokens
+	maxProgressTokenLength = 128
+
+	// maxTrackedDownloads caps how many downloads report progress at once;
+	// further ones are served untracked
+	maxTrackedDownloads = 10000
+
+	// progressRetention is how long a finished download's progress stays
+	// available, for subscribers that poll just after it ends
+	progressRetention = time.Minute
+
+	// progressWaitTimeout is how long a progress stream waits for its
+	// download to start before giving up
+	progressWaitTimeout = 30 * time.Second
+)
+
+// WithDownloadProgress lets clients follow streamed downloads over
+// Server-Sent Events. A download requested with ?progress=<token> publishes
+// how many bytes were sent under the token, and
+// GET /files/{name}/progress?token=<token> reports it every interval.
+// Only objects over the max buffered size, which are written in chunks,
+// are tracked. 0 disables progress reporting.
+func WithDownloadProgress(interval time.Duration) Option {
+	return func(h *FileHandler) {
+		if interval > 0 {
+			h.progress = &progressRegistry{
+				interval:  interval,
+				downloads: make(map[string]*downloadProgress),
+			}
+		}
+	}
+}
+
+// progressRegistry holds the progress of tracked downloads by token
+type progressRegistry struct {
+	interval time.Duration
+
+	mu        sync.Mutex
+	downloads map[string]*downloadProgress
+}
+
+// downloadProgress is the progress of one streamed download
+type downloadProgress struct {
+	key   string
+	total int64
+	sent  atomic.Int64
+	done  atomic.Bool
+}
+
+// track registers a download of key under token, replacing any earlier
+// download with the same token. It returns nil when too many downloads
+// are tracked already.
+func (p *progressRegistry) track(token, key string, total int64) *downloadProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.downloads[token]; !ok && len(p.downloads) >= maxTrackedDownloads {
+		return nil
+	}
+	d := &downloadProgress{key: key, total: total}
+	p.downloads[token] = d
+	return d
+}
+
+// finish marks d done and forgets it after progressRetention
+func (p *progressRegistry) finish(token string, d *downloadProgress) {
+	d.done.Store(true)
+	time.AfterFunc(progressRetention, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.downloads[token] == d {
+			delete(p.downloads, token)
+		}
+	})
+}
+
+// lookup returns the download tracked under token, or nil
+func (p *progressRegistry) lookup(token string) *downloadProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.downloads[token]
+}
+
+// progressWriter counts the bytes written through it into progress
+type progressWriter struct {
+	http.ResponseWriter
+	progress *downloadProgress
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.ResponseWriter.Write(b)
+	pw.progress.sent.Add(int64(n))
+	return n, err
+}
+
+// writeTrackedStream streams body like writeStream, publishing progress
+// under the request's progress token if it has one
+func (h *FileHandler) writeTrackedStream(w http.ResponseWriter, r *http.Request, filename, contentType string, body io.Reader, size int64) {
+	token := r.URL.Query().Get("progress")
+	if h.progress == nil || token == "" || len(token) > maxProgressTokenLength {
+		h.writeStream(w, filename, contentType, body, size)
+		return
+	}
+
+	d := h.progress.track(token, filename, size)
+	if d == nil {
+		slog.Warn("Too many tracked downloads, serving untracked", "filename", filename)
+		h.writeStream(w, filename, contentType, body, size)
+		return
+	}
+	defer h.progress.finish(token, d)
+	h.writeStream(&progressWriter{ResponseWriter: w, progress: d}, filename, contentType, body, size)
+}
+
+// progressEvent is the data of a progress Server-Sent Event
+type progressEvent struct {
+	BytesSent int64 `json:"bytes_sent"`
+	Total     int64 `json:"total"`
+}
+
+// DownloadProgress handles requests to follow a streamed download as
+// Server-Sent Events. It sends a "progress" event with bytes_sent and
+// total every interval, and a final "done" event once the download
+// finishes. Until the download starts, comments keep the connection open.
+func (h *FileHandler) DownloadProgress(w http.ResponseWriter, r *http.Request) {
+	filename, err := h.decodeKey(r, "name")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: keyErrorMessage(err),
+		})
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" || len(token) > maxProgressTokenLength {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: fmt.Sprintf("a token of up to %d characters is required", maxProgressTokenLength),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Stop proxies such as nginx from buffering events
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	waitUntil := h.clock.Now().Add(progressWaitTimeout)
+	for {
+		var err error
+		d := h.progress.lookup(token)
+		switch {
+		case d != nil && d.key == filename:
+			event := "progress"
+			if d.done.Load() {
+				event = "done"
+			}
+			err = writeEvent(w, event, progressEvent{BytesSent: d.sent.Load(), Total: d.total})
+			if err == nil && event == "done" {
+				rc.Flush()
+				return
+			}
+		case h.clock.Now().After(waitUntil):
+			writeEvent(w, "timeout", nil)
+			rc.Flush()
+			return
+		default:
+			_, err = io.WriteString(w, ": waiting\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			slog.Debug("Progress stream closed", "filename", filename, "error", err)
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.clock.After(h.progress.interval):
+		}
+	}
+}
+
+// writeEvent writes one Server-Sent Event with data encoded as JSON
+func writeEvent(w io.Writer, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
@@ -0,0 +1,121 @@
+package handlers_test
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func newProgressServer(t *testing.T, s *mocks.MockStorage) *httptest.Server {
+	t.Helper()
+	handler := handlers.NewFileHandler(nil, s,
+		handlers.WithMaxBufferedSize(16),
+		handlers.WithDownloadProgress(5*time.Millisecond),
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /files/{name}", handler.GetFile)
+	mux.HandleFunc("GET /files/{name}/progress", handler.DownloadProgress)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// readEvents reads Server-Sent Events from body until one named last,
+// returning the events seen as "name data" lines
+func readEvents(t *testing.T, body io.Reader, last string) []string {
+	t.Helper()
+	var events []string
+	var name string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			events = append(events, name+" "+strings.TrimPrefix(line, "data: "))
+			if name == last {
+				return events
+			}
+		}
+	}
+	t.Fatalf("Stream ended before a %q event, got %v", last, events)
+	return nil
+}
+
+func TestDownloadProgress_ReportsStreamedDownload(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("video.mp4", make([]byte, 100))
+	srv := newProgressServer(t, mockStorage)
+
+	// Subscribe before the download starts
+	events, err := http.Get(srv.URL + "/files/video.mp4/progress?token=tok1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Body.Close()
+	if got := events.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %q", got)
+	}
+
+	download, err := http.Get(srv.URL + "/files/video.mp4?progress=tok1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, download.Body)
+	download.Body.Close()
+
+	got := readEvents(t, events.Body, "done")
+	if want := `done {"bytes_sent":100,"total":100}`; got[len(got)-1] != want {
+		t.Errorf("Expected final event %q, got %q", want, got[len(got)-1])
+	}
+}
+
+func TestDownloadProgress_IgnoresOtherFiles(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.bin", make([]byte, 100))
+	mockStorage.SetObject("b.bin", make([]byte, 200))
+	srv := newProgressServer(t, mockStorage)
+
+	download, err := http.Get(srv.URL + "/files/a.bin?progress=tok2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, download.Body)
+	download.Body.Close()
+
+	// The token belongs to a.bin, so b.bin's stream keeps waiting
+	events, err := http.Get(srv.URL + "/files/b.bin/progress?token=tok2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Body.Close()
+	line, err := bufio.NewReader(events.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != ": waiting\n" {
+		t.Errorf("Expected a waiting comment, got %q", line)
+	}
+}
+
+func TestDownloadProgress_RequiresToken(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, mocks.NewMockStorage(),
+		handlers.WithDownloadProgress(time.Second))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/a.bin/progress", nil)
+	req.SetPathValue("name", "a.bin")
+	rec := httptest.NewRecorder()
+	handler.DownloadProgress(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
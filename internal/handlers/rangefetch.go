@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// rangeRequestKey is the context key for the Range header of a request
+type rangeRequestKey struct{}
+
+// rangeRequest is a Range header asked for on key
+type rangeRequest struct {
+	key    string
+	header string
+}
+
+// withRangeRequest returns ctx carrying r's Range header for key, when the
+// range could be fetched from storage on its own. Requests with If-Range
+// need the object's ETag before the range can be trusted, and base64
+// responses encode the whole object, so neither qualifies.
+func (h *FileHandler) withRangeRequest(ctx context.Context, r *http.Request, key string) context.Context {
+	header := r.Header.Get("Range")
+	if !h.rangeRequests || header == "" || r.Header.Get("If-Range") != "" || h.wantsBase64(r) {
+		return ctx
+	}
+	return context.WithValue(ctx, rangeRequestKey{}, rangeRequest{key: key, header: header})
+}
+
+// getObjectRange reads only the requested range of key from storage, if
+// the request asked for a single range of an object too large to buffer.
+// It reports false when the object should be read whole instead: small
+// objects are worth caching, compressed ones are ranged over their
+// inflated bytes, and several ranges would need several requests. The
+// object's size comes back with the range, so a range with a start is
+// read without looking the object up first; small and compressed objects
+// then cost a wasted ranged read instead of every range costing a lookup.
+// Only a suffix range needs the size before it can be asked for.
+func (h *FileHandler) getObjectRange(ctx context.Context, key string, limit int64) (*entry, bool, error) {
+	req, ok := ctx.Value(rangeRequestKey{}).(rangeRequest)
+	if !ok || req.key != key {
+		return nil, false, nil
+	}
+	specs, err := parseRangeSpecs(req.header, h.maxRanges)
+	if err != nil || len(specs) != 1 {
+		return nil, false, nil
+	}
+
+	var br byteRange
+	if spec := specs[0]; spec.first >= 0 {
+		// An open range asks for everything up to the end, whatever it is
+		br = byteRange{start: spec.first, length: spec.last - spec.first}
+		if spec.last < math.MaxInt64 {
+			br.length++
+		}
+	} else {
+		info, err := h.storage.StatObject(ctx, key)
+		if err != nil {
+			if isNotFoundError(err) {
+				return nil, false, err
+			}
+			// Let the whole-object read surface storage problems
+			slog.Warn("Failed to stat object for a range request",
+				"filename", key, "error", err, "upstream_request_id", storage.RequestID(err))
+			return nil, false, nil
+		}
+		if info.Size <= limit || info.ContentEncoding != "" {
+			return nil, false, nil
+		}
+		ranges, err := parseRange(req.header, info.Size, h.maxRanges)
+		if err != nil {
+			return nil, false, nil
+		}
+		br = ranges[0]
+	}
+
+	body, info, err := h.openRange(ctx, key, br)
+	if err != nil {
+		// A range starting past the end is answered with 416 by the
+		// whole-object read
+		if storage.IsInvalidRange(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if info.Size <= limit || info.ContentEncoding != "" || br.start >= info.Size {
+		body.Close()
+		return nil, false, nil
+	}
+	br.length = min(br.length, info.Size-br.start)
+
+	obj := &entry{
+		ContentType:     specificContentType(info.ContentType),
+		ContentEncoding: info.ContentEncoding,
+		CacheControl:    info.CacheControl,
+		ETag:            info.ETag,
+		modified:        info.LastModified,
+		storageClass:    info.StorageClass,
+		body:            body,
+		size:            info.Size,
+		window:          &br,
+	}
+	h.ensureETag(obj)
+	slog.Info("Fetched range from storage", "filename", key, "range", br.contentRange(info.Size))
+	return obj, true, nil
+}
+
+// writeWindow serves a body fetched as only window of a size-byte object
+// as a 206 response
+func (h *FileHandler) writeWindow(w http.ResponseWriter, filename, contentType string, body io.Reader, window byteRange, size int64) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Range", window.contentRange(size))
+	w.Header().Set("Content-Length", strconv.FormatInt(window.length, 10))
+	w.WriteHeader(http.StatusPartialContent)
+
+	// Headers are already sent, so a failure here can only be logged
+	if err := h.copyResponse(w, body); err != nil {
+		slog.Error("Failed to stream object range", "filename", filename, "error", err)
+	}
+}
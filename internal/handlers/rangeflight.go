@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// WithRangeCoalescing makes concurrent requests for the same byte range of
+// the same object share one ranged storage read, as happens when several
+// players seek a video to the same point. Only ranges of at most maxSize
+// bytes are shared, since the shared bytes are held in memory until every
+// waiting request has them; larger ranges and different ranges are read
+// independently. 0 disables coalescing.
+func WithRangeCoalescing(maxSize int64) Option {
+	return func(h *FileHandler) {
+		if maxSize <= 0 {
+			h.rangeFlights = nil
+			return
+		}
+		h.rangeFlights = &rangeFlights{
+			maxSize: maxSize,
+			calls:   make(map[rangeFlightKey]*rangeFlight),
+		}
+	}
+}
+
+// rangeFlights tracks the ranged storage reads in progress
+type rangeFlights struct {
+	maxSize int64
+
+	mu    sync.Mutex
+	calls map[rangeFlightKey]*rangeFlight
+}
+
+// rangeFlightKey identifies a ranged read: the same range of the same key
+type rangeFlightKey struct {
+	key    string
+	start  int64
+	length int64
+}
+
+// rangeFlight is a ranged read whose result is shared once done is closed
+type rangeFlight struct {
+	done chan struct{}
+	data []byte
+	info storage.ObjectInfo
+	err  error
+}
+
+// getSharedRange reads br of key from storage, joining a read of the same
+// range already in progress. The read runs detached from the request that
+// started it, so a client going away doesn't fail the others waiting on it.
+func (h *FileHandler) getSharedRange(ctx context.Context, key string, br byteRange) ([]byte, storage.ObjectInfo, error) {
+	k := rangeFlightKey{key: key, start: br.start, length: br.length}
+
+	f := h.rangeFlights
+	f.mu.Lock()
+	call, joined := f.calls[k]
+	if !joined {
+		call = &rangeFlight{done: make(chan struct{})}
+		f.calls[k] = call
+		go func() {
+			readCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.requestTimeout)
+			defer cancel()
+
+			call.data, call.info, call.err = h.readRange(readCtx, key, br)
+
+			f.mu.Lock()
+			delete(f.calls, k)
+			f.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	f.mu.Unlock()
+
+	if joined {
+		slog.InfoContext(ctx, "Joined in-flight range read", "filename", key, "start", br.start, "length", br.length)
+	}
+
+	select {
+	case <-call.done:
+		return call.data, call.info, call.err
+	case <-ctx.Done():
+		return nil, storage.ObjectInfo{}, ctx.Err()
+	}
+}
+
+// readRange reads br of key from storage into memory
+func (h *FileHandler) readRange(ctx context.Context, key string, br byteRange) ([]byte, storage.ObjectInfo, error) {
+	body, info, err := h.openStorageRange(ctx, key, br)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, fmt.Errorf("failed to read object range: %w", err)
+	}
+	return data, info, nil
+}
+
+// openRange returns an open body for br of key, shared with concurrent
+// requests for the same range when coalescing applies to it
+func (h *FileHandler) openRange(ctx context.Context, key string, br byteRange) (io.ReadCloser, storage.ObjectInfo, error) {
+	if h.rangeFlights == nil || br.length > h.rangeFlights.maxSize {
+		return h.openStorageRange(ctx, key, br)
+	}
+	data, info, err := h.getSharedRange(ctx, key, br)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), info, nil
+}
+
+// openStorageRange opens br of key in storage, checking that exactly the
+// range is sent. br may run past the end of an object whose size wasn't
+// known when it was asked for, so it ends at the object's end.
+func (h *FileHandler) openStorageRange(ctx context.Context, key string, br byteRange) (io.ReadCloser, storage.ObjectInfo, error) {
+	body, info, err := h.storage.GetObjectRange(ctx, key, br.start, br.length)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	want := max(min(br.length, info.Size-br.start), 0)
+	return h.checkedBody(ctx, key, body, want), info, nil
+}
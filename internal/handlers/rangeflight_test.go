@@ -0,0 +1,111 @@
+package handlers_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// stalledRangeStorage blocks ranged reads until release is closed
+type stalledRangeStorage struct {
+	*mocks.MockStorage
+	started atomic.Int32
+	release chan struct{}
+}
+
+func (s *stalledRangeStorage) GetObjectRange(ctx context.Context, key string, start, length int64) (io.ReadCloser, storage.ObjectInfo, error) {
+	s.started.Add(1)
+	<-s.release
+	return s.MockStorage.GetObjectRange(ctx, key, start, length)
+}
+
+func TestGetFile_RangeCoalescing(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	mockStorage := &stalledRangeStorage{MockStorage: mocks.NewMockStorage(), release: make(chan struct{})}
+	mockStorage.SetObject("video.mp4", []byte(body))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithMaxBufferedSize(50),
+		handlers.WithRangeCoalescing(20),
+	)
+
+	ranges := map[string]string{
+		"bytes=10-14": "01234",
+		"bytes=20-29": "0123456789",
+		"bytes=-3":    "789",
+	}
+	const perRange = 5
+
+	var wg sync.WaitGroup
+	for header, want := range ranges {
+		for range perRange {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rec := getFileWithHeader(handler, "video.mp4", http.Header{"Range": {header}})
+				if rec.Code != http.StatusPartialContent || rec.Body.String() != want {
+					t.Errorf("%s: expected 206 %q, got %d %q", header, want, rec.Code, rec.Body.String())
+				}
+			}()
+		}
+	}
+
+	// Every range is being read, and the other requests get time to join
+	// before any read completes
+	waitFor(t, func() bool { return mockStorage.started.Load() == int32(len(ranges)) })
+	time.Sleep(20 * time.Millisecond)
+	close(mockStorage.release)
+	wg.Wait()
+
+	calls := make(map[mocks.RangeCall]int)
+	for _, call := range mockStorage.RangeCalls {
+		calls[call]++
+	}
+	if len(mockStorage.RangeCalls) != len(ranges) {
+		t.Errorf("Expected one ranged read per distinct range, got %v", mockStorage.RangeCalls)
+	}
+	for call, n := range calls {
+		if n != 1 {
+			t.Errorf("Expected 1 read of %+v, got %d", call, n)
+		}
+	}
+}
+
+func TestGetFile_RangeCoalescing_LargeRangesNotShared(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	mockStorage := &stalledRangeStorage{MockStorage: mocks.NewMockStorage(), release: make(chan struct{})}
+	mockStorage.SetObject("video.mp4", []byte(body))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithMaxBufferedSize(50),
+		handlers.WithRangeCoalescing(20),
+	)
+
+	const requests = 3
+	var wg sync.WaitGroup
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := getFileWithHeader(handler, "video.mp4", http.Header{"Range": {"bytes=0-49"}})
+			if rec.Code != http.StatusPartialContent || rec.Body.String() != body[:50] {
+				t.Errorf("Expected 206 with 50 bytes, got %d with %d", rec.Code, rec.Body.Len())
+			}
+		}()
+	}
+
+	waitFor(t, func() bool { return mockStorage.started.Load() == requests })
+	close(mockStorage.release)
+	wg.Wait()
+
+	if got := len(mockStorage.RangeCalls); got != requests {
+		t.Errorf("Expected %d independent ranged reads, got %d", requests, got)
+	}
+}
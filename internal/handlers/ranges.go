@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxRanges caps the number of ranges served in a single request
+const defaultMaxRanges = 10
+
+var (
+	// errMalformedRange means the Range header isn't valid "bytes=" range
+	// syntax. Such requests are rejected with 400.
+	errMalformedRange = errors.New("malformed range")
+
+	// errUnknownRangeUnit means the Range header asks for a unit other
+	// than bytes. Per RFC 9110 such a header is ignored and the full body
+	// is served.
+	errUnknownRangeUnit = errors.New("unknown range unit")
+
+	// errTooManyRanges means the Range header lists more ranges than the
+	// configured maximum
+	errTooManyRanges = errors.New("too many ranges")
+
+	// errUnsatisfiableRange means none of the ranges overlap the body
+	errUnsatisfiableRange = errors.New("unsatisfiable range")
+)
+
+// WithMaxRanges sets the maximum number of ranges accepted in one request.
+// Requests asking for more are rejected with 400.
+func WithMaxRanges(n int) Option {
+	return func(h *FileHandler) {
+		if n > 0 {
+			h.maxRanges = n
+		}
+	}
+}
+
+// WithRangeRequests sets whether Range headers are honored. Disabled,
+// every request gets the full body and responses say so with
+// Accept-Ranges: none.
+func WithRangeRequests(enabled bool) Option {
+	return func(h *FileHandler) {
+		h.rangeRequests = enabled
+	}
+}
+
+// setAcceptRanges advertises whether a Range request for the same object
+// would be honored
+func setAcceptRanges(w http.ResponseWriter, ok bool) {
+	if ok {
+		w.Header().Set("Accept-Ranges", "bytes")
+		return
+	}
+	w.Header().Set("Accept-Ranges", "none")
+}
+
+// byteRange is a resolved range of length bytes starting at start
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+func (br byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.start, br.start+br.length-1, size)
+}
+
+// rangeSpec is one range of a Range header before it is resolved against
+// a body: bytes first through last, or the final last bytes when first is
+// negative. A missing last is math.MaxInt64.
+type rangeSpec struct {
+	first int64
+	last  int64
+}
+
+// parseRangeSpecs checks the syntax of a Range header and returns its
+// ranges. Positions must be plain decimal digits; ones too large for an
+// int64 are kept as math.MaxInt64, which no body reaches. Headers with more
+// than limit ranges fail with errTooManyRanges without being read further.
+func parseRangeSpecs(header string, limit int) ([]rangeSpec, error) {
+	unit, set, ok := strings.Cut(header, "=")
+	if !ok {
+		return nil, errMalformedRange
+	}
+	if !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		if strings.TrimSpace(unit) == "" {
+			return nil, errMalformedRange
+		}
+		return nil, errUnknownRangeUnit
+	}
+
+	var specs []rangeSpec
+	for _, part := range strings.Split(set, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if len(specs) == limit {
+			return nil, errTooManyRanges
+		}
+
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, errMalformedRange
+		}
+
+		if first == "" {
+			// Suffix range: the final N bytes
+			n, ok := parseRangePosition(last)
+			if !ok {
+				return nil, errMalformedRange
+			}
+			specs = append(specs, rangeSpec{first: -1, last: n})
+			continue
+		}
+
+		start, ok := parseRangePosition(first)
+		if !ok {
+			return nil, errMalformedRange
+		}
+		end := int64(math.MaxInt64)
+		if last != "" {
+			if end, ok = parseRangePosition(last); !ok || end < start {
+				return nil, errMalformedRange
+			}
+		}
+		specs = append(specs, rangeSpec{first: start, last: end})
+	}
+
+	if len(specs) == 0 {
+		return nil, errMalformedRange
+	}
+	return specs, nil
+}
+
+// parseRangePosition parses a byte position made only of decimal digits,
+// saturating at math.MaxInt64
+func parseRangePosition(s string) (int64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		// Only a value out of range is left
+		return math.MaxInt64, true
+	}
+	return n, true
+}
+
+// parseRange parses a Range header against a body of the given size,
+// allowing at most limit ranges. Ranges that start past the end are
+// dropped; if none are left the result is errUnsatisfiableRange.
+func parseRange(header string, size int64, limit int) ([]byteRange, error) {
+	specs, err := parseRangeSpecs(header, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []byteRange
+	for _, spec := range specs {
+		var br byteRange
+		if spec.first < 0 {
+			n := min(spec.last, size)
+			br = byteRange{start: size - n, length: n}
+		} else {
+			if spec.first >= size {
+				continue
+			}
+			end := min(spec.last, size-1)
+			br = byteRange{start: spec.first, length: end - spec.first + 1}
+		}
+
+		if br.length > 0 {
+			ranges = append(ranges, br)
+		}
+	}
+
+	if len(ranges) == 0 {
+		return nil, errUnsatisfiableRange
+	}
+	return ranges, nil
+}
+
+// checkRangeSyntax rejects a request whose Range header would be honored
+// but is malformed or lists too many ranges, before anything is fetched
+func (h *FileHandler) checkRangeSyntax(r *http.Request) error {
+	header := r.Header.Get("Range")
+	if !h.rangeRequests || header == "" || h.wantsBase64(r) {
+		return nil
+	}
+	if _, err := parseRangeSpecs(header, h.maxRanges); err != nil && !errors.Is(err, errUnknownRangeUnit) {
+		return err
+	}
+	return nil
+}
+
+// writeRangeError responds to a Range header that can't be served: 400
+// for bad syntax or too many ranges, 416 when no range overlaps the
+// size-byte body
+func (h *FileHandler) writeRangeError(w http.ResponseWriter, err error, size int64) {
+	switch {
+	case errors.Is(err, errUnsatisfiableRange):
+		w.Header().Del("Content-Type")
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		writeJSON(w, http.StatusRequestedRangeNotSatisfiable, Response{
+			Success: false,
+			Message: "Range not satisfiable",
+		})
+	case errors.Is(err, errTooManyRanges):
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: fmt.Sprintf("too many ranges (max %d)", h.maxRanges),
+		})
+	default:
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: "malformed Range header",
+		})
+	}
+}
+
+// writeRanges serves the requested ranges of data, returning false if the
+// full body should be written instead
+func (h *FileHandler) writeRanges(w http.ResponseWriter, r *http.Request, contentType string, data []byte) bool {
+	header := r.Header.Get("Range")
+	if header == "" {
+		return false
+	}
+
+	size := int64(len(data))
+	ranges, err := parseRange(header, size, h.maxRanges)
+	switch {
+	case errors.Is(err, errUnknownRangeUnit):
+		return false
+	case err != nil:
+		h.writeRangeError(w, err, size)
+		return true
+	}
+
+	// Overlapping ranges that add up to more than the body aren't worth
+	// the multipart overhead; serve the whole thing instead
+	var total int64
+	for _, br := range ranges {
+		total += br.length
+	}
+	if total > size {
+		return false
+	}
+
+	if len(ranges) == 1 {
+		br := ranges[0]
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Range", br.contentRange(size))
+		w.Header().Set("Content-Length", strconv.FormatInt(br.length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[br.start : br.start+br.length])
+		return true
+	}
+
+	// Build the body first so it can be sent with a Content-Length, which
+	// HTTP/1.0 clients need to find its end on a kept-alive connection
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, br := range ranges {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  {contentType},
+			"Content-Range": {br.contentRange(size)},
+		})
+		if err != nil {
+			return false
+		}
+		part.Write(data[br.start : br.start+br.length])
+	}
+	mw.Close()
+
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(body.Bytes())
+	return true
+}
@@ -0,0 +1,288 @@
+package handlers_test
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+const rangeTestContent = "0123456789abcdefghij" // 20 bytes
+
+func serveRange(t *testing.T, rangeHeader string, opts ...handlers.Option) *httptest.ResponseRecorder {
+	t.Helper()
+
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("video.mp4", []byte(rangeTestContent))
+	handler := handlers.NewFileHandler(nil, mockStorage, opts...)
+
+	req := httptest.NewRequest(http.MethodGet, "/files/video.mp4", nil)
+	req.SetPathValue("name", "video.mp4")
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	rec := httptest.NewRecorder()
+
+	handler.GetFile(rec, req)
+	return rec
+}
+
+func TestGetFile_Range_Single(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		wantBody   string
+		wantRange  string
+		wantStatus int
+	}{
+		{"first bytes", "bytes=0-3", "0123", "bytes 0-3/20", http.StatusPartialContent},
+		{"middle", "bytes=10-14", "abcde", "bytes 10-14/20", http.StatusPartialContent},
+		{"open ended", "bytes=15-", "fghij", "bytes 15-19/20", http.StatusPartialContent},
+		{"suffix", "bytes=-3", "hij", "bytes 17-19/20", http.StatusPartialContent},
+		{"end clamped", "bytes=18-100", "ij", "bytes 18-19/20", http.StatusPartialContent},
+		{"suffix larger than body", "bytes=-50", rangeTestContent, "bytes 0-19/20", http.StatusPartialContent},
+		{"no range", "", rangeTestContent, "", http.StatusOK},
+		{"unit is case-insensitive", "Bytes=0-3", "0123", "bytes 0-3/20", http.StatusPartialContent},
+		{"huge end clamped", "bytes=18-99999999999999999999", "ij", "bytes 18-19/20", http.StatusPartialContent},
+		{"huge suffix", "bytes=-99999999999999999999", rangeTestContent, "bytes 0-19/20", http.StatusPartialContent},
+		{"unknown unit ignored", "items=0-3", rangeTestContent, "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveRange(t, tt.header)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("Expected body '%s', got '%s'", tt.wantBody, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.wantRange {
+				t.Errorf("Expected Content-Range '%s', got '%s'", tt.wantRange, got)
+			}
+			if got := rec.Header().Get("Accept-Ranges"); got != "bytes" {
+				t.Errorf("Expected Accept-Ranges 'bytes', got '%s'", got)
+			}
+		})
+	}
+}
+
+func TestGetFile_Range_Unsatisfiable(t *testing.T) {
+	rec := serveRange(t, "bytes=20-30")
+
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestedRangeNotSatisfiable, rec.Code)
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes */20" {
+		t.Errorf("Expected Content-Range 'bytes */20', got '%s'", got)
+	}
+}
+
+func TestGetFile_Range_Invalid(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       string
+		wantStatus   int
+		contentRange string
+	}{
+		// Bad syntax is rejected before the object is fetched
+		{"no equals", "bytes", http.StatusBadRequest, ""},
+		{"no unit", "=0-3", http.StatusBadRequest, ""},
+		{"no ranges", "bytes=", http.StatusBadRequest, ""},
+		{"only commas", "bytes=,,", http.StatusBadRequest, ""},
+		{"only dash", "bytes=-", http.StatusBadRequest, ""},
+		{"letters", "bytes=abc-def", http.StatusBadRequest, ""},
+		{"letter end", "bytes=0-x", http.StatusBadRequest, ""},
+		{"no dash", "bytes=5", http.StatusBadRequest, ""},
+		{"reversed", "bytes=5-2", http.StatusBadRequest, ""},
+		{"signed start", "bytes=+1-3", http.StatusBadRequest, ""},
+		{"negative end", "bytes=5--2", http.StatusBadRequest, ""},
+		{"signed suffix", "bytes=-+3", http.StatusBadRequest, ""},
+		{"hex", "bytes=0x1-0x3", http.StatusBadRequest, ""},
+		{"decimal point", "bytes=1.5-3", http.StatusBadRequest, ""},
+		{"space inside range", "bytes=1 -3", http.StatusBadRequest, ""},
+		{"two dashes", "bytes=1-2-3", http.StatusBadRequest, ""},
+		{"one bad range of several", "bytes=0-1,x-2", http.StatusBadRequest, ""},
+		{"too many ranges", "bytes=" + strings.Repeat("0-0,", 1000) + "0-0", http.StatusBadRequest, ""},
+
+		// Valid syntax that misses the 20-byte body
+		{"start at end", "bytes=20-", http.StatusRequestedRangeNotSatisfiable, "bytes */20"},
+		{"start past end", "bytes=100-200", http.StatusRequestedRangeNotSatisfiable, "bytes */20"},
+		{"huge start", "bytes=99999999999999999999-", http.StatusRequestedRangeNotSatisfiable, "bytes */20"},
+		{"empty suffix", "bytes=-0", http.StatusRequestedRangeNotSatisfiable, "bytes */20"},
+		{"all ranges past end", "bytes=20-21,30-", http.StatusRequestedRangeNotSatisfiable, "bytes */20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveRange(t, tt.header)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("Expected Content-Range %q, got %q", tt.contentRange, got)
+			}
+		})
+	}
+}
+
+func TestGetFile_Range_InvalidIgnoredWhenDisabled(t *testing.T) {
+	rec := serveRange(t, "bytes=abc-def", handlers.WithRangeRequests(false))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != rangeTestContent {
+		t.Errorf("Expected the full body with status %d, got %d %q", http.StatusOK, rec.Code, rec.Body.String())
+	}
+}
+
+func TestGetFile_Range_Multipart(t *testing.T) {
+	rec := serveRange(t, "bytes=0-1, 10-12")
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusPartialContent, rec.Code)
+	}
+	if rec.Header().Get("Content-Length") != strconv.Itoa(rec.Body.Len()) {
+		t.Errorf("Expected Content-Length %d, got '%s'", rec.Body.Len(), rec.Header().Get("Content-Length"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("Failed to parse Content-Type: %v", err)
+	}
+	if mediaType != "multipart/byteranges" {
+		t.Fatalf("Expected multipart/byteranges, got %s", mediaType)
+	}
+
+	want := []struct {
+		body         string
+		contentRange string
+	}{
+		{"01", "bytes 0-1/20"},
+		{"abc", "bytes 10-12/20"},
+	}
+
+	reader := multipart.NewReader(rec.Body, params["boundary"])
+	for i, w := range want {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("Part %d: %v", i, err)
+		}
+		if got := part.Header.Get("Content-Range"); got != w.contentRange {
+			t.Errorf("Part %d: expected Content-Range '%s', got '%s'", i, w.contentRange, got)
+		}
+		if got := part.Header.Get("Content-Type"); got != "video/mp4" {
+			t.Errorf("Part %d: expected Content-Type 'video/mp4', got '%s'", i, got)
+		}
+		body, _ := io.ReadAll(part)
+		if string(body) != w.body {
+			t.Errorf("Part %d: expected body '%s', got '%s'", i, w.body, body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("Expected exactly %d parts, got error %v", len(want), err)
+	}
+}
+
+func TestGetFile_Range_TooMany(t *testing.T) {
+	rec := serveRange(t, "bytes=0-0,2-2,4-4", handlers.WithMaxRanges(2))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestGetFile_Range_OverlappingServesFullBody(t *testing.T) {
+	rec := serveRange(t, "bytes=0-15,5-19")
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Body.String() != rangeTestContent {
+		t.Errorf("Expected full body, got '%s'", rec.Body.String())
+	}
+}
+
+func TestGetFile_AcceptRanges(t *testing.T) {
+	small := make([]byte, 100)
+	large := make([]byte, 4096)
+
+	tests := []struct {
+		name           string
+		opts           []handlers.Option
+		data           []byte
+		gzip           bool
+		acceptEncoding string
+		want           string
+	}{
+		{"buffered", nil, small, false, "", "bytes"},
+		{"ranges disabled", []handlers.Option{handlers.WithRangeRequests(false)}, small, false, "", "none"},
+		{"streamed", []handlers.Option{handlers.WithMaxBufferedSize(50)}, small, false, "", "bytes"},
+		{"streamed with ranges disabled", []handlers.Option{
+			handlers.WithMaxBufferedSize(50), handlers.WithRangeRequests(false),
+		}, small, false, "", "none"},
+		{"streamed stored gzip", []handlers.Option{handlers.WithMaxBufferedSize(10)}, small, true, "gzip", "none"},
+		{"stored gzip for gzip client", []handlers.Option{handlers.WithGzipDecompression(true)}, small, true, "gzip", "bytes"},
+		{"decompressed within limit", []handlers.Option{
+			handlers.WithGzipDecompression(true), handlers.WithGzipRangeLimit(1024),
+		}, small, true, "", "bytes"},
+		{"decompressed past limit", []handlers.Option{
+			handlers.WithGzipDecompression(true), handlers.WithGzipRangeLimit(1024),
+		}, large, true, "", "none"},
+		{"decompressed with ranges disabled", []handlers.Option{
+			handlers.WithGzipDecompression(true), handlers.WithRangeRequests(false),
+		}, small, true, "", "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			if tt.gzip {
+				mockStorage.SetObject("f.bin", gzipBytes(t, tt.data))
+				mockStorage.SetObjectInfo("f.bin", storage.ObjectInfo{ContentEncoding: "gzip"})
+			} else {
+				mockStorage.SetObject("f.bin", tt.data)
+			}
+			handler := handlers.NewFileHandler(nil, mockStorage, tt.opts...)
+
+			req := httptest.NewRequest(http.MethodGet, "/files/f.bin", nil)
+			req.SetPathValue("name", "f.bin")
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.GetFile(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if got := rec.Header().Get("Accept-Ranges"); got != tt.want {
+				t.Errorf("Expected Accept-Ranges %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetFile_RangeRequestsDisabled(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("f.txt", []byte("0123456789"))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithRangeRequests(false))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/f.txt", nil)
+	req.SetPathValue("name", "f.txt")
+	req.Header.Set("Range", "bytes=0-3")
+	rec := httptest.NewRecorder()
+	handler.GetFile(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Errorf("Expected the full body with status %d, got %d %q", http.StatusOK, rec.Code, rec.Body.String())
+	}
+}
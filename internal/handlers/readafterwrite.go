@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// recentWriteKeyPrefix namespaces the cache markers of keys that may have
+// just been uploaded
+const recentWriteKeyPrefix = internalKeyPrefix + "recent-write:"
+
+// WithReadAfterWriteRetry retries storage reads that find nothing for keys
+// an upload URL was issued for within the last window (plus the URL's
+// validity, since the upload can happen any time before it expires). Such
+// reads are retried up to retries times, delay apart, before responding
+// 404, to ride out R2 propagation right after an upload. Issued uploads are
+// tracked in the cache, so this needs Redis. A window of 0 disables it.
+func WithReadAfterWriteRetry(window time.Duration, retries int, delay time.Duration) Option {
+	return func(h *FileHandler) {
+		h.recentWriteWindow = window
+		h.recentWriteRetries = retries
+		h.recentWriteDelay = delay
+	}
+}
+
+// readAfterWriteEnabled reports whether recent uploads are tracked
+func (h *FileHandler) readAfterWriteEnabled() bool {
+	return h.cache != nil && h.recentWriteWindow > 0 && h.recentWriteRetries > 0
+}
+
+// markRecentWrite records that key may be uploaded soon
+func (h *FileHandler) markRecentWrite(ctx context.Context, key string) {
+	if !h.readAfterWriteEnabled() {
+		return
+	}
+	ttl := h.uploadURLExpiry + h.recentWriteWindow
+	if err := h.cache.SetWithTTL(ctx, recentWriteKeyPrefix+key, []byte("1"), ttl); err != nil {
+		slog.Error("Failed to record upload", "filename", key, "error", err)
+	}
+}
+
+// retryRecentWrite retries reading key after storage reported err for it,
+// if err is a not-found error and key was recently uploaded. It returns
+// the object once found, or the last error.
+func (h *FileHandler) retryRecentWrite(ctx context.Context, key string, err error) (*entry, error) {
+	if !h.readAfterWriteEnabled() || !isNotFoundError(err) {
+		return nil, err
+	}
+	if _, found, cacheErr := h.cache.Get(ctx, recentWriteKeyPrefix+key); cacheErr != nil || !found {
+		return nil, err
+	}
+
+	for attempt := 1; attempt <= h.recentWriteRetries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-h.clock.After(h.recentWriteDelay):
+		}
+
+		var obj *entry
+		obj, err = h.getCheckedObject(ctx, key)
+		if err == nil {
+			slog.Info("Found recent upload after retrying", "filename", key, "attempts", attempt)
+			return obj, nil
+		}
+		if !isNotFoundError(err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,117 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// propagatingStorage only finds "upload.png" from its visibleAfter'th read,
+// counting gets and stats
+type propagatingStorage struct {
+	*mocks.MockStorage
+	reads        atomic.Int32
+	visibleAfter int32
+}
+
+func (s *propagatingStorage) read() {
+	if s.reads.Add(1) == s.visibleAfter {
+		s.SetObject("upload.png", []byte("png"))
+	}
+}
+
+func (s *propagatingStorage) GetObjectWithInfo(ctx context.Context, key string) ([]byte, storage.ObjectInfo, error) {
+	s.read()
+	return s.MockStorage.GetObjectWithInfo(ctx, key)
+}
+
+func (s *propagatingStorage) StatObject(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	s.read()
+	return s.MockStorage.StatObject(ctx, key)
+}
+
+func newReadAfterWriteHandler(s storage.Storage, retries int) *handlers.FileHandler {
+	return handlers.NewFileHandler(mocks.NewMockCache(), s,
+		handlers.WithUploadURLs([]string{"image/png"}, 10*time.Minute),
+		handlers.WithReadAfterWriteRetry(time.Minute, retries, 0),
+	)
+}
+
+func TestGetFile_ReadAfterWrite_RetriesRecentUpload(t *testing.T) {
+	mockStorage := &propagatingStorage{MockStorage: mocks.NewMockStorage(), visibleAfter: 3}
+	handler := newReadAfterWriteHandler(mockStorage, 2)
+
+	if rec := requestUploadURL(handler, "upload.png", `{"content_type":"image/png","size":3}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected upload URL, got status %d", rec.Code)
+	}
+
+	rec := getFile(handler, "upload.png")
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if reads := mockStorage.reads.Load(); reads != 3 {
+		t.Errorf("Expected 3 storage reads, got %d", reads)
+	}
+}
+
+func TestGetFile_ReadAfterWrite_GivesUpAfterRetries(t *testing.T) {
+	mockStorage := &propagatingStorage{MockStorage: mocks.NewMockStorage(), visibleAfter: 10}
+	handler := newReadAfterWriteHandler(mockStorage, 2)
+	requestUploadURL(handler, "upload.png", `{"content_type":"image/png","size":3}`)
+
+	rec := getFile(handler, "upload.png")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if reads := mockStorage.reads.Load(); reads != 3 {
+		t.Errorf("Expected 1 read and 2 retries, got %d reads", reads)
+	}
+}
+
+func TestGetFile_ReadAfterWrite_RetriesUnderMemoryPressure(t *testing.T) {
+	mockStorage := &propagatingStorage{MockStorage: mocks.NewMockStorage(), visibleAfter: 2}
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mockStorage,
+		handlers.WithUploadURLs([]string{"image/png"}, 10*time.Minute),
+		handlers.WithReadAfterWriteRetry(time.Minute, 2, 0),
+		handlers.WithMemoryShedding(1, 100),
+	)
+	requestUploadURL(handler, "upload.png", `{"content_type":"image/png","size":3}`)
+
+	rec := getFile(handler, "upload.png")
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the recent upload to be retried, got status %d", rec.Code)
+	}
+}
+
+func TestGetFile_ReadAfterWrite_NoRetryWithoutUpload(t *testing.T) {
+	mockStorage := &propagatingStorage{MockStorage: mocks.NewMockStorage(), visibleAfter: 2}
+	handler := newReadAfterWriteHandler(mockStorage, 2)
+
+	rec := getFile(handler, "upload.png")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if reads := mockStorage.reads.Load(); reads != 1 {
+		t.Errorf("Expected a single storage read, got %d", reads)
+	}
+}
+
+func TestGetFile_ReadAfterWrite_MarkerNotRequestable(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := newReadAfterWriteHandler(mockStorage, 1)
+	requestUploadURL(handler, "upload.png", `{"content_type":"image/png","size":3}`)
+
+	if rec := getFile(handler, "recent-write:upload.png"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for the marker's old key, got %d", http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithCacheReadRepair sets whether a cache entry that fails to decode, e.g.
+// on a checksum mismatch, is deleted before the object is fetched from
+// storage again. Without it the refetched object may not replace the bad
+// entry: versioned writes keep a cached revision that looks as new.
+func WithCacheReadRepair(enabled bool) Option {
+	return func(h *FileHandler) {
+		h.readRepair = enabled
+	}
+}
+
+// removeCorruptEntry deletes key's unreadable cache entry, reporting
+// whether it is gone so the fetch that follows counts as a repair
+func (h *FileHandler) removeCorruptEntry(ctx context.Context, key string) bool {
+	if !h.readRepair {
+		return false
+	}
+	if err := h.cache.Delete(ctx, key); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete corrupt cache entry", "filename", key, "error", err)
+		return false
+	}
+	return true
+}
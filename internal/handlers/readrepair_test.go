@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/metrics"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// corruptCached flips a bit in the last byte of key's cached value, which
+// is part of the body, and returns the corrupted value
+func corruptCached(t *testing.T, c *mocks.MockCache, key string) []byte {
+	t.Helper()
+	value, found, _ := c.Get(context.Background(), key)
+	if !found {
+		t.Fatalf("Expected %s to be cached", key)
+	}
+	corrupted := bytes.Clone(value)
+	corrupted[len(corrupted)-1] ^= 1
+	c.SetData(key, corrupted)
+	return corrupted
+}
+
+func TestGetFile_ReadRepair(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	mockStorage.SetObjectInfo("a.txt", storage.ObjectInfo{ETag: `"v1"`})
+	m := mocks.NewMockMetrics()
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithMetrics(m),
+		handlers.WithVersionedCacheWrites(true),
+	)
+
+	getFile(handler, "a.txt")
+	waitFor(t, func() bool { return cached(mockCache, "a.txt") })
+	corrupted := corruptCached(t, mockCache, "a.txt")
+
+	rec := getFile(handler, "a.txt")
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Fatalf("Expected the good body from storage, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := m.Counter(metrics.CorruptionRepairsTotal, nil); got != 1 {
+		t.Errorf("Expected 1 repair, got %v", got)
+	}
+	if len(mockCache.DeleteCalls) != 1 || mockCache.DeleteCalls[0] != "a.txt" {
+		t.Errorf("Expected the corrupt entry to be deleted, got %v", mockCache.DeleteCalls)
+	}
+
+	// The same revision is cached again, so no later hit serves the bad bytes
+	waitFor(t, func() bool {
+		value, found, _ := mockCache.Get(context.Background(), "a.txt")
+		return found && !bytes.Equal(value, corrupted)
+	})
+	if rec := getFile(handler, "a.txt"); rec.Body.String() != "hello" {
+		t.Errorf("Expected the repaired entry to be served, got %q", rec.Body.String())
+	}
+	if got := len(mockStorage.GetCalls); got != 2 {
+		t.Errorf("Expected 2 storage reads, got %d", got)
+	}
+}
+
+func TestGetFile_ReadRepair_Disabled(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	m := mocks.NewMockMetrics()
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithMetrics(m),
+		handlers.WithCacheReadRepair(false),
+	)
+
+	getFile(handler, "a.txt")
+	waitFor(t, func() bool { return cached(mockCache, "a.txt") })
+	corruptCached(t, mockCache, "a.txt")
+
+	// The checksum still keeps the bad bytes from being served
+	if rec := getFile(handler, "a.txt"); rec.Body.String() != "hello" {
+		t.Errorf("Expected the good body from storage, got %q", rec.Body.String())
+	}
+	if len(mockCache.DeleteCalls) != 0 {
+		t.Errorf("Expected no deletes, got %v", mockCache.DeleteCalls)
+	}
+	if got := m.Counter(metrics.CorruptionRepairsTotal, nil); got != 0 {
+		t.Errorf("Expected no repairs, got %v", got)
+	}
+}
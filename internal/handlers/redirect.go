@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// defaultRedirectExpiry is how long presigned download URLs stay valid
+const defaultRedirectExpiry = 5 * time.Minute
+
+// WithStorageRedirect serves objects larger than threshold bytes with a
+// 302 to a presigned storage URL valid for expiry, instead of proxying
+// them. Nothing of the body has been sent when the redirect is decided, so
+// a storage failure during the download is between the client and storage
+// and can be retried there. Smaller objects and cache hits are proxied as
+// before. A threshold of 0 disables redirects.
+func WithStorageRedirect(threshold int64, expiry time.Duration) Option {
+	return func(h *FileHandler) {
+		if threshold >= 0 {
+			h.redirectThreshold = threshold
+		}
+		if expiry > 0 {
+			h.redirectExpiry = expiry
+		}
+	}
+}
+
+// shouldRedirect reports whether obj, fetched from storage, is large
+// enough to be redirected rather than proxied. Blocked content types are
+// always proxied, since storage would serve them as-is.
+func (h *FileHandler) shouldRedirect(key string, obj *entry) bool {
+	return h.redirectThreshold > 0 && obj.body != nil && obj.size > h.redirectThreshold &&
+		!h.unsafeTypes[mediaType(h.contentTypeOf(obj, key))]
+}
+
+// redirectToStorage answers with a redirect to a presigned URL for the key
+// obj was found under, which is key unless it was found under another one.
+// If presigning fails the object is proxied after all.
+func (h *FileHandler) redirectToStorage(ctx context.Context, w http.ResponseWriter, r *http.Request, key string, obj *entry) {
+	storageKey := key
+	if obj.storageKey != "" {
+		storageKey = obj.storageKey
+	}
+
+	presigned, err := h.storage.PresignGetURL(ctx, storageKey, h.redirectExpiry)
+	if err != nil {
+		slog.Error("Failed to presign download, proxying instead", "filename", key, "storage_key", storageKey, "error", err)
+		h.writeFileResponse(w, r, key, obj)
+		return
+	}
+	obj.body.Close()
+
+	slog.Info("Redirecting large object to storage", "filename", key, "storage_key", storageKey, "size", obj.size)
+
+	// The URL expires, so neither the redirect nor the URL may be reused
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, presigned.URL, http.StatusFound)
+}
@@ -0,0 +1,81 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestGetFile_StorageRedirect(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("big.bin", []byte(strings.Repeat("x", 100)))
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithStorageRedirect(50, time.Minute))
+
+	rec := getFile(handler, "big.bin")
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Expected status 302, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Location"); got != "https://storage.example.com/big.bin?X-Amz-Signature=mock" {
+		t.Errorf("Expected the presigned URL, got '%s'", got)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Expected Cache-Control 'no-store', got '%s'", got)
+	}
+	if len(mockStorage.PresignGetCalls) != 1 || mockStorage.PresignGetCalls[0].Expiry != time.Minute {
+		t.Errorf("Expected one presign with a 1m expiry, got %v", mockStorage.PresignGetCalls)
+	}
+}
+
+func TestGetFile_StorageRedirect_FallbackPrefix(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("assets/v1/big.bin", []byte(strings.Repeat("x", 100)))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithStorageRedirect(50, time.Minute),
+		handlers.WithFallbackPrefixes(map[string][]string{"assets/v2/": {"assets/v1/"}}),
+	)
+
+	rec := getFile(handler, "assets/v2/big.bin")
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Expected status 302, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Location"); got != "https://storage.example.com/assets/v1/big.bin?X-Amz-Signature=mock" {
+		t.Errorf("Expected a presigned URL for the fallback key, got '%s'", got)
+	}
+}
+
+func TestGetFile_StorageRedirect_SmallObjectProxied(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("small.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithStorageRedirect(50, 0))
+
+	rec := getFile(handler, "small.txt")
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Errorf("Expected the proxied body, got %d '%s'", rec.Code, rec.Body.String())
+	}
+	if len(mockStorage.PresignGetCalls) != 0 {
+		t.Errorf("Expected no presign, got %d", len(mockStorage.PresignGetCalls))
+	}
+}
+
+func TestGetFile_StorageRedirect_PresignFailureProxies(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("big.bin", []byte(body))
+	mockStorage.PresignError = errors.New("presign failed")
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithStorageRedirect(50, 0))
+
+	rec := getFile(handler, "big.bin")
+
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Errorf("Expected the proxied body, got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/ch374n/file-downloader/internal/contextkeys"
+)
+
+// maxRequestIDLength caps a request ID accepted from the client
+const maxRequestIDLength = 128
+
+// RequestContext wraps next so every request carries a request ID and,
+// when the client sent a valid traceparent header, its trace on its
+// context. The ID is taken from X-Request-ID when the client sent a
+// usable one, e.g. from a load balancer, and generated otherwise, and is
+// echoed back in X-Request-ID.
+func RequestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := contextkeys.WithRequestID(r.Context(), id)
+		if trace, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+			ctx = contextkeys.WithTrace(ctx, trace)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID reports whether a client's request ID is safe to log and
+// echo: short, and only letters, digits, '-', '_' and '.'
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit request ID in hex
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// newSpanID returns a random 64-bit span ID in hex
+func newSpanID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// childSpan returns ctx with its trace, if it has one, moved to a new span
+// whose parent is ctx's, for work done on a request's behalf after it
+// returns
+func childSpan(ctx context.Context) context.Context {
+	trace, ok := contextkeys.TraceFrom(ctx)
+	if !ok {
+		return ctx
+	}
+	trace.ParentSpanID, trace.SpanID = trace.SpanID, newSpanID()
+	return contextkeys.WithTrace(ctx, trace)
+}
+
+// parseTraceparent parses a version 00 W3C traceparent header,
+// "00-<trace-id>-<parent-id>-<flags>"
+func parseTraceparent(header string) (contextkeys.Trace, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return contextkeys.Trace{}, false
+	}
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !lowerHex(traceID, 32) || !lowerHex(spanID, 16) || !lowerHex(flags, 2) ||
+		strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return contextkeys.Trace{}, false
+	}
+	flagBits, _ := hex.DecodeString(flags)
+	return contextkeys.Trace{TraceID: traceID, SpanID: spanID, Sampled: flagBits[0]&1 == 1}, true
+}
+
+// lowerHex reports whether s is n lowercase hex digits
+func lowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/contextkeys"
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestRequestContext_RequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientID string
+		wantEcho bool
+	}{
+		{"generated", "", false},
+		{"from client", "lb-1234.abc_DEF", true},
+		{"unsafe characters", "a b\nc", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := handlers.RequestContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = contextkeys.RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/files/a.txt", nil)
+			if tt.clientID != "" {
+				req.Header.Set("X-Request-ID", tt.clientID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get("X-Request-ID")
+			if got == "" || got != seen {
+				t.Fatalf("Expected the echoed ID %q to match the context's %q", got, seen)
+			}
+			if tt.wantEcho != (got == tt.clientID) {
+				t.Errorf("Expected client ID reused: %v, got %q", tt.wantEcho, got)
+			}
+			if !tt.wantEcho && len(got) != 32 {
+				t.Errorf("Expected a generated 32-character ID, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRequestContext_Traceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		wantOK      bool
+		wantSampled bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"missing", "", false, false},
+		{"unknown version", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"short span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trace contextkeys.Trace
+			var ok bool
+			handler := handlers.RequestContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace, ok = contextkeys.TraceFrom(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/files/a.txt", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if ok != tt.wantOK {
+				t.Fatalf("Expected trace parsed: %v, got %v", tt.wantOK, ok)
+			}
+			if !ok {
+				return
+			}
+			if trace.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || trace.SpanID != "00f067aa0ba902b7" {
+				t.Errorf("Expected the header's IDs, got %+v", trace)
+			}
+			if trace.Sampled != tt.wantSampled {
+				t.Errorf("Expected sampled %v, got %v", tt.wantSampled, trace.Sampled)
+			}
+		})
+	}
+}
+
+// contextCache hands the context of every cache write to writes
+type contextCache struct {
+	*mocks.MockCache
+	writes chan context.Context
+}
+
+func (c *contextCache) Set(ctx context.Context, key string, data []byte) error {
+	c.writes <- ctx
+	return c.MockCache.Set(ctx, key, data)
+}
+
+func (c *contextCache) SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	c.writes <- ctx
+	return c.MockCache.SetWithTTL(ctx, key, data, ttl)
+}
+
+func TestRequestContext_BackgroundCacheWrite(t *testing.T) {
+	mockCache := &contextCache{MockCache: mocks.NewMockCache(), writes: make(chan context.Context, 1)}
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	fileHandler := handlers.NewFileHandler(mockCache, mockStorage)
+	handler := handlers.RequestContext(http.HandlerFunc(fileHandler.GetFile))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/a.txt", nil)
+	req.SetPathValue("name", "a.txt")
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var ctx context.Context
+	select {
+	case ctx = <-mockCache.writes:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the object to be cached")
+	}
+
+	if got := contextkeys.RequestID(ctx); got != "req-1" {
+		t.Errorf("Expected the request ID on the cache write, got %q", got)
+	}
+	trace, ok := contextkeys.TraceFrom(ctx)
+	if !ok || trace.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("Expected the request's trace on the cache write, got %+v", trace)
+	}
+	if trace.ParentSpanID != "00f067aa0ba902b7" || len(trace.SpanID) != 16 || trace.SpanID == trace.ParentSpanID {
+		t.Errorf("Expected a child span of the request's, got %+v", trace)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultRetryAfter is sent with transient errors that carry no estimate
+// of their own
+const defaultRetryAfter = time.Second
+
+// retryAfterError is a transient error carrying how long the failing
+// subsystem expects to need before a retry can succeed
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// withRetryAfter attaches a recovery estimate to err
+func withRetryAfter(err error, after time.Duration) error {
+	return &retryAfterError{err: err, after: after}
+}
+
+// retryAfter returns the recovery estimate carried by err, or
+// defaultRetryAfter if it has none
+func retryAfter(err error) time.Duration {
+	var withAfter *retryAfterError
+	if errors.As(err, &withAfter) {
+		return withAfter.after
+	}
+	return defaultRetryAfter
+}
+
+// setRetryAfter tells the client to wait d before retrying. The header is
+// sent in whole seconds, rounded up and never below 1 so clients that
+// treat 0 as "retry now" don't hammer a recovering service.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	seconds := max(1, int64(math.Ceil(d.Seconds())))
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+}
+
+// writeUnavailable writes a 503 for a transient failure, with Retry-After
+// derived from err's recovery estimate
+func writeUnavailable(w http.ResponseWriter, err error, message string, data any) {
+	setRetryAfter(w, retryAfter(err))
+	writeJSON(w, http.StatusServiceUnavailable, Response{
+		Success: false,
+		Message: message,
+		Data:    data,
+	})
+}
@@ -0,0 +1,117 @@
+package handlers_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+// throttledError mimics the SDK error for a throttled storage response
+func throttledError(status int, retryAfter string) error {
+	header := http.Header{}
+	if retryAfter != "" {
+		header.Set("Retry-After", retryAfter)
+	}
+	return fmt.Errorf("failed to get object test.txt: %w", &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status, Header: header}},
+		Err:      errors.New("SlowDown: Please reduce your request rate"),
+	})
+}
+
+func assertRetryAfter(t *testing.T, rec *httptest.ResponseRecorder, want string) {
+	t.Helper()
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != want {
+		t.Errorf("Expected Retry-After %q, got %q", want, got)
+	}
+}
+
+func TestGetFile_RetryAfter_StorageThrottled(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		want       string
+	}{
+		{"seconds", http.StatusServiceUnavailable, "7", "7"},
+		{"http date", http.StatusServiceUnavailable, now.Add(30 * time.Second).Format(http.TimeFormat), "30"},
+		{"date in the past", http.StatusServiceUnavailable, now.Add(-time.Minute).Format(http.TimeFormat), "1"},
+		{"no header", http.StatusTooManyRequests, "", "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.GetError = throttledError(tt.status, tt.retryAfter)
+			handler := handlers.NewFileHandler(nil, mockStorage,
+				handlers.WithClock(mocks.NewMockClock(now)))
+
+			assertRetryAfter(t, getFile(handler, "test.txt"), tt.want)
+		})
+	}
+}
+
+func TestGetFile_RetryAfter_ConcurrencyLimit(t *testing.T) {
+	mockStorage := &stalledStorage{MockStorage: mocks.NewMockStorage(), release: make(chan struct{})}
+	mockStorage.SetObject("file.txt", []byte("content"))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithAdaptiveConcurrency(1, 1, 2500*time.Millisecond))
+
+	done := make(chan struct{})
+	go func() {
+		getFile(handler, "file.txt")
+		close(done)
+	}()
+	waitFor(t, func() bool { return mockStorage.started.Load() == 1 })
+
+	assertRetryAfter(t, getFile(handler, "file.txt"), "3")
+
+	close(mockStorage.release)
+	<-done
+}
+
+func TestGetFile_RetryAfter_MissStorm(t *testing.T) {
+	clk := mocks.NewMockClock(time.Unix(1_700_000_000, 0))
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("file.txt", []byte("content"))
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mockStorage,
+		handlers.WithClock(clk),
+		handlers.WithMissStormProtection(1, 1, 0),
+	)
+
+	getFile(handler, "file.txt")
+	clk.Advance(200 * time.Millisecond)
+
+	assertRetryAfter(t, getFile(handler, "other.txt"), "1")
+}
+
+func TestGetFile_RetryAfter_MemoryPressure(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("large.bin", make([]byte, 100))
+	handler := newPressuredHandler(nil, mockStorage)
+
+	assertRetryAfter(t, getFile(handler, "large.bin"), "1")
+}
+
+func TestHealth_RetryAfter_Unhealthy(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.HealthCheckError = errors.New("bucket unreachable")
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	rec := httptest.NewRecorder()
+	handler.Health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	assertRetryAfter(t, rec, "1")
+}
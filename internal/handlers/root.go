@@ -0,0 +1,26 @@
+package handlers
+
+// RootMode controls what the root path "/" responds with
+type RootMode string
+
+const (
+	// RootModeInfo serves the JSON service info. This is the default.
+	RootModeInfo RootMode = "info"
+
+	// RootModeHealth serves a plain "OK" without checking dependencies,
+	// for load balancers that probe "/"
+	RootModeHealth RootMode = "health"
+
+	// RootModeRedirect redirects to the configured URL, e.g. the docs
+	RootModeRedirect RootMode = "redirect"
+)
+
+// WithRootMode sets how "/" is served. redirectURL is the target for
+// RootModeRedirect; without one the info response is served. Other paths
+// falling through to the root handler always get the info response.
+func WithRootMode(mode RootMode, redirectURL string) Option {
+	return func(h *FileHandler) {
+		h.rootMode = mode
+		h.rootRedirectURL = redirectURL
+	}
+}
@@ -0,0 +1,80 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+var signingKey = []byte("test-secret")
+
+// newSignedServer serves the signed file route with a clock fixed at now
+func newSignedServer(t *testing.T, now time.Time, s *mocks.MockStorage) *httptest.Server {
+	t.Helper()
+	handler := handlers.NewFileHandler(nil, s,
+		handlers.WithClock(mocks.NewMockClock(now)),
+		handlers.WithSigningKey(signingKey),
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /s/{sig}/files/{name}", handler.SignedFile)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSignedFile(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("docs/a.txt", []byte("hello"))
+	mockStorage.SetObject("b.txt", []byte("other"))
+	server := newSignedServer(t, now, mockStorage)
+
+	valid := handlers.SignPath(signingKey, "docs/a.txt", now.Add(time.Hour))
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"expired", handlers.SignPath(signingKey, "docs/a.txt", now.Add(-time.Second)), http.StatusForbidden},
+		{"wrong secret", handlers.SignPath([]byte("other"), "docs/a.txt", now.Add(time.Hour)), http.StatusForbidden},
+		{"other key", strings.Replace(valid, "docs%2Fa.txt", "b.txt", 1), http.StatusForbidden},
+		{"extended expiry", "/s/" + "9999999999" + valid[strings.Index(valid, "."):], http.StatusForbidden},
+		{"malformed", "/s/garbage/files/b.txt", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatalf("%s: GET failed: %v", tt.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.wantStatus, resp.StatusCode)
+		}
+	}
+}
+
+func TestSignedFile_NoSigningKey(t *testing.T) {
+	now := time.Now()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	path := handlers.SignPath(nil, "a.txt", now.Add(time.Hour))
+	sig := strings.Split(path, "/")[2]
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.SetPathValue("sig", sig)
+	req.SetPathValue("name", "a.txt")
+	rec := httptest.NewRecorder()
+	handler.SignedFile(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/metrics"
+)
+
+// WithSlidingExpiration makes every cache hit reset the entry's TTL, so
+// objects stay cached for as long as they keep being read. An entry is
+// never kept past maxLifetime after it was cached, so hot objects are
+// still refetched from storage eventually. defaultTTL is the cache's
+// default TTL, used for keys without a TTL rule. Each hit costs an extra
+// Redis write; a maxLifetime of 0 disables sliding expiration.
+func WithSlidingExpiration(defaultTTL, maxLifetime time.Duration) Option {
+	return func(h *FileHandler) {
+		if defaultTTL > 0 && maxLifetime > 0 {
+			h.slidingTTL = defaultTTL
+			h.maxCacheLifetime = maxLifetime
+		}
+	}
+}
+
+// extendExpiration resets the TTL of obj, just read from the cache under
+// key, in the background. The TTL is shortened to end at the entry's
+// maximum lifetime, and entries cached without a timestamp are left alone
+// since their age is unknown.
+func (h *FileHandler) extendExpiration(ctx context.Context, key string, obj *entry) {
+	if h.maxCacheLifetime <= 0 || obj.CachedAt <= 0 {
+		return
+	}
+
+	ttl := h.cacheTTLForRequest(ctx, key)
+	if ttl <= 0 {
+		ttl = h.slidingTTL
+	}
+	remaining := time.Unix(obj.CachedAt, 0).Add(h.maxCacheLifetime).Sub(h.clock.Now())
+	if remaining < time.Second {
+		return
+	}
+	ttl = min(ttl, remaining)
+
+	go func() {
+		bgCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		start := time.Now()
+		if err := h.cache.Expire(bgCtx, key, ttl); err != nil {
+			slog.Error("Failed to extend cache TTL", "filename", key, "error", err)
+		}
+		h.metrics.ObserveHistogram(metrics.CacheOperationDuration, time.Since(start).Seconds(), metrics.Labels{"operation": "expire"})
+	}()
+}
@@ -0,0 +1,73 @@
+package handlers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestGetFile_SlidingExpiration(t *testing.T) {
+	clk := mocks.NewMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	mockStorage.SetObject("thumbs/a.jpg", []byte("thumb"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithClock(clk),
+		handlers.WithCacheTTLRules(map[string]time.Duration{"thumbs/": 10 * time.Minute}),
+		handlers.WithSlidingExpiration(time.Hour, 90*time.Minute),
+	)
+	getFile(handler, "a.txt")
+	getFile(handler, "thumbs/a.jpg")
+	waitFor(t, func() bool { return cached(mockCache, "a.txt") && cached(mockCache, "thumbs/a.jpg") })
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		key     string
+		wantTTL time.Duration
+	}{
+		{"default ttl", 10 * time.Minute, "a.txt", time.Hour},
+		{"ttl rule", 0, "thumbs/a.jpg", 10 * time.Minute},
+		{"capped by max lifetime", 50 * time.Minute, "a.txt", 30 * time.Minute},
+		{"past max lifetime", 30 * time.Minute, "a.txt", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk.Advance(tt.advance)
+			before := len(mockCache.Expirations())
+			getFile(handler, tt.key)
+
+			if tt.wantTTL == 0 {
+				time.Sleep(20 * time.Millisecond)
+				if got := mockCache.Expirations(); len(got) != before {
+					t.Errorf("Expected no TTL reset, got %v", got[before:])
+				}
+				return
+			}
+			waitFor(t, func() bool { return len(mockCache.Expirations()) == before+1 })
+			got := mockCache.Expirations()[before]
+			if got.Key != tt.key || got.TTL != tt.wantTTL {
+				t.Errorf("Expected TTL reset of %s to %v, got %s to %v", tt.key, tt.wantTTL, got.Key, got.TTL)
+			}
+		})
+	}
+}
+
+func TestGetFile_SlidingExpiration_Disabled(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage)
+	getFile(handler, "a.txt")
+	waitFor(t, func() bool { return cached(mockCache, "a.txt") })
+
+	getFile(handler, "a.txt")
+	time.Sleep(20 * time.Millisecond)
+	if got := mockCache.Expirations(); len(got) != 0 {
+		t.Errorf("Expected no TTL resets, got %v", got)
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLength is how much of a body is looked at to detect its type,
+// matching what http.DetectContentType considers
+const sniffLength = 512
+
+// ContentSniffer detects a content type from the first bytes of a body,
+// returning "application/octet-stream" when it can't tell
+type ContentSniffer func(head []byte) string
+
+// WithContentSniffing detects the type of objects whose key has one of
+// extensions (".dat", case-insensitive) from their first bytes, and serves
+// them with the detected type when it differs from the one the extension
+// suggests, e.g. a ".dat" that is really a PNG. A type stored with the
+// object still wins when stored content types are enabled. A nil sniffer
+// uses http.DetectContentType. Stored-compressed objects and ranges read
+// on their own are served by extension, since their first bytes aren't
+// the start of the content.
+func WithContentSniffing(extensions []string, sniffer ContentSniffer) Option {
+	return func(h *FileHandler) {
+		h.sniffExtensions = make(map[string]bool, len(extensions))
+		for _, ext := range extensions {
+			if ext = strings.ToLower(strings.TrimSpace(ext)); ext != "" {
+				if !strings.HasPrefix(ext, ".") {
+					ext = "." + ext
+				}
+				h.sniffExtensions[ext] = true
+			}
+		}
+		h.sniffer = sniffer
+		if h.sniffer == nil {
+			h.sniffer = http.DetectContentType
+		}
+	}
+}
+
+// sniffContentType returns the type detected from obj's first bytes when
+// filename's extension is configured for sniffing, or "" if there is
+// nothing to go by. A streamed body is peeked at, not consumed.
+func (h *FileHandler) sniffContentType(obj *entry, filename string) string {
+	if !h.sniffExtensions[strings.ToLower(filepath.Ext(filename))] {
+		return ""
+	}
+	if obj.ContentEncoding != "" || obj.window != nil {
+		return ""
+	}
+
+	var head []byte
+	if obj.body != nil {
+		head = peekBody(obj, sniffLength)
+	} else {
+		head = obj.Data[:min(len(obj.Data), sniffLength)]
+	}
+	if len(head) == 0 {
+		return ""
+	}
+
+	sniffed := h.sniffer(head)
+	if mediaType(sniffed) == "application/octet-stream" {
+		return ""
+	}
+	return sniffed
+}
+
+// peekBody returns up to n bytes from the start of obj's streamed body,
+// replacing the body with one that still yields them
+func peekBody(obj *entry, n int) []byte {
+	br := bufio.NewReaderSize(obj.body, n)
+	head, _ := br.Peek(n)
+	obj.body = struct {
+		io.Reader
+		io.Closer
+	}{br, obj.body}
+	return head
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/cache"
+	"github.com/ch374n/file-downloader/internal/metrics"
+)
+
+// staleKeyPrefix namespaces the fallback copies kept for auth failures
+const staleKeyPrefix = internalKeyPrefix + "stale:"
+
+// WithStaleOnAuthError keeps a second copy of every cached object for ttl,
+// which should be longer than the cache TTL. When storage rejects our
+// credentials on a cache miss, e.g. while R2 keys are being rotated, the
+// copy is served instead of failing. A ttl of 0 disables it.
+func WithStaleOnAuthError(ttl time.Duration) Option {
+	return func(h *FileHandler) {
+		h.staleTTL = ttl
+	}
+}
+
+// WithStaleMaxAge stops fallback copies from WithStaleOnAuthError being
+// served once they were cached more than maxAge ago, so a long outage
+// fails with the storage error rather than serving dangerously old
+// content. Copies cached without a timestamp are never served while it is
+// set. A maxAge of 0 serves copies for as long as they are kept.
+func WithStaleMaxAge(maxAge time.Duration) Option {
+	return func(h *FileHandler) {
+		h.staleMaxAge = maxAge
+	}
+}
+
+// cacheStale stores the fallback copy of key's encoded entry
+func (h *FileHandler) cacheStale(ctx context.Context, key string, value []byte) {
+	if h.staleTTL > 0 {
+		h.cacheInBackground(ctx, staleKeyPrefix+key, value, h.staleTTL, cache.Version{})
+	}
+}
+
+// fetchStale returns the fallback copy of key, if one is cached
+func (h *FileHandler) fetchStale(ctx context.Context, key string) (*entry, bool) {
+	if h.cache == nil || h.staleTTL <= 0 {
+		return nil, false
+	}
+
+	data, found, err := h.cache.Get(ctx, staleKeyPrefix+key)
+	if err != nil {
+		slog.Error("Cache error reading stale copy", "filename", key, "error", err)
+	}
+	if !found {
+		return nil, false
+	}
+
+	obj, err := decodeEntry(data)
+	if err != nil {
+		slog.Error("Discarding unreadable stale copy", "filename", key, "error", err)
+		return nil, false
+	}
+
+	obj.age = obj.cachedAge(h.clock.Now())
+	if h.staleMaxAge > 0 && (obj.age < 0 || obj.age > h.staleMaxAge) {
+		slog.Warn("Not serving stale copy older than the maximum age",
+			"filename", key, "age", obj.age, "max_age", h.staleMaxAge)
+		return nil, false
+	}
+
+	obj.stale = true
+	h.metrics.IncCounter(metrics.StaleServedTotal, nil)
+	slog.Warn("Serving stale copy after storage auth error", "filename", key)
+	return obj, true
+}
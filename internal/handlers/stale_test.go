@@ -0,0 +1,198 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+// authError mimics the SDK's response error for a rejected credential
+type authError struct{}
+
+func (authError) Error() string       { return "AccessDenied: Access Denied" }
+func (authError) HTTPStatusCode() int { return http.StatusForbidden }
+
+// stalePrefix is where the handler keeps stale copies in the cache
+const stalePrefix = "\x00fcs:stale:"
+
+// primeStale fetches key once so the fallback copy is cached, then drops
+// the regular entry as if its TTL had expired
+func primeStale(t *testing.T, c *mocks.MockCache, handler *handlers.FileHandler, key string) {
+	t.Helper()
+	getFile(handler, key)
+	waitFor(t, func() bool { return c.SetCallCount() == 2 })
+
+	stale, found, _ := c.Get(context.Background(), stalePrefix+key)
+	if !found {
+		t.Fatal("Expected a stale copy to be cached")
+	}
+	c.ClearData()
+	c.SetData(stalePrefix+key, stale)
+}
+
+func TestGetFile_StaleOnAuthError(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithStaleOnAuthError(time.Hour))
+
+	primeStale(t, mockCache, handler, "a.txt")
+	for _, call := range mockCache.SetCalls {
+		if call.Key == stalePrefix+"a.txt" && call.TTL != time.Hour {
+			t.Errorf("Expected stale TTL 1h, got %v", call.TTL)
+		}
+	}
+
+	mockStorage.GetError = authError{}
+	rec := getFile(handler, "a.txt")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if rec.Body.String() != "hello" {
+		t.Errorf("Expected body 'hello', got '%s'", rec.Body.String())
+	}
+}
+
+func TestGetFile_StaleOnAuthError_OtherErrorsFail(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithStaleOnAuthError(time.Hour))
+
+	primeStale(t, mockCache, handler, "a.txt")
+
+	mockStorage.GetError = errors.New("connection reset")
+	rec := getFile(handler, "a.txt")
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rec.Code)
+	}
+}
+
+func TestGetFile_AuthErrorWithoutStale(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.GetError = authError{}
+	handler := handlers.NewFileHandler(mockCache, mockStorage)
+
+	rec := getFile(handler, "a.txt")
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rec.Code)
+	}
+}
+
+func TestGetFile_CacheHitDuringAuthError(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockCache.SetData("a.txt", []byte("cached"))
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.GetError = authError{}
+	handler := handlers.NewFileHandler(mockCache, mockStorage)
+
+	rec := getFile(handler, "a.txt")
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if len(mockStorage.GetCalls) != 0 {
+		t.Errorf("Expected no storage calls, got %d", len(mockStorage.GetCalls))
+	}
+}
+
+func TestGetFile_StaleMaxAge(t *testing.T) {
+	tests := []struct {
+		name       string
+		elapsed    time.Duration
+		wantStatus int
+	}{
+		{"fresh", time.Minute, http.StatusOK},
+		{"at max age", 10 * time.Minute, http.StatusOK},
+		{"past max age", 10*time.Minute + time.Second, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := mocks.NewMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			mockCache := mocks.NewMockCache()
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject("a.txt", []byte("hello"))
+			handler := handlers.NewFileHandler(mockCache, mockStorage,
+				handlers.WithClock(clk),
+				handlers.WithStaleOnAuthError(time.Hour),
+				handlers.WithStaleMaxAge(10*time.Minute),
+			)
+
+			primeStale(t, mockCache, handler, "a.txt")
+			clk.Advance(tt.elapsed)
+			mockStorage.GetError = authError{}
+			rec := getFile(handler, "a.txt")
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			wantWarning := ""
+			if tt.wantStatus == http.StatusOK {
+				wantWarning = `112 - "Disconnected operation"`
+			}
+			if got := rec.Header().Get("Warning"); got != wantWarning {
+				t.Errorf("Expected Warning %q, got %q", wantWarning, got)
+			}
+		})
+	}
+}
+
+func TestGetFile_StaleMaxAge_UnknownAge(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockCache.SetData(stalePrefix+"a.txt", []byte("raw body cached without metadata"))
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.GetError = authError{}
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithStaleOnAuthError(time.Hour),
+		handlers.WithStaleMaxAge(10*time.Minute),
+	)
+
+	if rec := getFile(handler, "a.txt"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 for a stale copy of unknown age, got %d", rec.Code)
+	}
+}
+
+func TestGetFile_StaleCopyNotRequestable(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("private/secret.txt", []byte("secret"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithStaleOnAuthError(time.Hour))
+	primeStale(t, mockCache, handler, "private/secret.txt")
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{"old prefix", "stale:private/secret.txt", http.StatusNotFound},
+		{"internal prefix", stalePrefix + "private/secret.txt", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/files/"+url.PathEscape(tt.key), nil)
+			req.SetPathValue("name", tt.key)
+			rec := httptest.NewRecorder()
+			handler.GetFile(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if rec.Body.String() == "secret" {
+				t.Error("Expected the stale copy not to be served")
+			}
+		})
+	}
+}
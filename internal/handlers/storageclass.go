@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithSlowStorageClasses lists storage classes that are slow to retrieve
+// from, e.g. "STANDARD_IA". Objects fetched from one of them are served
+// with an X-Storage-Class header naming it, so callers can tell why the
+// response was slow. Cache hits never carry the header.
+func WithSlowStorageClasses(classes []string) Option {
+	return func(h *FileHandler) {
+		h.slowStorageClasses = make(map[string]bool, len(classes))
+		for _, class := range classes {
+			h.slowStorageClasses[strings.ToUpper(class)] = true
+		}
+	}
+}
+
+// setStorageClassHeader flags a response served from a slow storage class
+func (h *FileHandler) setStorageClassHeader(w http.ResponseWriter, obj *entry) {
+	if obj.storageClass != "" && h.slowStorageClasses[strings.ToUpper(obj.storageClass)] {
+		w.Header().Set("X-Storage-Class", obj.storageClass)
+	}
+}
@@ -0,0 +1,43 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+func TestGetFile_SlowStorageClassHeader(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("cold.bin", []byte("cold"))
+	mockStorage.SetObjectInfo("cold.bin", storage.ObjectInfo{StorageClass: "STANDARD_IA"})
+	mockStorage.SetObject("hot.bin", []byte("hot"))
+	mockStorage.SetObjectInfo("hot.bin", storage.ObjectInfo{StorageClass: "STANDARD"})
+	handler := handlers.NewFileHandler(mockCache, mockStorage,
+		handlers.WithSlowStorageClasses([]string{"standard_ia"}))
+
+	if got := getFile(handler, "cold.bin").Header().Get("X-Storage-Class"); got != "STANDARD_IA" {
+		t.Errorf("Expected X-Storage-Class 'STANDARD_IA', got '%s'", got)
+	}
+	if got := getFile(handler, "hot.bin").Header().Get("X-Storage-Class"); got != "" {
+		t.Errorf("Expected no X-Storage-Class for a standard object, got '%s'", got)
+	}
+
+	waitFor(t, func() bool { return mockCache.SetCallCount() == 2 })
+	if got := getFile(handler, "cold.bin").Header().Get("X-Storage-Class"); got != "" {
+		t.Errorf("Expected no X-Storage-Class on a cache hit, got '%s'", got)
+	}
+}
+
+func TestGetFile_SlowStorageClassHeader_Disabled(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("cold.bin", []byte("cold"))
+	mockStorage.SetObjectInfo("cold.bin", storage.ObjectInfo{StorageClass: "STANDARD_IA"})
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	if got := getFile(handler, "cold.bin").Header().Get("X-Storage-Class"); got != "" {
+		t.Errorf("Expected no X-Storage-Class by default, got '%s'", got)
+	}
+}
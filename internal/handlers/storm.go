@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/clock"
+	"github.com/ch374n/file-downloader/internal/metrics"
+)
+
+// errLoadShed is returned when a request is rejected to protect storage
+var errLoadShed = errors.New("request shed to protect storage")
+
+// WithMissStormProtection enables admission control for cache misses. When
+// more than threshold misses arrive within one second (e.g. right after a
+// cache flush), shedFraction of further misses are rejected with 503 and
+// the rest are delayed by a random jitter of up to maxDelay before going
+// to storage. A threshold of 0 disables the protection.
+func WithMissStormProtection(threshold int, shedFraction float64, maxDelay time.Duration) Option {
+	return func(h *FileHandler) {
+		if threshold <= 0 {
+			h.missStorm = nil
+			return
+		}
+		h.missStorm = &missStormGuard{
+			threshold:    threshold,
+			shedFraction: shedFraction,
+			maxDelay:     maxDelay,
+		}
+	}
+}
+
+// missStormGuard detects bursts of cache misses and spreads or sheds them
+type missStormGuard struct {
+	threshold    int
+	shedFraction float64
+	maxDelay     time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	misses      int
+}
+
+// admit records a cache miss and reports whether it may go to storage
+func (g *missStormGuard) admit(ctx context.Context, clk clock.Clock, m metrics.Metrics) bool {
+	if g == nil || !g.record(clk.Now(), m) {
+		return true
+	}
+
+	if rand.Float64() < g.shedFraction {
+		m.IncCounter(metrics.MissStormShedTotal, nil)
+		return false
+	}
+
+	if g.maxDelay > 0 {
+		select {
+		case <-ctx.Done():
+		case <-clk.After(rand.N(g.maxDelay)):
+		}
+	}
+	return true
+}
+
+// windowRemaining returns how long until the current one-second window
+// ends and misses are counted afresh
+func (g *missStormGuard) windowRemaining(now time.Time) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return max(0, g.windowStart.Add(time.Second).Sub(now))
+}
+
+// record counts a miss and reports whether the current one-second window
+// is over the threshold
+func (g *missStormGuard) record(now time.Time, m metrics.Metrics) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.windowStart) >= time.Second {
+		g.windowStart = now
+		g.misses = 0
+	}
+	g.misses++
+
+	storm := g.misses > g.threshold
+	if storm {
+		m.SetGauge(metrics.MissStormActive, 1, nil)
+	} else {
+		m.SetGauge(metrics.MissStormActive, 0, nil)
+	}
+	return storm
+}
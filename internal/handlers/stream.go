@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// defaultResponseBufferSize is how many bytes of a streamed response are
+// collected before they are written to the client
+const defaultResponseBufferSize = 32 << 10
+
+// WithMaxBufferedSize sets the largest object, in bytes, that is read into
+// memory. Larger objects are streamed from storage straight to the client
+// and never cached, and larger cache hits are written in chunks rather than
+// in one call. Range requests aren't honored for objects streamed from
+// storage, except a single range of a larger object, which is read from
+// storage on its own. 0 buffers every object.
+func WithMaxBufferedSize(n int64) Option {
+	return func(h *FileHandler) {
+		if n >= 0 {
+			h.maxBufferedSize = n
+		}
+	}
+}
+
+// WithResponseBufferSize sets how many bytes of a streamed response are
+// collected before they are written to the client, so a body read from
+// storage in small pieces reaches the connection in a few large writes.
+// Objects held in memory are still written in one call. 0 writes every
+// piece as soon as it is read.
+func WithResponseBufferSize(n int) Option {
+	return func(h *FileHandler) {
+		if n >= 0 {
+			h.responseBufferSize = n
+		}
+	}
+}
+
+// streamThreshold returns the size above which objects are left unbuffered,
+// either to stream them or to redirect to storage, or 0 to buffer all
+func (h *FileHandler) streamThreshold() int64 {
+	limit := h.maxBufferedSize
+	if h.redirectThreshold > 0 && (limit <= 0 || h.redirectThreshold < limit) {
+		limit = h.redirectThreshold
+	}
+	return limit
+}
+
+// getObject reads key from storage. Objects larger than the stream
+// threshold are returned with their body still open instead of their data.
+func (h *FileHandler) getObject(ctx context.Context, key string) (*entry, error) {
+	limit := h.streamThreshold()
+	if limit <= 0 {
+		data, info, err := h.storage.GetObjectWithInfo(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.checkLength(ctx, key, int64(len(data)), info.Size); err != nil {
+			return nil, err
+		}
+		obj := &entry{
+			Data:            data,
+			ContentType:     specificContentType(info.ContentType),
+			ContentEncoding: info.ContentEncoding,
+			CacheControl:    info.CacheControl,
+			ETag:            info.ETag,
+			modified:        info.LastModified,
+			storageClass:    info.StorageClass,
+		}
+		h.ensureETag(obj)
+		if err := h.transform(ctx, key, obj); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+
+	if obj, ok, err := h.getObjectRange(ctx, key, limit); ok || err != nil {
+		return obj, err
+	}
+
+	body, info, err := h.storage.GetObjectStream(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	body = h.checkedBody(ctx, key, body, info.Size)
+
+	obj := &entry{
+		ContentType:     specificContentType(info.ContentType),
+		ContentEncoding: info.ContentEncoding,
+		CacheControl:    info.CacheControl,
+		ETag:            info.ETag,
+		modified:        info.LastModified,
+		storageClass:    info.StorageClass,
+	}
+	if info.Size > limit {
+		obj.body, obj.size = body, info.Size
+		h.ensureETag(obj)
+		return obj, nil
+	}
+
+	defer body.Close()
+	obj.Data, err = io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object body: %w", err)
+	}
+	h.ensureETag(obj)
+	if err := h.transform(ctx, key, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// writeStream copies a size-byte body to w
+func (h *FileHandler) writeStream(w http.ResponseWriter, filename, contentType string, body io.Reader, size int64) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are already sent, so a failure here can only be logged
+	if err := h.copyResponse(w, body); err != nil {
+		slog.Error("Failed to stream object", "filename", filename, "error", err)
+	}
+}
+
+// copyResponse copies body to w in chunks of streamChunkSize, collecting
+// them into writes of the response buffer size
+func (h *FileHandler) copyResponse(w io.Writer, body io.Reader) error {
+	buf := streamBuffers.Get().(*[]byte)
+	defer streamBuffers.Put(buf)
+
+	if h.responseBufferSize <= 0 {
+		_, err := io.CopyBuffer(struct{ io.Writer }{w}, body, *buf)
+		return err
+	}
+
+	bw := bufio.NewWriterSize(w, h.responseBufferSize)
+	if _, err := io.CopyBuffer(struct{ io.Writer }{bw}, body, *buf); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,322 @@
+package handlers_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+func TestGetFile_MaxBufferedSize_StreamsLargeObject(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("big.bin", []byte(body))
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithMaxBufferedSize(50))
+
+	rec := getFile(handler, "big.bin")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if rec.Body.String() != body {
+		t.Errorf("Expected the full body, got %d bytes", rec.Body.Len())
+	}
+	if got := rec.Header().Get("Content-Length"); got != "100" {
+		t.Errorf("Expected Content-Length 100, got '%s'", got)
+	}
+	if got := rec.Header().Get("Accept-Ranges"); got != "bytes" {
+		t.Errorf("Expected Accept-Ranges 'bytes' for a streamed object, got '%s'", got)
+	}
+
+	// Give a background cache write time to show up if there were one
+	time.Sleep(20 * time.Millisecond)
+	if mockCache.SetCallCount() != 0 {
+		t.Errorf("Expected large object not to be cached, got %d sets", mockCache.SetCallCount())
+	}
+}
+
+func TestGetFile_MaxBufferedSize_CachesSmallObject(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("small.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithMaxBufferedSize(50))
+
+	rec := getFile(handler, "small.txt")
+
+	if rec.Body.String() != "hello" {
+		t.Errorf("Expected body 'hello', got '%s'", rec.Body.String())
+	}
+	waitFor(t, func() bool { return mockCache.SetCallCount() == 1 })
+}
+
+func TestGetFile_MaxBufferedSize_LargeCacheHit(t *testing.T) {
+	body := strings.Repeat("y", 100<<10)
+	mockCache := mocks.NewMockCache()
+	mockCache.SetData("big.bin", []byte(body))
+	handler := handlers.NewFileHandler(mockCache, mocks.NewMockStorage(), handlers.WithMaxBufferedSize(1024))
+
+	rec := getFile(handler, "big.bin")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if rec.Body.String() != body {
+		t.Errorf("Expected the full body, got %d bytes", rec.Body.Len())
+	}
+	if got := rec.Header().Get("Content-Length"); got != "102400" {
+		t.Errorf("Expected Content-Length 102400, got '%s'", got)
+	}
+}
+
+func TestGetFile_MaxBufferedSize_DecompressesStream(t *testing.T) {
+	plain := strings.Repeat("z", 1000)
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(plain))
+	zw.Close()
+
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("big.txt", compressed.Bytes())
+	mockStorage.SetObjectInfo("big.txt", storage.ObjectInfo{ContentEncoding: "gzip"})
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithMaxBufferedSize(10),
+		handlers.WithGzipDecompression(true),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/files/big.txt", nil)
+	req.SetPathValue("name", "big.txt")
+	rec := httptest.NewRecorder()
+	handler.GetFile(rec, req)
+
+	got, _ := io.ReadAll(rec.Body)
+	if string(got) != plain {
+		t.Errorf("Expected the inflated body, got %d bytes", len(got))
+	}
+}
+
+func TestGetFile_MaxBufferedSize_RangeFetchesOnlyWindow(t *testing.T) {
+	body := strings.Repeat("0123456789", 10)
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("big.bin", []byte(body))
+	mockStorage.SetObjectInfo("big.bin", storage.ObjectInfo{ETag: `"v1"`})
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithMaxBufferedSize(50))
+
+	tests := []struct {
+		rangeHeader  string
+		wantBody     string
+		contentRange string
+	}{
+		{"bytes=10-14", "01234", "bytes 10-14/100"},
+		{"bytes=-3", "789", "bytes 97-99/100"},
+		{"bytes=95-", "56789", "bytes 95-99/100"},
+	}
+	for _, tt := range tests {
+		rec := getFileWithHeader(handler, "big.bin", http.Header{"Range": {tt.rangeHeader}})
+
+		if rec.Code != http.StatusPartialContent {
+			t.Fatalf("%s: expected status %d, got %d", tt.rangeHeader, http.StatusPartialContent, rec.Code)
+		}
+		if rec.Body.String() != tt.wantBody {
+			t.Errorf("%s: expected body %q, got %q", tt.rangeHeader, tt.wantBody, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Range"); got != tt.contentRange {
+			t.Errorf("%s: expected Content-Range %q, got %q", tt.rangeHeader, tt.contentRange, got)
+		}
+		if got := rec.Header().Get("ETag"); got != `"v1"` {
+			t.Errorf("%s: expected the object's ETag, got %q", tt.rangeHeader, got)
+		}
+	}
+
+	// Only the windows were read, and none of them cached
+	if got := len(mockStorage.RangeCalls); got != len(tests) {
+		t.Errorf("Expected %d ranged reads, got %d", len(tests), got)
+	}
+	if got := mockStorage.RangeCalls[0]; got.Start != 10 || got.Length != 5 {
+		t.Errorf("Expected a read of 5 bytes at 10, got %d at %d", got.Length, got.Start)
+	}
+	// Only the suffix range needed the object's size first
+	if got := len(mockStorage.StatCalls); got != 1 {
+		t.Errorf("Expected 1 stat call, got %d", got)
+	}
+	if got := len(mockStorage.GetCalls); got != 0 {
+		t.Errorf("Expected no whole-object reads, got %d", got)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if mockCache.SetCallCount() != 0 {
+		t.Errorf("Expected ranges not to be cached, got %d sets", mockCache.SetCallCount())
+	}
+}
+
+func TestGetFile_MaxBufferedSize_RangeReadsWholeObject(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	tests := []struct {
+		name       string
+		data       []byte
+		info       storage.ObjectInfo
+		header     http.Header
+		wantStatus int
+		// Ranges with a start are tried before the object's size is known
+		wantRangeCalls int
+	}{
+		{"several ranges", []byte(big), storage.ObjectInfo{},
+			http.Header{"Range": {"bytes=0-1,5-6"}}, http.StatusOK, 0},
+		{"If-Range", []byte(big), storage.ObjectInfo{ETag: `"v1"`},
+			http.Header{"Range": {"bytes=0-1"}, "If-Range": {`"v1"`}}, http.StatusOK, 0},
+		{"unsatisfiable", []byte(big), storage.ObjectInfo{},
+			http.Header{"Range": {"bytes=500-"}}, http.StatusRequestedRangeNotSatisfiable, 1},
+		{"small object", []byte("0123456789"), storage.ObjectInfo{},
+			http.Header{"Range": {"bytes=0-1"}}, http.StatusPartialContent, 1},
+		{"small object suffix", []byte("0123456789"), storage.ObjectInfo{},
+			http.Header{"Range": {"bytes=-2"}}, http.StatusPartialContent, 0},
+		{"stored gzip", []byte(big), storage.ObjectInfo{ContentEncoding: "gzip"},
+			http.Header{"Range": {"bytes=0-1"}, "Accept-Encoding": {"gzip"}}, http.StatusOK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject("f.bin", tt.data)
+			mockStorage.SetObjectInfo("f.bin", tt.info)
+			handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithMaxBufferedSize(50))
+
+			rec := getFileWithHeader(handler, "f.bin", tt.header)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := len(mockStorage.RangeCalls); got != tt.wantRangeCalls {
+				t.Errorf("Expected %d ranged reads, got %d", tt.wantRangeCalls, got)
+			}
+			if got := len(mockStorage.GetCalls); got != 1 {
+				t.Errorf("Expected 1 whole-object read, got %d", got)
+			}
+		})
+	}
+}
+
+// trickleStorage returns streamed bodies one byte per read, like a slow
+// connection to storage
+type trickleStorage struct {
+	*mocks.MockStorage
+}
+
+func (s *trickleStorage) GetObjectStream(ctx context.Context, key string) (io.ReadCloser, storage.ObjectInfo, error) {
+	body, info, err := s.MockStorage.GetObjectStream(ctx, key)
+	if err != nil {
+		return nil, info, err
+	}
+	return io.NopCloser(iotest.OneByteReader(body)), info, nil
+}
+
+// writeCounter counts the writes a response is sent in
+type writeCounter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *writeCounter) Write(b []byte) (int, error) {
+	w.writes++
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestGetFile_ResponseBufferSize_CollectsSmallReads(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	tests := []struct {
+		name       string
+		size       int
+		wantWrites int
+	}{
+		{"buffered", 64, 2},
+		{"unbuffered", 0, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject("big.bin", []byte(body))
+			handler := handlers.NewFileHandler(nil, &trickleStorage{mockStorage},
+				handlers.WithMaxBufferedSize(50),
+				handlers.WithResponseBufferSize(tt.size),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/files/big.bin", nil)
+			req.SetPathValue("name", "big.bin")
+			w := &writeCounter{ResponseRecorder: httptest.NewRecorder()}
+			handler.GetFile(w, req)
+
+			if w.Body.String() != body {
+				t.Errorf("Expected the full body, got %d bytes", w.Body.Len())
+			}
+			if w.writes != tt.wantWrites {
+				t.Errorf("Expected %d writes, got %d", tt.wantWrites, w.writes)
+			}
+		})
+	}
+}
+
+// BenchmarkGetFile_StreamedSmallReads serves a 64 KiB object that storage
+// returns 1 KiB at a time over a real connection, so the cost of each
+// write to the socket shows
+func BenchmarkGetFile_StreamedSmallReads(b *testing.B) {
+	for _, size := range []int{0, 32 << 10} {
+		b.Run(fmt.Sprintf("buffer=%d", size), func(b *testing.B) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject("f.bin", bytes.Repeat([]byte("x"), 64<<10))
+			handler := handlers.NewFileHandler(nil, &chunkedStorage{mockStorage, 1 << 10},
+				handlers.WithMaxBufferedSize(1),
+				handlers.WithResponseBufferSize(size),
+			)
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /files/{name}", handler.GetFile)
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			b.SetBytes(64 << 10)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				resp, err := http.Get(server.URL + "/files/f.bin")
+				if err != nil {
+					b.Fatal(err)
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		})
+	}
+}
+
+// chunkedStorage returns streamed bodies at most chunk bytes per read
+type chunkedStorage struct {
+	*mocks.MockStorage
+	chunk int
+}
+
+func (s *chunkedStorage) GetObjectStream(ctx context.Context, key string) (io.ReadCloser, storage.ObjectInfo, error) {
+	body, info, err := s.MockStorage.GetObjectStream(ctx, key)
+	if err != nil {
+		return nil, info, err
+	}
+	return io.NopCloser(&chunkedReader{body, s.chunk}), info, nil
+}
+
+type chunkedReader struct {
+	r     io.Reader
+	chunk int
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	return r.r.Read(p[:min(len(p), r.chunk)])
+}
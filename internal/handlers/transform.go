@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Transformer post-processes an object after it is read from storage and
+// before it is cached and served, e.g. to strip EXIF data from images. It
+// returns the new body and Content-Type; an empty type keeps the old one.
+// An error fails the request rather than serving the untransformed object.
+type Transformer interface {
+	Transform(ctx context.Context, key, contentType string, data []byte) ([]byte, string, error)
+}
+
+// TransformerFunc adapts a function to a Transformer
+type TransformerFunc func(ctx context.Context, key, contentType string, data []byte) ([]byte, string, error)
+
+// Transform calls f
+func (f TransformerFunc) Transform(ctx context.Context, key, contentType string, data []byte) ([]byte, string, error) {
+	return f(ctx, key, contentType, data)
+}
+
+// IdentityTransformer returns objects unchanged
+type IdentityTransformer struct{}
+
+// Transform returns data and contentType as they are
+func (IdentityTransformer) Transform(_ context.Context, _, contentType string, data []byte) ([]byte, string, error) {
+	return data, contentType, nil
+}
+
+// WithTransformers post-processes objects by their Content-Type. Rules are
+// keyed by a media type ("text/html") or a family ("image/*"), the more
+// specific rule winning; objects matching no rule are served unchanged.
+// Only objects read into memory are transformed, never streamed or
+// compressed ones. Transformed bodies are what gets cached, so bump the
+// cache version after changing a transformer.
+func WithTransformers(rules map[string]Transformer) Option {
+	return func(h *FileHandler) {
+		h.transformers = make(map[string]Transformer, len(rules))
+		for match, t := range rules {
+			h.transformers[strings.ToLower(strings.TrimSpace(match))] = t
+		}
+	}
+}
+
+// transformerFor returns the transformer for objects of contentType, or nil
+func (h *FileHandler) transformerFor(contentType string) Transformer {
+	if len(h.transformers) == 0 {
+		return nil
+	}
+	t := mediaType(contentType)
+	if transformer, ok := h.transformers[t]; ok {
+		return transformer
+	}
+	family, _, _ := strings.Cut(t, "/")
+	return h.transformers[family+"/*"]
+}
+
+// transform applies the matching transformer to obj, just read from
+// storage as key. A body that changes no longer matches the storage ETag
+// byte for byte, so the ETag is weakened.
+func (h *FileHandler) transform(ctx context.Context, key string, obj *entry) error {
+	if obj.body != nil || obj.ContentEncoding != "" {
+		return nil
+	}
+	contentType := h.contentTypeOf(obj, key)
+	transformer := h.transformerFor(contentType)
+	if transformer == nil {
+		return nil
+	}
+
+	data, newType, err := transformer.Transform(ctx, key, contentType, obj.Data)
+	if err != nil {
+		return fmt.Errorf("failed to transform object: %w", err)
+	}
+	if newType != "" && newType != contentType {
+		obj.ContentType = newType
+	}
+	if !bytes.Equal(data, obj.Data) && obj.ETag != "" && !strings.HasPrefix(obj.ETag, "W/") {
+		obj.ETag = "W/" + obj.ETag
+	}
+	obj.Data = data
+	return nil
+}
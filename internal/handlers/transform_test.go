@@ -0,0 +1,105 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+var upper = handlers.TransformerFunc(func(_ context.Context, _, contentType string, data []byte) ([]byte, string, error) {
+	return bytes.ToUpper(data), contentType, nil
+})
+
+func TestGetFile_Transform_ByMediaTypeAndFamily(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	mockStorage.SetObject("b.csv", []byte("x,y"))
+	mockStorage.SetObject("c.json", []byte(`{"a":1}`))
+	mockStorage.SetObjectInfo("a.txt", storage.ObjectInfo{ETag: `"abc"`})
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithTransformers(map[string]handlers.Transformer{
+		"text/*":    upper,
+		"text/csv":  handlers.IdentityTransformer{},
+		"image/png": upper,
+	}))
+
+	rec := getFile(handler, "a.txt")
+	if rec.Body.String() != "HELLO" {
+		t.Errorf("Expected the family rule to apply, got %q", rec.Body.String())
+	}
+	if got := rec.Header().Get("ETag"); got != `W/"abc"` {
+		t.Errorf("Expected a weakened ETag, got %q", got)
+	}
+	if got := getFile(handler, "b.csv").Body.String(); got != "x,y" {
+		t.Errorf("Expected the media type rule to win over the family, got %q", got)
+	}
+	if got := getFile(handler, "c.json").Body.String(); got != `{"a":1}` {
+		t.Errorf("Expected an unmatched type to be served unchanged, got %q", got)
+	}
+
+	// The transformed body is what gets cached
+	waitFor(t, func() bool { return cached(mockCache, "a.txt") })
+	if got := getFile(handler, "a.txt").Body.String(); got != "HELLO" {
+		t.Errorf("Expected the cached body to be transformed, got %q", got)
+	}
+	if got := len(mockStorage.GetCalls); got != 3 {
+		t.Errorf("Expected 3 storage reads, got %d", got)
+	}
+}
+
+func TestGetFile_Transform_ChangesContentType(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithTransformers(map[string]handlers.Transformer{
+		"text/plain": handlers.TransformerFunc(func(_ context.Context, _, _ string, data []byte) ([]byte, string, error) {
+			return []byte("<p>" + string(data) + "</p>"), "text/html", nil
+		}),
+	}))
+
+	rec := getFile(handler, "a.txt")
+
+	if got := rec.Header().Get("Content-Type"); got != "text/html" {
+		t.Errorf("Expected Content-Type 'text/html', got %q", got)
+	}
+	if rec.Body.String() != "<p>hello</p>" {
+		t.Errorf("Expected the transformed body, got %q", rec.Body.String())
+	}
+}
+
+func TestGetFile_Transform_Error(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("hello"))
+	handler := handlers.NewFileHandler(nil, mockStorage, handlers.WithTransformers(map[string]handlers.Transformer{
+		"text/*": handlers.TransformerFunc(func(context.Context, string, string, []byte) ([]byte, string, error) {
+			return nil, "", errors.New("boom")
+		}),
+	}))
+
+	rec := getFile(handler, "a.txt")
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+}
+
+func TestGetFile_Transform_SkipsStreamedAndEncoded(t *testing.T) {
+	mockStorage, compressed := newGzipStorage(t, []byte("<p>hello</p>"))
+	mockStorage.SetObject("big.txt", bytes.Repeat([]byte("x"), 100))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithMaxBufferedSize(50),
+		handlers.WithTransformers(map[string]handlers.Transformer{"text/*": upper}),
+	)
+
+	if got := getFile(handler, "big.txt").Body.String(); got != string(bytes.Repeat([]byte("x"), 100)) {
+		t.Errorf("Expected a streamed object to be served unchanged, got %q", got)
+	}
+	if rec := getFileWithHeader(handler, "page.html", http.Header{"Accept-Encoding": {"gzip"}}); !bytes.Equal(rec.Body.Bytes(), compressed) {
+		t.Errorf("Expected a compressed object to be served unchanged")
+	}
+}
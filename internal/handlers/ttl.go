@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheTTLRule sets the cache TTL for keys starting with prefix
+type cacheTTLRule struct {
+	prefix string
+	ttl    time.Duration
+}
+
+// WithCacheTTLRules overrides the cache TTL for keys under the given
+// prefixes, e.g. {"thumbs/": 24h, "live/": 10s}. The longest matching prefix
+// wins; keys matching no rule use the cache's default TTL. A leading "/" on
+// a prefix is ignored since storage keys don't start with one.
+func WithCacheTTLRules(rules map[string]time.Duration) Option {
+	return func(h *FileHandler) {
+		h.cacheTTLRules = make([]cacheTTLRule, 0, len(rules))
+		for prefix, ttl := range rules {
+			if ttl <= 0 {
+				continue
+			}
+			h.cacheTTLRules = append(h.cacheTTLRules, cacheTTLRule{
+				prefix: strings.TrimPrefix(prefix, "/"),
+				ttl:    ttl,
+			})
+		}
+		sort.Slice(h.cacheTTLRules, func(i, j int) bool {
+			return len(h.cacheTTLRules[i].prefix) > len(h.cacheTTLRules[j].prefix)
+		})
+	}
+}
+
+// cacheTTLFor returns the TTL to cache key with, or 0 for the default
+func (h *FileHandler) cacheTTLFor(key string) time.Duration {
+	for _, rule := range h.cacheTTLRules {
+		if strings.HasPrefix(key, rule.prefix) {
+			return rule.ttl
+		}
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package handlers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestGetFile_CacheTTLRules(t *testing.T) {
+	rules := map[string]time.Duration{
+		"/thumbs/":      24 * time.Hour,
+		"thumbs/small/": time.Hour,
+		"live/":         10 * time.Second,
+	}
+
+	tests := []struct {
+		key     string
+		wantTTL time.Duration
+	}{
+		{"thumbs/a.png", 24 * time.Hour},
+		{"thumbs/small/a.png", time.Hour},
+		{"live/feed.json", 10 * time.Second},
+		{"other/file.txt", 0},
+	}
+
+	for _, tt := range tests {
+		mockCache := mocks.NewMockCache()
+		mockStorage := mocks.NewMockStorage()
+		mockStorage.SetObject(tt.key, []byte("data"))
+		handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithCacheTTLRules(rules))
+
+		getFile(handler, tt.key)
+		waitFor(t, func() bool { return mockCache.SetCallCount() == 1 })
+
+		ttl := mockCache.SetCalls[0].TTL
+		if ttl != tt.wantTTL {
+			t.Errorf("%s: expected TTL %v, got %v", tt.key, tt.wantTTL, ttl)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheTTLKey is the context key for a request's cache TTL override
+type cacheTTLKey struct{}
+
+var errInvalidCacheTTL = errors.New("invalid X-Cache-TTL")
+
+// WithCacheTTLHeader lets trusted callers shorten or set the TTL a cache
+// miss is stored with, by sending X-Cache-TTL ("30s" or a number of
+// seconds) along with "Authorization: Bearer <token>". Values are capped
+// at max. The header is ignored from other callers, when the cache is
+// disabled, and when max is 0.
+func WithCacheTTLHeader(max time.Duration, token string) Option {
+	return func(h *FileHandler) {
+		h.ttlHeaderMax = max
+		h.ttlHeaderToken = token
+	}
+}
+
+// requestCacheTTL returns the TTL override asked for by r, or 0 for none.
+// It fails only for a trusted caller sending a malformed value.
+func (h *FileHandler) requestCacheTTL(r *http.Request) (time.Duration, error) {
+	value := strings.TrimSpace(r.Header.Get("X-Cache-TTL"))
+	if value == "" || h.cache == nil || h.ttlHeaderMax <= 0 || !hasBearerToken(r, h.ttlHeaderToken) {
+		return 0, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, errInvalidCacheTTL
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if ttl <= 0 {
+		return 0, errInvalidCacheTTL
+	}
+	return min(ttl, h.ttlHeaderMax), nil
+}
+
+// withCacheTTL returns ctx carrying a cache TTL override for the request
+func withCacheTTL(ctx context.Context, ttl time.Duration) context.Context {
+	if ttl <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, cacheTTLKey{}, ttl)
+}
+
+// cacheTTLForRequest returns the TTL to cache key with on this request:
+// the request's override if it has one, otherwise the prefix rules
+func (h *FileHandler) cacheTTLForRequest(ctx context.Context, key string) time.Duration {
+	if ttl, ok := ctx.Value(cacheTTLKey{}).(time.Duration); ok {
+		return ttl
+	}
+	return h.cacheTTLFor(key)
+}
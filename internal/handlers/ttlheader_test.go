@@ -0,0 +1,78 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+func TestGetFile_CacheTTLHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     string
+		token   string
+		wantTTL time.Duration
+	}{
+		{"duration", "30s", "secret", 30 * time.Second},
+		{"seconds", "45", "secret", 45 * time.Second},
+		{"capped at max", "2h", "secret", time.Hour},
+		{"unauthorized ignored", "30s", "", 0},
+		{"wrong token ignored", "30s", "guess", 0},
+	}
+
+	for _, tt := range tests {
+		mockCache := mocks.NewMockCache()
+		mockStorage := mocks.NewMockStorage()
+		mockStorage.SetObject("a.txt", []byte("data"))
+		handler := handlers.NewFileHandler(mockCache, mockStorage,
+			handlers.WithCacheTTLHeader(time.Hour, "secret"))
+
+		header := http.Header{}
+		header.Set("X-Cache-TTL", tt.ttl)
+		if tt.token != "" {
+			header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := getFileWithHeader(handler, "a.txt", header)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tt.name, rec.Code)
+		}
+		waitFor(t, func() bool { return mockCache.SetCallCount() == 1 })
+
+		if ttl := mockCache.SetCalls[0].TTL; ttl != tt.wantTTL {
+			t.Errorf("%s: expected TTL %v, got %v", tt.name, tt.wantTTL, ttl)
+		}
+	}
+}
+
+func TestGetFile_CacheTTLHeader_Invalid(t *testing.T) {
+	for _, ttl := range []string{"soon", "-5s", "0"} {
+		mockStorage := mocks.NewMockStorage()
+		mockStorage.SetObject("a.txt", []byte("data"))
+		handler := handlers.NewFileHandler(mocks.NewMockCache(), mockStorage,
+			handlers.WithCacheTTLHeader(time.Hour, "secret"))
+
+		header := http.Header{"Authorization": {"Bearer secret"}}
+		header.Set("X-Cache-TTL", ttl)
+		rec := getFileWithHeader(handler, "a.txt", header)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", ttl, rec.Code)
+		}
+	}
+}
+
+func TestGetFile_CacheTTLHeader_IgnoredWithoutCache(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("data"))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithCacheTTLHeader(time.Hour, "secret"))
+
+	header := http.Header{"Authorization": {"Bearer secret"}}
+	header.Set("X-Cache-TTL", "soon")
+	rec := getFileWithHeader(handler, "a.txt", header)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+}
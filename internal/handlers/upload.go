@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// defaultUploadURLExpiry is how long presigned upload URLs stay valid
+	defaultUploadURLExpiry = 15 * time.Minute
+
+	// defaultUploadMaxSize is the largest upload a presigned URL is issued
+	// for, in bytes
+	defaultUploadMaxSize = 100 << 20
+
+	// maxKeyLength is the longest object key R2 accepts, in bytes
+	maxKeyLength = 1024
+)
+
+// WithUploadURLs allows presigned upload URLs for the given content types.
+// Entries are media types ("image/png") or type wildcards ("image/*"). With
+// no content types every upload-url request is rejected.
+func WithUploadURLs(contentTypes []string, expiry time.Duration) Option {
+	return func(h *FileHandler) {
+		h.uploadContentTypes = make([]string, 0, len(contentTypes))
+		for _, contentType := range contentTypes {
+			if contentType = strings.ToLower(strings.TrimSpace(contentType)); contentType != "" {
+				h.uploadContentTypes = append(h.uploadContentTypes, contentType)
+			}
+		}
+		if expiry > 0 {
+			h.uploadURLExpiry = expiry
+		}
+	}
+}
+
+// WithUploadMaxSize sets the largest upload, in bytes, that presigned
+// upload URLs are issued for. Values of 0 or less keep the default.
+func WithUploadMaxSize(n int64) Option {
+	return func(h *FileHandler) {
+		if n > 0 {
+			h.uploadMaxSize = n
+		}
+	}
+}
+
+// uploadURLRequest is the body of an upload-url request. Size is the exact
+// length of the upload, which the presigned URL is bound to.
+type uploadURLRequest struct {
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// UploadURL handles requests for a presigned URL to upload a file directly
+// to storage
+func (h *FileHandler) UploadURL(w http.ResponseWriter, r *http.Request) {
+	key, err := h.decodeKey(r, "name")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: keyErrorMessage(err),
+		})
+		return
+	}
+
+	if !validObjectKey(key) || !h.keyAllowed(key) {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: "invalid filename",
+		})
+		return
+	}
+
+	var req uploadURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	contentType, ok := h.allowedUploadType(req.ContentType)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: "content type not allowed",
+		})
+		return
+	}
+
+	if req.Size <= 0 || req.Size > h.uploadMaxSize {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: fmt.Sprintf("size must be between 1 and %d bytes", h.uploadMaxSize),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// Upload URLs only create objects. The presigned request also carries
+	// If-None-Match, so an object created after this check isn't replaced.
+	exists, err := h.storage.ObjectExists(ctx, key)
+	if err != nil {
+		slog.Error("Failed to check upload target", "filename", key, "error", err)
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Message: "Failed to create upload URL",
+		})
+		return
+	}
+	if exists {
+		writeJSON(w, http.StatusConflict, Response{
+			Success: false,
+			Message: "file already exists",
+		})
+		return
+	}
+
+	presigned, err := h.storage.PresignPutURL(ctx, key, h.uploadURLExpiry, contentType, req.Size)
+	if err != nil {
+		slog.Error("Failed to presign upload", "filename", key, "error", err)
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Message: "Failed to create upload URL",
+		})
+		return
+	}
+
+	headers := make(map[string]string, len(presigned.Header))
+	for name := range presigned.Header {
+		headers[name] = presigned.Header.Get(name)
+	}
+
+	h.markRecentWrite(ctx, key)
+
+	slog.Info("Issued upload URL", "filename", key, "content_type", contentType, "size", req.Size)
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]any{
+			"url":        presigned.URL,
+			"method":     presigned.Method,
+			"headers":    headers,
+			"expires_at": h.clock.Now().Add(h.uploadURLExpiry).UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// allowedUploadType normalizes contentType and reports whether it matches
+// the upload allowlist. Parameters such as charset are kept.
+func (h *FileHandler) allowedUploadType(contentType string) (string, bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+
+	for _, allowed := range h.uploadContentTypes {
+		prefix, isWildcard := strings.CutSuffix(allowed, "/*")
+		if mediaType == allowed || (isWildcard && strings.HasPrefix(mediaType, prefix+"/")) {
+			return mime.FormatMediaType(mediaType, params), true
+		}
+	}
+	return "", false
+}
+
+// validObjectKey reports whether key is safe to use as a path outside of
+// storage, e.g. for an upload URL or an archive entry: valid UTF-8 within
+// R2's length limit, without control characters, and without empty, "."
+// or ".." path segments
+func validObjectKey(key string) bool {
+	if key == "" || len(key) > maxKeyLength || !utf8.ValidString(key) {
+		return false
+	}
+	if strings.ContainsFunc(key, unicode.IsControl) {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,232 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+type uploadURLResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Data    struct {
+		URL       string            `json:"url"`
+		Method    string            `json:"method"`
+		Headers   map[string]string `json:"headers"`
+		ExpiresAt string            `json:"expires_at"`
+	} `json:"data"`
+}
+
+func requestUploadURL(handler *handlers.FileHandler, name, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/files/upload/upload-url", strings.NewReader(body))
+	req.SetPathValue("name", name)
+	rec := httptest.NewRecorder()
+	handler.UploadURL(rec, req)
+	return rec
+}
+
+func TestUploadURL_Success(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithUploadURLs([]string{"image/png"}, 10*time.Minute),
+	)
+
+	rec := requestUploadURL(handler, "avatar.png", `{"content_type":"image/png","size":3}`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var resp uploadURLResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.Data.Method != http.MethodPut {
+		t.Errorf("Expected method PUT, got '%s'", resp.Data.Method)
+	}
+	if resp.Data.URL == "" {
+		t.Error("Expected a URL")
+	}
+	if resp.Data.Headers["Content-Type"] != "image/png" {
+		t.Errorf("Expected Content-Type header 'image/png', got '%s'", resp.Data.Headers["Content-Type"])
+	}
+	if _, err := time.Parse(time.RFC3339, resp.Data.ExpiresAt); err != nil {
+		t.Errorf("Expected RFC 3339 expires_at, got '%s'", resp.Data.ExpiresAt)
+	}
+
+	if len(mockStorage.PresignCalls) != 1 {
+		t.Fatalf("Expected 1 presign call, got %d", len(mockStorage.PresignCalls))
+	}
+	call := mockStorage.PresignCalls[0]
+	if call.Key != "avatar.png" || call.Expiry != 10*time.Minute || call.ContentType != "image/png" || call.Size != 3 {
+		t.Errorf("Unexpected presign call: %+v", call)
+	}
+}
+
+func TestUploadURL_ContentTypeAllowlist(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, mocks.NewMockStorage(),
+		handlers.WithUploadURLs([]string{"image/*", "application/pdf"}, 0),
+	)
+
+	tests := []struct {
+		contentType string
+		wantStatus  int
+	}{
+		{"image/png", http.StatusOK},
+		{"IMAGE/JPEG", http.StatusOK},
+		{"application/pdf", http.StatusOK},
+		{"text/html", http.StatusBadRequest},
+		{"application/pdf-evil", http.StatusBadRequest},
+		{"imagefoo/png", http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		rec := requestUploadURL(handler, "file", `{"content_type":"`+tt.contentType+`","size":3}`)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%q: expected status %d, got %d", tt.contentType, tt.wantStatus, rec.Code)
+		}
+	}
+}
+
+func TestUploadURL_NoAllowlistRejects(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage)
+
+	rec := requestUploadURL(handler, "avatar.png", `{"content_type":"image/png","size":3}`)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if len(mockStorage.PresignCalls) != 0 {
+		t.Errorf("Expected no presign calls, got %d", len(mockStorage.PresignCalls))
+	}
+}
+
+func TestUploadURL_InvalidKey(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, mocks.NewMockStorage(),
+		handlers.WithUploadURLs([]string{"image/png"}, 0),
+	)
+
+	keys := []string{"", "../secret", "a//b", "a/./b", "dir/", "bad\nkey", strings.Repeat("a", 1025)}
+	for _, key := range keys {
+		rec := requestUploadURL(handler, key, `{"content_type":"image/png","size":3}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d, got %d", key, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+func TestUploadURL_InvalidBody(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, mocks.NewMockStorage(),
+		handlers.WithUploadURLs([]string{"image/png"}, 0),
+	)
+
+	rec := requestUploadURL(handler, "avatar.png", `not json`)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestUploadURL_Size(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithUploadURLs([]string{"image/png"}, 0),
+		handlers.WithUploadMaxSize(100),
+	)
+
+	tests := []struct {
+		body       string
+		wantStatus int
+	}{
+		{`{"content_type":"image/png","size":100}`, http.StatusOK},
+		{`{"content_type":"image/png","size":101}`, http.StatusBadRequest},
+		{`{"content_type":"image/png","size":0}`, http.StatusBadRequest},
+		{`{"content_type":"image/png","size":-1}`, http.StatusBadRequest},
+		{`{"content_type":"image/png"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		rec := requestUploadURL(handler, "avatar.png", tt.body)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.body, tt.wantStatus, rec.Code)
+		}
+	}
+
+	if len(mockStorage.PresignCalls) != 1 || mockStorage.PresignCalls[0].Size != 100 {
+		t.Errorf("Expected a single presign call for 100 bytes, got %+v", mockStorage.PresignCalls)
+	}
+}
+
+func TestUploadURL_ExistingKeyRefused(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("avatar.png", []byte("png"))
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithUploadURLs([]string{"image/png"}, 0),
+	)
+
+	rec := requestUploadURL(handler, "avatar.png", `{"content_type":"image/png","size":3}`)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, rec.Code)
+	}
+	if len(mockStorage.PresignCalls) != 0 {
+		t.Errorf("Expected no presign calls, got %d", len(mockStorage.PresignCalls))
+	}
+}
+
+func TestUploadURL_ExistsError(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.ExistsError = mocks.ErrStorageError
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithUploadURLs([]string{"image/png"}, 0),
+	)
+
+	rec := requestUploadURL(handler, "avatar.png", `{"content_type":"image/png","size":3}`)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+	if len(mockStorage.PresignCalls) != 0 {
+		t.Errorf("Expected no presign calls, got %d", len(mockStorage.PresignCalls))
+	}
+}
+
+func TestUploadURL_PresignError(t *testing.T) {
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.PresignError = mocks.ErrStorageError
+	handler := handlers.NewFileHandler(nil, mockStorage,
+		handlers.WithUploadURLs([]string{"image/png"}, 0),
+	)
+
+	rec := requestUploadURL(handler, "avatar.png", `{"content_type":"image/png","size":3}`)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+}
+
+func TestUploadURL_ExpiresAtUsesClock(t *testing.T) {
+	clk := mocks.NewMockClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	handler := handlers.NewFileHandler(nil, mocks.NewMockStorage(),
+		handlers.WithClock(clk),
+		handlers.WithUploadURLs([]string{"image/png"}, 10*time.Minute),
+	)
+
+	rec := requestUploadURL(handler, "avatar.png", `{"content_type":"image/png","size":3}`)
+
+	var resp uploadURLResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.Data.ExpiresAt != "2024-01-01T12:10:00Z" {
+		t.Errorf("Expected expires_at '2024-01-01T12:10:00Z', got '%s'", resp.Data.ExpiresAt)
+	}
+}
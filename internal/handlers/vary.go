@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// addVary adds fields to the response's Vary header, keeping it a single
+// comma-separated list without repeats so every negotiation feature can
+// add what it depends on independently
+func addVary(w http.ResponseWriter, fields ...string) {
+	var vary []string
+	for _, value := range w.Header().Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				vary = append(vary, field)
+			}
+		}
+	}
+	for _, field := range fields {
+		if !containsFold(vary, field) {
+			vary = append(vary, field)
+		}
+	}
+	w.Header().Set("Vary", strings.Join(vary, ", "))
+}
+
+// containsFold reports whether fields holds field, ignoring case as header
+// names do
+func containsFold(fields []string, field string) bool {
+	for _, f := range fields {
+		if strings.EqualFold(f, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// setEnvelopeVary marks responses that depend on whether Accept allowed a
+// base64 envelope. Only requests with ?encode=base64 can get one, and the
+// query is already part of a cache's key, so other responses don't vary.
+func (h *FileHandler) setEnvelopeVary(w http.ResponseWriter, r *http.Request) {
+	if h.base64MaxSize > 0 && r.URL.Query().Get("encode") == "base64" {
+		addVary(w, "Accept")
+	}
+}
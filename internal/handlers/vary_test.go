@@ -0,0 +1,79 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+func TestGetFile_Vary(t *testing.T) {
+	plain := []byte("<p>hello</p>")
+	compressed := gzipBytes(t, plain)
+	big := make([]byte, 100)
+
+	tests := []struct {
+		name     string
+		key      string
+		target   string
+		header   http.Header
+		opts     []handlers.Option
+		wantCode int
+		wantVary string
+	}{
+		{"plain object", "a.txt", "/files/a.txt", nil, nil, http.StatusOK, ""},
+		{"decompressed", "page.html", "/files/page.html", nil,
+			[]handlers.Option{handlers.WithGzipDecompression(true)}, http.StatusOK, "Accept-Encoding"},
+		{"stored gzip for gzip client", "page.html", "/files/page.html", http.Header{"Accept-Encoding": {"gzip"}},
+			[]handlers.Option{handlers.WithGzipDecompression(true)}, http.StatusOK, "Accept-Encoding"},
+		{"stored gzip without decompression", "page.html", "/files/page.html", nil, nil, http.StatusOK, ""},
+		{"not modified", "page.html", "/files/page.html",
+			http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {`"p1"`}},
+			[]handlers.Option{handlers.WithGzipDecompression(true)}, http.StatusNotModified, "Accept-Encoding"},
+		{"envelope", "a.txt", "/files/a.txt?encode=base64", http.Header{"Accept": {"application/json"}},
+			nil, http.StatusOK, "Accept"},
+		{"envelope not accepted", "a.txt", "/files/a.txt?encode=base64", http.Header{"Accept": {"text/html"}},
+			nil, http.StatusOK, "Accept"},
+		{"envelopes disabled", "a.txt", "/files/a.txt?encode=base64", http.Header{"Accept": {"application/json"}},
+			[]handlers.Option{handlers.WithBase64MaxSize(0)}, http.StatusOK, ""},
+		{"envelope not accepted, decompressed", "page.html", "/files/page.html?encode=base64", nil,
+			[]handlers.Option{handlers.WithGzipDecompression(true)}, http.StatusOK, "Accept, Accept-Encoding"},
+		{"envelope too large", "big.bin", "/files/big.bin?encode=base64", http.Header{"Accept": {"application/json"}},
+			[]handlers.Option{handlers.WithBase64MaxSize(10)}, http.StatusNotAcceptable, "Accept"},
+		{"redirect", "big.bin", "/files/big.bin?encode=base64", nil,
+			[]handlers.Option{handlers.WithStorageRedirect(50, time.Minute)}, http.StatusFound, "Accept"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockStorage()
+			mockStorage.SetObject("a.txt", []byte("hello"))
+			mockStorage.SetObject("page.html", compressed)
+			mockStorage.SetObjectInfo("page.html", storage.ObjectInfo{ContentEncoding: "gzip", ETag: `"p1"`})
+			mockStorage.SetObject("big.bin", big)
+			handler := handlers.NewFileHandler(nil, mockStorage, tt.opts...)
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.SetPathValue("name", tt.key)
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			rec := httptest.NewRecorder()
+			handler.GetFile(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if got := rec.Header().Values("Vary"); len(got) > 1 {
+				t.Errorf("Expected a single Vary header, got %q", got)
+			}
+			if got := rec.Header().Get("Vary"); got != tt.wantVary {
+				t.Errorf("Expected Vary %q, got %q", tt.wantVary, got)
+			}
+		})
+	}
+}
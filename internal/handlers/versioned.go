@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/cache"
+)
+
+// WithVersionedCacheWrites makes cache misses store objects together with
+// their ETag and modification time, replacing a cached copy only with a
+// different, not older revision. Without it, two fetches racing an upload
+// can leave whichever body was written last cached, even the old one.
+func WithVersionedCacheWrites(enabled bool) Option {
+	return func(h *FileHandler) {
+		h.versionedWrites = enabled
+	}
+}
+
+// setCache stores value under key with ttl, or the cache's default TTL
+// when ttl is 0. With versioned writes and a known revision v, it reports
+// whether the value was stored or a same or newer revision was kept.
+func (h *FileHandler) setCache(ctx context.Context, key string, value []byte, ttl time.Duration, v cache.Version) (bool, error) {
+	if h.versionedWrites && v.ETag != "" {
+		return h.cache.SetIfNewer(ctx, key, value, ttl, v)
+	}
+	if ttl > 0 {
+		return true, h.cache.SetWithTTL(ctx, key, value, ttl)
+	}
+	return true, h.cache.Set(ctx, key, value)
+}
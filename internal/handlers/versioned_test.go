@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+	"github.com/ch374n/file-downloader/internal/storage"
+)
+
+// racingStorage holds its first fetch until release is closed, returning
+// the object as it was when the fetch started
+type racingStorage struct {
+	*mocks.MockStorage
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (s *racingStorage) GetObjectWithInfo(ctx context.Context, key string) ([]byte, storage.ObjectInfo, error) {
+	data, info, err := s.MockStorage.GetObjectWithInfo(ctx, key)
+	if s.calls.Add(1) == 1 {
+		<-s.release
+	}
+	return data, info, err
+}
+
+// raceUpload fetches key while it is replaced in storage, letting the fetch
+// of the old revision finish after the new one was cached, and returns the
+// body served afterwards
+func raceUpload(t *testing.T, opts ...handlers.Option) string {
+	t.Helper()
+	s := &racingStorage{MockStorage: mocks.NewMockStorage(), release: make(chan struct{})}
+	s.SetObject("a.txt", []byte("old"))
+	s.SetObjectInfo("a.txt", storage.ObjectInfo{ETag: `"1"`, LastModified: time.Unix(1000, 0)})
+	mockCache := mocks.NewMockCache()
+	handler := handlers.NewFileHandler(mockCache, s, opts...)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		getFile(handler, "a.txt")
+	}()
+	waitFor(t, func() bool { return s.calls.Load() == 1 })
+
+	s.SetObject("a.txt", []byte("new"))
+	s.SetObjectInfo("a.txt", storage.ObjectInfo{ETag: `"2"`, LastModified: time.Unix(2000, 0)})
+	if rec := getFile(handler, "a.txt"); rec.Body.String() != "new" {
+		t.Fatalf("Expected the new body from storage, got '%s'", rec.Body.String())
+	}
+	waitFor(t, func() bool { return mockCache.SetCallCount() == 1 })
+
+	close(s.release)
+	<-done
+	waitFor(t, func() bool { return mockCache.SetCallCount() == 2 })
+
+	rec := getFile(handler, "a.txt")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestGetFile_VersionedCacheWrites_ConcurrentSetKeepsNewer(t *testing.T) {
+	if body := raceUpload(t, handlers.WithVersionedCacheWrites(true)); body != "new" {
+		t.Errorf("Expected the newer revision to stay cached, got '%s'", body)
+	}
+}
+
+func TestGetFile_UnversionedCacheWrites_LastWriteWins(t *testing.T) {
+	if body := raceUpload(t); body != "old" {
+		t.Errorf("Expected the late write of the old revision to win, got '%s'", body)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ch374n/file-downloader/internal/cache"
+	"github.com/ch374n/file-downloader/internal/metrics"
+)
+
+const (
+	// defaultWarmConcurrency is how many keys are warmed at once
+	defaultWarmConcurrency = 4
+
+	// maxWarmKeys caps the keys accepted in one warm request
+	maxWarmKeys = 1000
+)
+
+// WithWarmConcurrency sets how many keys a cache warm request fetches in
+// parallel. Values below 1 keep the default.
+func WithWarmConcurrency(n int) Option {
+	return func(h *FileHandler) {
+		if n > 0 {
+			h.warmConcurrency = n
+		}
+	}
+}
+
+// warmRequest is the body of a cache warm request
+type warmRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// warmResult reports the outcome of warming one key
+type warmResult struct {
+	Key     string `json:"key"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// WarmCache handles requests to fetch a list of keys from storage into the
+// cache ahead of expected traffic. Keys are fetched with bounded
+// concurrency and the outcome is reported per key.
+func (h *FileHandler) WarmCache(w http.ResponseWriter, r *http.Request) {
+	if h.cache == nil {
+		writeJSON(w, http.StatusServiceUnavailable, Response{
+			Success: false,
+			Message: "cache is disabled",
+		})
+		return
+	}
+
+	var req warmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	if len(req.Keys) == 0 || len(req.Keys) > maxWarmKeys {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Message: fmt.Sprintf("between 1 and %d keys are required", maxWarmKeys),
+		})
+		return
+	}
+
+	results := make([]warmResult, len(req.Keys))
+	sem := make(chan struct{}, h.warmConcurrency)
+	var wg sync.WaitGroup
+
+	for i, key := range req.Keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i] = warmResult{Key: key, Success: true}
+			if err := h.warmKey(r.Context(), key); err != nil {
+				results[i] = warmResult{Key: key, Error: err.Error()}
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+	slog.Info("Cache warm finished", "keys", len(results), "failed", failed)
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: failed == 0,
+		Message: fmt.Sprintf("warmed %d of %d keys", len(results)-failed, len(results)),
+		Data:    map[string]any{"results": results},
+	})
+}
+
+// warmKey fetches key from storage and stores it in the cache, waiting for
+// the cache write so failures can be reported. Like a cache miss, it
+// doesn't read storage during maintenance or shed large objects under
+// memory pressure.
+func (h *FileHandler) warmKey(ctx context.Context, key string) error {
+	key = h.normalizeKey(key)
+	if key == "" || strings.ContainsFunc(key, unicode.IsControl) || !h.keyAllowed(key) {
+		return errors.New("invalid key")
+	}
+	if err := h.checkMaintenance(key); err != nil {
+		return errors.New("unavailable during maintenance")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	obj, err := h.getCheckedObject(ctx, key)
+	h.metrics.ObserveHistogram(metrics.R2RequestDuration, time.Since(start).Seconds(), metrics.Labels{"operation": "get"})
+	if err != nil {
+		h.metrics.IncCounter(metrics.R2RequestsTotal, metrics.Labels{"operation": "get", "status": "error"})
+		if isNotFoundError(err) {
+			return errors.New("not found")
+		}
+		if errors.Is(err, errLoadShed) {
+			return errors.New("shed under memory pressure")
+		}
+		return errors.New("storage error")
+	}
+	h.metrics.IncCounter(metrics.R2RequestsTotal, metrics.Labels{"operation": "get", "status": "success"})
+
+	if obj.body != nil {
+		obj.body.Close()
+		return errors.New("too large to cache")
+	}
+
+	obj.CachedAt = h.clock.Now().Unix()
+	value, err := encodeEntry(obj)
+	if err != nil {
+		return err
+	}
+
+	if h.cacheOOM.readOnly(h.clock.Now()) {
+		return errors.New("cache is read-only")
+	}
+	_, err = h.setCache(ctx, key, value, h.cacheTTLFor(key), obj.version())
+	if cache.IsOutOfMemory(err) {
+		h.cacheOOM.record(ctx, h.clock.Now(), key, err)
+		return errors.New("cache is out of memory")
+	}
+	if err != nil {
+		slog.Error("Failed to warm cache", "filename", key, "error", err)
+		return errors.New("cache error")
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ch374n/file-downloader/internal/handlers"
+	"github.com/ch374n/file-downloader/internal/mocks"
+)
+
+type warmResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Data    struct {
+		Results []struct {
+			Key     string `json:"key"`
+			Success bool   `json:"success"`
+			Error   string `json:"error"`
+		} `json:"results"`
+	} `json:"data"`
+}
+
+func warmCache(handler *handlers.FileHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/cache/warm", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.WarmCache(rec, req)
+	return rec
+}
+
+func TestWarmCache_PerKeyResults(t *testing.T) {
+	mockCache := mocks.NewMockCache()
+	mockStorage := mocks.NewMockStorage()
+	mockStorage.SetObject("a.txt", []byte("a"))
+	mockStorage.SetObject("b.txt", []byte("b"))
+	handler := handlers.NewFileHandler(mockCache, mockStorage, handlers.WithWarmConcurrency(2))
+
+	rec := warmCache(handler, `{"keys":["a.txt","missing.txt","b.txt"]}`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var resp warmResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.Success {
+		t.Error("Expected success false when a key fails")
+	}
+	if len(resp.Data.Results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(resp.Data.Results))
+	}
+
+	wantSuccess := map[string]bool{"a.txt": true, "missing.txt": false, "b.txt": true}
+	for i, result := range resp.Data.Results {
+		if result.Key != []string{"a.txt", "missing.txt", "b.txt"}[i] {
+			t.Errorf("Result %d: expected results in request order, got '%s'", i, result.Key)
+		}
+		if result.Success != wantSuccess[result.Key] {
+			t.Errorf("%s: expected success %v, got %v (%s)", result.Key, wantSuccess[result.Key], result.Success, result.Error)
+		}
+	}
+
+	// Warmed keys are served from the cache
+	getFile(handler, "a.txt")
+	if len(mockStorage.GetCalls) != 3 {
+		t.Errorf("Expected 3 storage get calls, got %d", len(mockStorage.GetCalls))
+	}
+}
+
+func TestWarmCache_CacheDisabled(t *testing.T) {
+	handler := handlers.NewFileHandler(nil, mocks.NewMockStorage())
+
+	rec := warmCache(handler, `{"keys":["a.txt"]}`)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
+
+func TestWarmCache_InvalidBody(t *testing.T) {
+	handler := handlers.NewFileHandler(mocks.NewMockCache(), mocks.NewMockStorage())
+
+	for _, body := range []string{`not json`, `{"keys":[]}`} {
+		rec := warmCache(handler, body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d, got %d", body, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+func TestRequireBearerToken(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	protected := handlers.RequireBearerToken("s3cret", ok)
+
+	tests := []struct {
+		authorization string
+		wantStatus    int
+	}{
+		{"Bearer s3cret", http.StatusNoContent},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"s3cret", http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/admin/cache/warm", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		rec := httptest.NewRecorder()
+		protected(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%q: expected status %d, got %d", tt.authorization, tt.wantStatus, rec.Code)
+		}
+	}
+}
+
+func TestRequireOriginPullSecret(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	tests := []struct {
+		name       string
+		secret     string
+		header     string
+		wantStatus int
+	}{
+		{"matching secret", "cdn-secret", "cdn-secret", http.StatusNoContent},
+		{"wrong secret", "cdn-secret", "cdn-secre", http.StatusForbidden},
+		{"missing secret", "cdn-secret", "", http.StatusForbidden},
+		{"no secret configured", "", "", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			protected := handlers.RequireOriginPullSecret(tt.secret, ok)
+
+			req := httptest.NewRequest(http.MethodGet, "/files/a.txt", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Origin-Pull-Secret", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			protected(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
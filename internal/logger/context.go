@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ch374n/file-downloader/internal/contextkeys"
+)
+
+// contextHandler adds the request values stored on a record's context,
+// like the request ID, to every record logged with a *Context method
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		r.Add(contextkeys.LogAttrs(ctx)...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
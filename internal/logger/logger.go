@@ -27,7 +27,7 @@ func Init(level string) {
 		Level: logLevel,
 	}
 
-	handler := slog.NewJSONHandler(os.Stdout, opts)
+	handler := contextHandler{slog.NewJSONHandler(os.Stdout, opts)}
 	Log = slog.New(handler)
 	slog.SetDefault(Log)
 }
@@ -1,68 +1,45 @@
 package metrics
 
-import (
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
-)
-
-var (
-	// HTTP metrics
-	HTTPRequestsTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"method", "path", "status"},
-	)
-
-	HTTPRequestDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "HTTP request duration in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"method", "path"},
-	)
+// Metrics records the service's metrics to a monitoring backend. Names are
+// the constants below; labels must match the ones listed for each name.
+type Metrics interface {
+	IncCounter(name string, labels Labels)
+	ObserveHistogram(name string, value float64, labels Labels)
+	SetGauge(name string, value float64, labels Labels)
+}
+
+// Labels are the label values of a single observation, keyed by label name
+type Labels map[string]string
+
+// Metric names. Histograms observe durations in seconds.
+const (
+	// HTTP metrics, labelled method, path, status (requests only) and cache
+	HTTPRequestsTotal   = "http_requests_total"
+	HTTPRequestDuration = "http_request_duration_seconds"
 
 	// Cache metrics
-	CacheHitsTotal = promauto.NewCounter(
-		prometheus.CounterOpts{
-			Name: "cache_hits_total",
-			Help: "Total number of cache hits",
-		},
-	)
-
-	CacheMissesTotal = promauto.NewCounter(
-		prometheus.CounterOpts{
-			Name: "cache_misses_total",
-			Help: "Total number of cache misses",
-		},
-	)
-
-	CacheOperationDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "cache_operation_duration_seconds",
-			Help:    "Cache operation duration in seconds",
-			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1},
-		},
-		[]string{"operation"},
-	)
+	CacheHitsTotal         = "cache_hits_total"
+	CacheMissesTotal       = "cache_misses_total"
+	CacheOperationDuration = "cache_operation_duration_seconds" // operation
+	StaleServedTotal       = "cache_stale_served_total"
+	MissStormShedTotal     = "cache_miss_storm_shed_total"
+	MissStormActive        = "cache_miss_storm_active"
+	MemoryShedTotal        = "memory_pressure_shed_total"
+	MemoryPressure         = "memory_pressure_active"
+	ConcurrencyShedTotal   = "adaptive_concurrency_shed_total"
+	ConcurrencyLimit       = "adaptive_concurrency_limit"
+	PrefetchTotal          = "cache_prefetch_total" // status
+	CorruptionRepairsTotal = "cache_corruption_repaired_total"
+
+	// R2 metrics, labelled operation and status (requests only)
+	R2RequestsTotal   = "r2_requests_total"
+	R2RequestDuration = "r2_request_duration_seconds"
+	R2ReadFallbacks   = "r2_read_fallbacks_total" // operation
+)
 
-	// R2 metrics
-	R2RequestsTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "r2_requests_total",
-			Help: "Total number of R2 requests",
-		},
-		[]string{"operation", "status"},
-	)
+// Nop discards all metrics
+type Nop struct{}
 
-	R2RequestDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "r2_request_duration_seconds",
-			Help:    "R2 request duration in seconds",
-			Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10},
-		},
-		[]string{"operation"},
-	)
-)
+func (Nop) IncCounter(string, Labels)                {}
+func (Nop) ObserveHistogram(string, float64, Labels) {}
+func (Nop) SetGauge(string, float64, Labels)         {}
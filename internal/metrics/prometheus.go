@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus records metrics as Prometheus collectors
+type Prometheus struct {
+	counters   map[string]*prometheus.CounterVec
+	histograms map[string]*prometheus.HistogramVec
+	gauges     map[string]*prometheus.GaugeVec
+}
+
+// NewPrometheus creates the service's collectors and registers them with
+// reg. Collectors already registered there, e.g. by an earlier call with
+// the same registry, are reused.
+func NewPrometheus(reg prometheus.Registerer) *Prometheus {
+	p := &Prometheus{
+		counters:   make(map[string]*prometheus.CounterVec),
+		histograms: make(map[string]*prometheus.HistogramVec),
+		gauges:     make(map[string]*prometheus.GaugeVec),
+	}
+
+	counter := func(name, help string, labels ...string) {
+		p.counters[name] = register(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: name, Help: help}, labels))
+	}
+	histogram := func(name, help string, buckets []float64, labels ...string) {
+		p.histograms[name] = register(reg, prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels))
+	}
+	gauge := func(name, help string) {
+		p.gauges[name] = register(reg, prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{Name: name, Help: help}, nil))
+	}
+
+	// HTTP metrics
+	counter(HTTPRequestsTotal, "Total number of HTTP requests", "method", "path", "status", "cache")
+	histogram(HTTPRequestDuration, "HTTP request duration in seconds", prometheus.DefBuckets, "method", "path", "cache")
+
+	// Cache metrics
+	counter(CacheHitsTotal, "Total number of cache hits")
+	counter(CacheMissesTotal, "Total number of cache misses")
+	histogram(CacheOperationDuration, "Cache operation duration in seconds",
+		[]float64{.001, .005, .01, .025, .05, .1, .25, .5, 1}, "operation")
+	counter(StaleServedTotal, "Total number of stale copies served after storage auth errors")
+	counter(MissStormShedTotal, "Total number of cache misses rejected during a miss storm")
+	gauge(MissStormActive, "Whether a cache miss storm is currently detected (1) or not (0)")
+	counter(MemoryShedTotal, "Total number of large-object fetches rejected under memory pressure")
+	gauge(MemoryPressure, "Whether memory usage is above the shedding threshold (1) or not (0)")
+	counter(ConcurrencyShedTotal, "Total number of requests rejected over the adaptive concurrency limit")
+	gauge(ConcurrencyLimit, "Current adaptive limit on concurrent file fetches")
+	counter(PrefetchTotal, "Total number of related keys prefetched, by outcome", "status")
+	counter(CorruptionRepairsTotal, "Total number of corrupt cache entries replaced from storage")
+
+	// R2 metrics
+	counter(R2RequestsTotal, "Total number of R2 requests", "operation", "status")
+	histogram(R2RequestDuration, "R2 request duration in seconds",
+		[]float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10}, "operation")
+	counter(R2ReadFallbacks, "Total number of R2 reads retried on the primary endpoint after the read endpoint failed", "operation")
+
+	return p
+}
+
+// register registers c with reg, returning the collector already registered
+// under the same description if there is one
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	if err := reg.Register(c); err != nil {
+		var exists prometheus.AlreadyRegisteredError
+		if errors.As(err, &exists) {
+			return exists.ExistingCollector.(T)
+		}
+		panic(err)
+	}
+	return c
+}
+
+// Unknown names and mismatched labels are dropped rather than panicking,
+// since a metric should never take a request down
+
+func (p *Prometheus) IncCounter(name string, labels Labels) {
+	if vec, ok := p.counters[name]; ok {
+		if c, err := vec.GetMetricWith(prometheus.Labels(labels)); err == nil {
+			c.Inc()
+		}
+	}
+}
+
+func (p *Prometheus) ObserveHistogram(name string, value float64, labels Labels) {
+	if vec, ok := p.histograms[name]; ok {
+		if h, err := vec.GetMetricWith(prometheus.Labels(labels)); err == nil {
+			h.Observe(value)
+		}
+	}
+}
+
+func (p *Prometheus) SetGauge(name string, value float64, labels Labels) {
+	if vec, ok := p.gauges[name]; ok {
+		if g, err := vec.GetMetricWith(prometheus.Labels(labels)); err == nil {
+			g.Set(value)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPrometheus_RecordsAndReusesCollectors(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	p := NewPrometheus(reg)
+
+	// A second backend on the same registry shares its collectors
+	NewPrometheus(reg).IncCounter(CacheHitsTotal, nil)
+	p.IncCounter(CacheHitsTotal, nil)
+	p.IncCounter(R2RequestsTotal, Labels{"operation": "get", "status": "success"})
+
+	// Unknown names and labels are dropped
+	p.IncCounter("unknown_total", nil)
+	p.IncCounter(R2RequestsTotal, Labels{"bogus": "x"})
+
+	if got := testutil.ToFloat64(p.counters[CacheHitsTotal]); got != 2 {
+		t.Errorf("Expected 2 cache hits, got %v", got)
+	}
+	if got := testutil.ToFloat64(p.counters[R2RequestsTotal].WithLabelValues("get", "success")); got != 1 {
+		t.Errorf("Expected 1 R2 request, got %v", got)
+	}
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StatsD sends metrics over UDP in the StatsD line format. Labels are sent
+// as DogStatsD tags, which Datadog, Telegraf and statsd_exporter accept.
+// Histograms are sent as timers in milliseconds. Sends never block on the
+// collector and failures are dropped.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+}
+
+// NewStatsD returns a client sending to the collector at addr, prefixing
+// every metric name with prefix (e.g. "downloader.")
+func NewStatsD(addr, prefix string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to statsd at %s: %w", addr, err)
+	}
+	return &StatsD{conn: conn, prefix: prefix}, nil
+}
+
+func (s *StatsD) IncCounter(name string, labels Labels) {
+	s.send(name, "1", "c", labels)
+}
+
+func (s *StatsD) ObserveHistogram(name string, value float64, labels Labels) {
+	s.send(strings.TrimSuffix(name, "_seconds"), strconv.FormatFloat(value*1000, 'f', -1, 64), "ms", labels)
+}
+
+func (s *StatsD) SetGauge(name string, value float64, labels Labels) {
+	s.send(name, strconv.FormatFloat(value, 'f', -1, 64), "g", labels)
+}
+
+// Close closes the UDP socket
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsD) send(name, value, kind string, labels Labels) {
+	var b strings.Builder
+	b.WriteString(s.prefix)
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(kind)
+
+	// Empty values are left out rather than sent as empty tags
+	if len(labels) > 0 {
+		keys := make([]string, 0, len(labels))
+		for k, v := range labels {
+			if v != "" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for i, k := range keys {
+			if i == 0 {
+				b.WriteString("|#")
+			} else {
+				b.WriteByte(',')
+			}
+			b.WriteString(k)
+			b.WriteByte(':')
+			b.WriteString(labels[k])
+		}
+	}
+
+	s.conn.Write([]byte(b.String()))
+}
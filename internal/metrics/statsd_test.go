@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsD_LineFormat(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	s, err := NewStatsD(conn.LocalAddr().String(), "app.")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer s.Close()
+
+	s.IncCounter(HTTPRequestsTotal, Labels{"status": "200", "method": "GET", "cache": ""})
+	s.ObserveHistogram(R2RequestDuration, 0.25, Labels{"operation": "get"})
+	s.SetGauge(MissStormActive, 1, nil)
+
+	want := []string{
+		"app.http_requests_total:1|c|#method:GET,status:200",
+		"app.r2_request_duration:250|ms|#operation:get",
+		"app.cache_miss_storm_active:1|g",
+	}
+	buf := make([]byte, 512)
+	for _, w := range want {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Failed to read packet: %v", err)
+		}
+		if got := string(buf[:n]); got != w {
+			t.Errorf("Expected '%s', got '%s'", w, got)
+		}
+	}
+}
@@ -3,13 +3,20 @@ package mocks
 import (
 	"context"
 	"errors"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/cache"
 )
 
 // MockCache is a mock implementation of cache.Cache for testing
 type MockCache struct {
-	mu   sync.RWMutex
-	data map[string][]byte
+	mu       sync.RWMutex
+	data     map[string][]byte
+	versions map[string]cache.Version
+	accesses map[string]time.Time
 
 	// Control behavior
 	GetError   error
@@ -18,21 +25,36 @@ type MockCache struct {
 	CloseError error
 
 	// Track calls
-	GetCalls   []string
-	SetCalls   []SetCall
-	PingCalls  int
-	CloseCalls int
+	GetCalls    []string
+	SetCalls    []SetCall
+	ExpireCalls []ExpireCall
+	DeleteCalls []string
+	PingCalls   int
+	CloseCalls  int
 }
 
 type SetCall struct {
 	Key  string
 	Data []byte
+
+	// TTL is the TTL passed to SetWithTTL; 0 for Set
+	TTL time.Duration
+
+	// Version is the revision passed to SetIfNewer
+	Version cache.Version
+}
+
+type ExpireCall struct {
+	Key string
+	TTL time.Duration
 }
 
 // NewMockCache creates a new mock cache
 func NewMockCache() *MockCache {
 	return &MockCache{
 		data:     make(map[string][]byte),
+		versions: make(map[string]cache.Version),
+		accesses: make(map[string]time.Time),
 		GetCalls: make([]string, 0),
 		SetCalls: make([]SetCall, 0),
 	}
@@ -55,19 +77,111 @@ func (m *MockCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
 
 // Set stores data in mock cache
 func (m *MockCache) Set(ctx context.Context, key string, data []byte) error {
+	return m.SetWithTTL(ctx, key, data, 0)
+}
+
+// SetWithTTL stores data in mock cache, recording the requested TTL
+func (m *MockCache) SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.SetCalls = append(m.SetCalls, SetCall{Key: key, Data: data})
+	m.SetCalls = append(m.SetCalls, SetCall{Key: key, Data: data, TTL: ttl})
 
 	if m.SetError != nil {
 		return m.SetError
 	}
 
 	m.data[key] = data
+	delete(m.versions, key)
 	return nil
 }
 
+// SetIfNewer stores data unless the key holds the same or a newer revision,
+// following the same rules as the Redis cache
+func (m *MockCache) SetIfNewer(ctx context.Context, key string, data []byte, ttl time.Duration, v cache.Version) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.SetCalls = append(m.SetCalls, SetCall{Key: key, Data: data, TTL: ttl, Version: v})
+
+	if m.SetError != nil {
+		return false, m.SetError
+	}
+
+	if stored, ok := m.versions[key]; ok && !v.Replaces(stored) {
+		return false, nil
+	}
+	m.data[key] = data
+	m.versions[key] = v
+	return true, nil
+}
+
+// Expire records the requested TTL of an existing key
+func (m *MockCache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ExpireCalls = append(m.ExpireCalls, ExpireCall{Key: key, TTL: ttl})
+	return m.SetError
+}
+
+// Delete removes a key from the mock cache
+func (m *MockCache) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeleteCalls = append(m.DeleteCalls, key)
+	if m.SetError != nil {
+		return m.SetError
+	}
+	delete(m.data, key)
+	delete(m.versions, key)
+	return nil
+}
+
+// RecordAccess stores last-access times, keeping the later of two times
+func (m *MockCache) RecordAccess(ctx context.Context, accesses map[string]time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SetError != nil {
+		return m.SetError
+	}
+	for key, at := range accesses {
+		if at.After(m.accesses[key]) {
+			m.accesses[key] = at
+		}
+	}
+	return nil
+}
+
+// LastAccesses returns up to limit recorded keys starting with prefix,
+// least recently accessed first
+func (m *MockCache) LastAccesses(ctx context.Context, prefix string, limit int) ([]cache.Access, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.GetError != nil {
+		return nil, m.GetError
+	}
+	var accesses []cache.Access
+	for key, at := range m.accesses {
+		if strings.HasPrefix(key, prefix) {
+			accesses = append(accesses, cache.Access{Key: key, At: at})
+		}
+	}
+	sort.Slice(accesses, func(i, j int) bool {
+		if !accesses[i].At.Equal(accesses[j].At) {
+			return accesses[i].At.Before(accesses[j].At)
+		}
+		return accesses[i].Key < accesses[j].Key
+	})
+	if len(accesses) > limit {
+		accesses = accesses[:limit]
+	}
+	return accesses, nil
+}
+
 // Ping checks mock cache health
 func (m *MockCache) Ping(ctx context.Context) error {
 	m.mu.Lock()
@@ -86,11 +200,28 @@ func (m *MockCache) Close() error {
 	return m.CloseError
 }
 
+// SetCallCount returns the number of Set calls made so far. Unlike reading
+// SetCalls directly, it is safe to use while background sets are running.
+func (m *MockCache) SetCallCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.SetCalls)
+}
+
+// Expirations returns the Expire calls made so far. It is safe to use
+// while background calls are running.
+func (m *MockCache) Expirations() []ExpireCall {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]ExpireCall(nil), m.ExpireCalls...)
+}
+
 // SetData pre-populates cache data for testing
 func (m *MockCache) SetData(key string, data []byte) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.data[key] = data
+	delete(m.versions, key)
 }
 
 // ClearData clears all cached data
@@ -98,6 +229,7 @@ func (m *MockCache) ClearData() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.data = make(map[string][]byte)
+	m.versions = make(map[string]cache.Version)
 }
 
 // Reset resets all mock state
@@ -106,8 +238,12 @@ func (m *MockCache) Reset() {
 	defer m.mu.Unlock()
 
 	m.data = make(map[string][]byte)
+	m.versions = make(map[string]cache.Version)
+	m.accesses = make(map[string]time.Time)
 	m.GetCalls = make([]string, 0)
 	m.SetCalls = make([]SetCall, 0)
+	m.ExpireCalls = nil
+	m.DeleteCalls = nil
 	m.PingCalls = 0
 	m.CloseCalls = 0
 	m.GetError = nil
@@ -0,0 +1,77 @@
+package mocks
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ch374n/file-downloader/internal/metrics"
+)
+
+// MockMetrics is a mock implementation of metrics.Metrics that keeps the
+// latest value of every series in memory
+type MockMetrics struct {
+	mu           sync.Mutex
+	counters     map[string]float64
+	gauges       map[string]float64
+	observations map[string][]float64
+}
+
+// NewMockMetrics creates a new mock metrics backend
+func NewMockMetrics() *MockMetrics {
+	return &MockMetrics{
+		counters:     make(map[string]float64),
+		gauges:       make(map[string]float64),
+		observations: make(map[string][]float64),
+	}
+}
+
+func (m *MockMetrics) IncCounter(name string, labels metrics.Labels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[seriesKey(name, labels)]++
+}
+
+func (m *MockMetrics) ObserveHistogram(name string, value float64, labels metrics.Labels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := seriesKey(name, labels)
+	m.observations[key] = append(m.observations[key], value)
+}
+
+func (m *MockMetrics) SetGauge(name string, value float64, labels metrics.Labels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[seriesKey(name, labels)] = value
+}
+
+// Counter returns the value of a counter series
+func (m *MockMetrics) Counter(name string, labels metrics.Labels) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[seriesKey(name, labels)]
+}
+
+// Gauge returns the value of a gauge series
+func (m *MockMetrics) Gauge(name string, labels metrics.Labels) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gauges[seriesKey(name, labels)]
+}
+
+// Observations returns the values observed by a histogram series
+func (m *MockMetrics) Observations(name string, labels metrics.Labels) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]float64(nil), m.observations[seriesKey(name, labels)]...)
+}
+
+// seriesKey identifies a series by its name and sorted labels
+func seriesKey(name string, labels metrics.Labels) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"testing"
+	"time"
 
 	"github.com/ch374n/file-downloader/internal/mocks"
 )
@@ -328,3 +329,64 @@ func TestMockStorage_ClearObjects(t *testing.T) {
 		t.Error("key2 should be cleared")
 	}
 }
+
+func TestMockStorage_GetObjectTagging(t *testing.T) {
+	storage := mocks.NewMockStorage()
+	ctx := context.Background()
+
+	_, err := storage.GetObjectTagging(ctx, "missing")
+	if err != mocks.ErrObjectNotFound {
+		t.Fatalf("Expected ErrObjectNotFound, got %v", err)
+	}
+
+	storage.SetObject("key1", []byte("content"))
+	storage.SetTags("key1", map[string]string{"visibility": "public"})
+
+	tags, err := storage.GetObjectTagging(ctx, "key1")
+	if err != nil {
+		t.Fatalf("GetObjectTagging failed: %v", err)
+	}
+	if tags["visibility"] != "public" {
+		t.Errorf("Expected visibility 'public', got '%s'", tags["visibility"])
+	}
+	if len(storage.TaggingCalls) != 2 {
+		t.Errorf("Expected 2 TaggingCalls, got %d", len(storage.TaggingCalls))
+	}
+
+	storage.TaggingError = mocks.ErrStorageError
+	_, err = storage.GetObjectTagging(ctx, "key1")
+	if err != mocks.ErrStorageError {
+		t.Errorf("Expected ErrStorageError, got %v", err)
+	}
+}
+
+func TestMockClock_AdvanceFiresAfter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := mocks.NewMockClock(start)
+
+	ch := clk.After(time.Minute)
+
+	clk.Advance(30 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("After fired before its deadline")
+	default:
+	}
+
+	clk.Advance(30 * time.Second)
+	select {
+	case got := <-ch:
+		if !got.Equal(start.Add(time.Minute)) {
+			t.Errorf("Expected %v, got %v", start.Add(time.Minute), got)
+		}
+	default:
+		t.Fatal("After didn't fire at its deadline")
+	}
+
+	if !clk.Now().Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected Now %v, got %v", start.Add(time.Minute), clk.Now())
+	}
+	if clk.WaiterCount() != 0 {
+		t.Errorf("Expected no pending waiters, got %d", clk.WaiterCount())
+	}
+}
@@ -1,22 +1,36 @@
 package mocks
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/ch374n/file-downloader/internal/storage"
 )
 
 // MockStorage is a mock implementation of storage.Storage for testing
 type MockStorage struct {
 	mu      sync.RWMutex
 	objects map[string][]byte
+	infos   map[string]storage.ObjectInfo
+	tags    map[string]map[string]string
 
 	// Control behavior
 	GetError         error
 	PutError         error
 	DeleteError      error
 	ExistsError      error
+	StatError        error
+	TaggingError     error
+	PresignError     error
+	ListError        error
 	HealthCheckError error
 
 	// Track calls
@@ -24,6 +38,12 @@ type MockStorage struct {
 	PutCalls         []PutCall
 	DeleteCalls      []string
 	ExistsCalls      []string
+	StatCalls        []string
+	RangeCalls       []RangeCall
+	TaggingCalls     []string
+	PresignCalls     []PresignCall
+	PresignGetCalls  []PresignCall
+	ListCalls        []string
 	HealthCheckCalls int
 }
 
@@ -33,14 +53,33 @@ type PutCall struct {
 	Data        []byte
 }
 
+type RangeCall struct {
+	Key    string
+	Start  int64
+	Length int64
+}
+
+type PresignCall struct {
+	Key         string
+	Expiry      time.Duration
+	ContentType string
+	Size        int64
+}
+
 // NewMockStorage creates a new mock storage
 func NewMockStorage() *MockStorage {
 	return &MockStorage{
-		objects:     make(map[string][]byte),
-		GetCalls:    make([]string, 0),
-		PutCalls:    make([]PutCall, 0),
-		DeleteCalls: make([]string, 0),
-		ExistsCalls: make([]string, 0),
+		objects:         make(map[string][]byte),
+		infos:           make(map[string]storage.ObjectInfo),
+		tags:            make(map[string]map[string]string),
+		GetCalls:        make([]string, 0),
+		PutCalls:        make([]PutCall, 0),
+		DeleteCalls:     make([]string, 0),
+		ExistsCalls:     make([]string, 0),
+		StatCalls:       make([]string, 0),
+		TaggingCalls:    make([]string, 0),
+		PresignCalls:    make([]PresignCall, 0),
+		PresignGetCalls: make([]PresignCall, 0),
 	}
 }
 
@@ -63,6 +102,52 @@ func (m *MockStorage) GetObject(ctx context.Context, key string) ([]byte, error)
 	return data, nil
 }
 
+// GetObjectWithInfo retrieves an object and its metadata from mock storage.
+// Calls are recorded in GetCalls alongside GetObject calls.
+func (m *MockStorage) GetObjectWithInfo(ctx context.Context, key string) ([]byte, storage.ObjectInfo, error) {
+	data, err := m.GetObject(ctx, key)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	info := m.infos[key]
+	info.Size = int64(len(data))
+	return data, info, nil
+}
+
+// GetObjectStream returns a reader over an object in mock storage. Calls
+// are recorded in GetCalls alongside GetObject calls.
+func (m *MockStorage) GetObjectStream(ctx context.Context, key string) (io.ReadCloser, storage.ObjectInfo, error) {
+	data, info, err := m.GetObjectWithInfo(ctx, key)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), info, nil
+}
+
+// GetObjectRange returns a reader over length bytes of an object in mock
+// storage starting at start. Calls are recorded in RangeCalls.
+func (m *MockStorage) GetObjectRange(ctx context.Context, key string, start, length int64) (io.ReadCloser, storage.ObjectInfo, error) {
+	m.mu.Lock()
+	m.RangeCalls = append(m.RangeCalls, RangeCall{Key: key, Start: start, Length: length})
+	getErr := m.GetError
+	data, found := m.objects[key]
+	info := m.infos[key]
+	m.mu.Unlock()
+
+	if getErr != nil {
+		return nil, storage.ObjectInfo{}, getErr
+	}
+	if !found {
+		return nil, storage.ObjectInfo{}, ErrObjectNotFound
+	}
+	info.Size = int64(len(data))
+	end := min(start+length, info.Size)
+	return io.NopCloser(bytes.NewReader(data[min(start, end):end])), info, nil
+}
+
 // PutObject stores an object in mock storage
 func (m *MockStorage) PutObject(ctx context.Context, key string, data io.Reader, contentType string) error {
 	m.mu.Lock()
@@ -117,6 +202,122 @@ func (m *MockStorage) ObjectExists(ctx context.Context, key string) (bool, error
 	return found, nil
 }
 
+// StatObject returns the metadata of an object in mock storage
+func (m *MockStorage) StatObject(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.StatCalls = append(m.StatCalls, key)
+
+	if m.StatError != nil {
+		return storage.ObjectInfo{}, m.StatError
+	}
+
+	data, found := m.objects[key]
+	if !found {
+		return storage.ObjectInfo{}, ErrObjectNotFound
+	}
+
+	info := m.infos[key]
+	info.Size = int64(len(data))
+	return info, nil
+}
+
+// GetObjectTagging returns the tags set on an object in mock storage
+func (m *MockStorage) GetObjectTagging(ctx context.Context, key string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.TaggingCalls = append(m.TaggingCalls, key)
+
+	if m.TaggingError != nil {
+		return nil, m.TaggingError
+	}
+
+	if _, found := m.objects[key]; !found {
+		return nil, ErrObjectNotFound
+	}
+
+	tags := make(map[string]string, len(m.tags[key]))
+	for k, v := range m.tags[key] {
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+// ListObjects lists the objects in mock storage under prefix in key order.
+// ETags come from SetObjectInfo.
+func (m *MockStorage) ListObjects(ctx context.Context, prefix string, fn func(storage.ListedObject) error) error {
+	m.mu.Lock()
+	m.ListCalls = append(m.ListCalls, prefix)
+	if m.ListError != nil {
+		m.mu.Unlock()
+		return m.ListError
+	}
+	var listed []storage.ListedObject
+	for key, data := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			listed = append(listed, storage.ListedObject{Key: key, ETag: m.infos[key].ETag, Size: int64(len(data))})
+		}
+	}
+	m.mu.Unlock()
+
+	// fn may call back into the mock, so the lock isn't held
+	slices.SortFunc(listed, func(a, b storage.ListedObject) int { return strings.Compare(a.Key, b.Key) })
+	for _, obj := range listed {
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PresignPutURL returns a fake presigned upload request
+func (m *MockStorage) PresignPutURL(ctx context.Context, key string, expiry time.Duration, contentType string, size int64) (storage.PresignedRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.PresignCalls = append(m.PresignCalls, PresignCall{
+		Key:         key,
+		Expiry:      expiry,
+		ContentType: contentType,
+		Size:        size,
+	})
+
+	if m.PresignError != nil {
+		return storage.PresignedRequest{}, m.PresignError
+	}
+
+	return storage.PresignedRequest{
+		URL:    "https://storage.example.com/" + key + "?X-Amz-Signature=mock",
+		Method: http.MethodPut,
+		Header: http.Header{
+			"Content-Type":   []string{contentType},
+			"Content-Length": []string{strconv.FormatInt(size, 10)},
+			"If-None-Match":  []string{"*"},
+		},
+	}, nil
+}
+
+// PresignGetURL returns a fake presigned download request. PresignError
+// applies to it as well.
+func (m *MockStorage) PresignGetURL(ctx context.Context, key string, expiry time.Duration) (storage.PresignedRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.PresignGetCalls = append(m.PresignGetCalls, PresignCall{Key: key, Expiry: expiry})
+
+	if m.PresignError != nil {
+		return storage.PresignedRequest{}, m.PresignError
+	}
+
+	return storage.PresignedRequest{
+		URL:    "https://storage.example.com/" + key + "?X-Amz-Signature=mock",
+		Method: http.MethodGet,
+		Header: http.Header{},
+	}, nil
+}
+
 // HealthCheck checks mock storage health
 func (m *MockStorage) HealthCheck(ctx context.Context) error {
 	m.mu.Lock()
@@ -133,11 +334,34 @@ func (m *MockStorage) SetObject(key string, data []byte) {
 	m.objects[key] = data
 }
 
+// Puts returns the PutObject calls made so far. It is safe to use while
+// background calls are running.
+func (m *MockStorage) Puts() []PutCall {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]PutCall(nil), m.PutCalls...)
+}
+
+// SetObjectInfo pre-populates the metadata of an object for testing
+func (m *MockStorage) SetObjectInfo(key string, info storage.ObjectInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.infos[key] = info
+}
+
+// SetTags pre-populates the tags of an object for testing
+func (m *MockStorage) SetTags(key string, tags map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tags[key] = tags
+}
+
 // ClearObjects clears all stored objects
 func (m *MockStorage) ClearObjects() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.objects = make(map[string][]byte)
+	m.infos = make(map[string]storage.ObjectInfo)
 }
 
 // Reset resets all mock state
@@ -146,15 +370,27 @@ func (m *MockStorage) Reset() {
 	defer m.mu.Unlock()
 
 	m.objects = make(map[string][]byte)
+	m.infos = make(map[string]storage.ObjectInfo)
+	m.tags = make(map[string]map[string]string)
 	m.GetCalls = make([]string, 0)
 	m.PutCalls = make([]PutCall, 0)
 	m.DeleteCalls = make([]string, 0)
 	m.ExistsCalls = make([]string, 0)
+	m.StatCalls = make([]string, 0)
+	m.RangeCalls = nil
+	m.TaggingCalls = make([]string, 0)
+	m.PresignCalls = make([]PresignCall, 0)
+	m.PresignGetCalls = make([]PresignCall, 0)
+	m.ListCalls = nil
 	m.HealthCheckCalls = 0
 	m.GetError = nil
 	m.PutError = nil
 	m.DeleteError = nil
 	m.ExistsError = nil
+	m.StatError = nil
+	m.TaggingError = nil
+	m.PresignError = nil
+	m.ListError = nil
 	m.HealthCheckError = nil
 }
 
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// RequestID returns the storage provider's request ID carried by err, or ""
+// if there is none. R2 request IDs are needed for Cloudflare support.
+func RequestID(err error) string {
+	// Implemented by the AWS SDK's HTTP response errors
+	var withID interface{ ServiceRequestID() string }
+	if errors.As(err, &withID) {
+		return withID.ServiceRequestID()
+	}
+	return ""
+}
+
+// isMissing reports whether err means the object doesn't exist
+func isMissing(err error) bool {
+	var withStatus interface{ HTTPStatusCode() int }
+	if errors.As(err, &withStatus) && withStatus.HTTPStatusCode() == http.StatusNotFound {
+		return true
+	}
+
+	var withCode interface{ ErrorCode() string }
+	if errors.As(err, &withCode) {
+		switch withCode.ErrorCode() {
+		case "NoSuchKey", "NotFound":
+			return true
+		}
+	}
+	return false
+}
+
+// IsAuthError reports whether err means storage rejected our credentials,
+// as happens briefly while R2 keys are being rotated
+func IsAuthError(err error) bool {
+	var withStatus interface{ HTTPStatusCode() int }
+	if errors.As(err, &withStatus) {
+		switch withStatus.HTTPStatusCode() {
+		case 401, 403:
+			return true
+		}
+	}
+
+	var withCode interface{ ErrorCode() string }
+	if errors.As(err, &withCode) {
+		switch withCode.ErrorCode() {
+		case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken":
+			return true
+		}
+	}
+	return false
+}
+
+// IsThrottled reports whether err means storage is rate limiting us or is
+// briefly unavailable, so the same request is likely to succeed later
+func IsThrottled(err error) bool {
+	var withStatus interface{ HTTPStatusCode() int }
+	if errors.As(err, &withStatus) {
+		switch withStatus.HTTPStatusCode() {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		}
+	}
+
+	var withCode interface{ ErrorCode() string }
+	if errors.As(err, &withCode) {
+		switch withCode.ErrorCode() {
+		case "SlowDown", "TooManyRequests", "ServiceUnavailable", "RequestLimitExceeded":
+			return true
+		}
+	}
+	return false
+}
+
+// IsInvalidRange reports whether err means a ranged read started past the
+// end of the object
+func IsInvalidRange(err error) bool {
+	var withStatus interface{ HTTPStatusCode() int }
+	if errors.As(err, &withStatus) && withStatus.HTTPStatusCode() == http.StatusRequestedRangeNotSatisfiable {
+		return true
+	}
+
+	var withCode interface{ ErrorCode() string }
+	return errors.As(err, &withCode) && withCode.ErrorCode() == "InvalidRange"
+}
+
+// RetryAfter returns how long storage asked us to wait with the
+// Retry-After header of the response behind err, given in seconds or as an
+// HTTP-date relative to now. It reports false if there is no such header.
+func RetryAfter(err error, now time.Time) (time.Duration, bool) {
+	var withResponse interface{ HTTPResponse() *smithyhttp.Response }
+	if !errors.As(err, &withResponse) {
+		return 0, false
+	}
+	resp := withResponse.HTTPResponse()
+	if resp == nil || resp.Response == nil {
+		return 0, false
+	}
+	return parseRetryAfter(resp.Header.Get("Retry-After"), now)
+}
+
+// parseRetryAfter parses a Retry-After header value, either a number of
+// seconds or an HTTP-date, into a delay from now. Dates in the past give 0.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(0, at.Sub(now)), true
+}
@@ -3,17 +3,76 @@ package storage
 import (
 	"context"
 	"io"
+	"net/http"
+	"time"
 )
 
+// ObjectInfo holds metadata stored alongside an object
+type ObjectInfo struct {
+	// ContentType is the Content-Type set at upload time, if any
+	ContentType string
+
+	// ContentEncoding is the encoding the object was uploaded with,
+	// e.g. "gzip" for objects stored pre-compressed
+	ContentEncoding string
+
+	// CacheControl is the Cache-Control metadata set at upload time
+	CacheControl string
+
+	// Size is the object's length in bytes
+	Size int64
+
+	// ETag is the storage entity tag, quoted, e.g. "\"9b2cf535f27731c9\""
+	ETag string
+
+	// LastModified is when the object was last written
+	LastModified time.Time
+
+	// StorageClass is the class the object is stored in, e.g. "STANDARD"
+	// or "STANDARD_IA" for R2 Infrequent Access, which is slower to read
+	StorageClass string
+}
+
+// ListedObject is one object returned by a listing
+type ListedObject struct {
+	Key  string
+	ETag string
+	Size int64
+}
+
+// PresignedRequest is a signed request a client can send directly to storage
+type PresignedRequest struct {
+	URL    string
+	Method string
+
+	// Header lists the headers the client must send with the request
+	Header http.Header
+}
+
 // Storage defines the interface for object storage operations
 // This allows for easy mocking in tests
 type Storage interface {
 	GetObject(ctx context.Context, key string) ([]byte, error)
+	GetObjectWithInfo(ctx context.Context, key string) ([]byte, ObjectInfo, error)
+	GetObjectStream(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	GetObjectRange(ctx context.Context, key string, start, length int64) (io.ReadCloser, ObjectInfo, error)
 	PutObject(ctx context.Context, key string, data io.Reader, contentType string) error
 	DeleteObject(ctx context.Context, key string) error
 	ObjectExists(ctx context.Context, key string) (bool, error)
+	StatObject(ctx context.Context, key string) (ObjectInfo, error)
+	GetObjectTagging(ctx context.Context, key string) (map[string]string, error)
+	ListObjects(ctx context.Context, prefix string, fn func(ListedObject) error) error
+	PresignPutURL(ctx context.Context, key string, expiry time.Duration, contentType string, size int64) (PresignedRequest, error)
+	PresignGetURL(ctx context.Context, key string, expiry time.Duration) (PresignedRequest, error)
 	HealthCheck(ctx context.Context) error
 }
 
 // Ensure R2Client implements Storage interface
 var _ Storage = (*R2Client)(nil)
+
+// ReadFallbackNotifier is implemented by storage that reads from a separate
+// endpoint and can fall back to its primary one, reporting each fallback
+// with the operation that fell back
+type ReadFallbackNotifier interface {
+	OnReadFallback(fn func(operation string))
+}
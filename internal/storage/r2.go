@@ -4,6 +4,11 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
@@ -13,43 +18,186 @@ import (
 type R2Client struct {
 	client     *s3.Client
 	bucketName string
+
+	// readClient serves object reads when a read endpoint is configured,
+	// falling back to client when it fails; nil reads from client
+	readClient *s3.Client
+
+	// onReadFallback is called with the operation whenever a read falls
+	// back from the read endpoint to the primary one
+	onReadFallback func(operation string)
+}
+
+// R2Option configures an R2Client
+type R2Option func(*r2Options)
+
+type r2Options struct {
+	readEndpoint string
 }
 
-func NewR2Client(accountID, accessKeyID, secretAccessKey, bucketName string) (*R2Client, error) {
+// WithReadEndpoint sends object reads (GetObject and HeadObject) to
+// endpoint, e.g. a regional endpoint closer to the service, while writes,
+// listings and presigned URLs keep using the account's primary endpoint.
+// A read that fails on endpoint for any reason other than the object
+// being missing is retried on the primary endpoint. Empty reads from the
+// primary endpoint.
+func WithReadEndpoint(endpoint string) R2Option {
+	return func(o *r2Options) {
+		o.readEndpoint = endpoint
+	}
+}
+
+func NewR2Client(accountID, accessKeyID, secretAccessKey, bucketName string, opts ...R2Option) (*R2Client, error) {
+	var o r2Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	endpoint := fmt.Sprintf("https://%s.r2.cloudflarestorage.com", accountID)
+	creds := credentials.NewStaticCredentialsProvider(
+		accessKeyID,
+		secretAccessKey,
+		"",
+	)
 
 	client := s3.New(s3.Options{
-		Region: "auto",
-		Credentials: credentials.NewStaticCredentialsProvider(
-			accessKeyID,
-			secretAccessKey,
-			"",
-		),
+		Region:       "auto",
+		Credentials:  creds,
 		BaseEndpoint: aws.String(endpoint),
 	})
 
-	return &R2Client{
+	r := &R2Client{
 		client:     client,
 		bucketName: bucketName,
-	}, nil
+	}
+	if o.readEndpoint != "" {
+		if _, err := url.ParseRequestURI(o.readEndpoint); err != nil {
+			return nil, fmt.Errorf("invalid read endpoint %q: %w", o.readEndpoint, err)
+		}
+		r.readClient = s3.New(s3.Options{
+			Region:       "auto",
+			Credentials:  creds,
+			BaseEndpoint: aws.String(o.readEndpoint),
+		})
+	}
+	return r, nil
+}
+
+// OnReadFallback sets fn to be called with the operation, "get" or "head",
+// whenever a read falls back from the read endpoint to the primary one.
+// It must be set before the client is used.
+func (r *R2Client) OnReadFallback(fn func(operation string)) {
+	r.onReadFallback = fn
+}
+
+// read runs op against the read endpoint if there is one, retrying it
+// against the primary endpoint if the read endpoint fails with anything
+// but a missing object
+func read[T any](ctx context.Context, r *R2Client, operation string, op func(*s3.Client) (T, error)) (T, error) {
+	if r.readClient == nil {
+		return op(r.client)
+	}
+
+	out, err := op(r.readClient)
+	if err == nil || isMissing(err) || ctx.Err() != nil {
+		return out, err
+	}
+
+	slog.WarnContext(ctx, "R2 read endpoint failed, retrying on primary endpoint",
+		"operation", operation, "error", err)
+	if r.onReadFallback != nil {
+		r.onReadFallback(operation)
+	}
+	return op(r.client)
 }
 
 func (r *R2Client) GetObject(ctx context.Context, key string) ([]byte, error) {
-	output, err := r.client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(r.bucketName),
-		Key:    aws.String(key),
+	data, _, err := r.GetObjectWithInfo(ctx, key)
+	return data, err
+}
+
+// GetObjectWithInfo returns the object body along with its stored
+// metadata. The returned Size is the object's Content-Length, which a
+// truncated body falls short of.
+func (r *R2Client) GetObjectWithInfo(ctx context.Context, key string) ([]byte, ObjectInfo, error) {
+	body, info, err := r.GetObjectStream(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("failed to read object body: %w", err)
+	}
+
+	// Size stays the Content-Length R2 announced, so callers can tell a
+	// truncated transfer from a complete one
+	return data, info, nil
+}
+
+// GetObjectStream returns the open object body along with its stored
+// metadata. The caller must close the body.
+func (r *R2Client) GetObjectStream(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	output, err := read(ctx, r, "get", func(c *s3.Client) (*s3.GetObjectOutput, error) {
+		return c.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(r.bucketName),
+			Key:    aws.String(key),
+		})
+	})
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+
+	info := ObjectInfo{
+		ContentType:     aws.ToString(output.ContentType),
+		ContentEncoding: aws.ToString(output.ContentEncoding),
+		CacheControl:    aws.ToString(output.CacheControl),
+		Size:            aws.ToInt64(output.ContentLength),
+		ETag:            aws.ToString(output.ETag),
+		LastModified:    aws.ToTime(output.LastModified),
+		StorageClass:    string(output.StorageClass),
+	}
+
+	return output.Body, info, nil
+}
+
+// GetObjectRange returns the open body of length bytes of an object
+// starting at start, so only that window is transferred. The returned
+// Size is the whole object's, not the window's. The caller must close
+// the body.
+func (r *R2Client) GetObjectRange(ctx context.Context, key string, start, length int64) (io.ReadCloser, ObjectInfo, error) {
+	output, err := read(ctx, r, "get", func(c *s3.Client) (*s3.GetObjectOutput, error) {
+		return c.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(r.bucketName),
+			Key:    aws.String(key),
+			Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, start+length-1)),
+		})
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+		return nil, ObjectInfo{}, fmt.Errorf("failed to get range of object %s: %w", key, err)
 	}
-	defer output.Body.Close()
 
-	data, err := io.ReadAll(output.Body)
+	// Content-Range is "bytes <first>-<last>/<size>"
+	_, total, _ := strings.Cut(aws.ToString(output.ContentRange), "/")
+	size, err := strconv.ParseInt(total, 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read object body: %w", err)
+		output.Body.Close()
+		return nil, ObjectInfo{}, fmt.Errorf("failed to get range of object %s: unexpected Content-Range %q",
+			key, aws.ToString(output.ContentRange))
+	}
+
+	info := ObjectInfo{
+		ContentType:     aws.ToString(output.ContentType),
+		ContentEncoding: aws.ToString(output.ContentEncoding),
+		CacheControl:    aws.ToString(output.CacheControl),
+		Size:            size,
+		ETag:            aws.ToString(output.ETag),
+		LastModified:    aws.ToTime(output.LastModified),
+		StorageClass:    string(output.StorageClass),
 	}
 
-	return data, nil
+	return output.Body, info, nil
 }
 
 func (r *R2Client) PutObject(ctx context.Context, key string, data io.Reader, contentType string) error {
@@ -78,17 +226,137 @@ func (r *R2Client) DeleteObject(ctx context.Context, key string) error {
 	return nil
 }
 
+// ObjectExists reports whether key exists. Only a missing object reports
+// false; any other failure is returned, since it says nothing either way.
 func (r *R2Client) ObjectExists(ctx context.Context, key string) (bool, error) {
-	_, err := r.client.HeadObject(ctx, &s3.HeadObjectInput{
+	_, err := read(ctx, r, "head", func(c *s3.Client) (*s3.HeadObjectOutput, error) {
+		return c.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(r.bucketName),
+			Key:    aws.String(key),
+		})
+	})
+	if isMissing(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check object %s: %w", key, err)
+	}
+
+	return true, nil
+}
+
+// StatObject returns an object's metadata without downloading its body
+func (r *R2Client) StatObject(ctx context.Context, key string) (ObjectInfo, error) {
+	output, err := read(ctx, r, "head", func(c *s3.Client) (*s3.HeadObjectOutput, error) {
+		return c.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(r.bucketName),
+			Key:    aws.String(key),
+		})
+	})
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to stat object %s: %w", key, err)
+	}
+
+	return ObjectInfo{
+		ContentType:     aws.ToString(output.ContentType),
+		ContentEncoding: aws.ToString(output.ContentEncoding),
+		CacheControl:    aws.ToString(output.CacheControl),
+		Size:            aws.ToInt64(output.ContentLength),
+		ETag:            aws.ToString(output.ETag),
+		LastModified:    aws.ToTime(output.LastModified),
+		StorageClass:    string(output.StorageClass),
+	}, nil
+}
+
+// GetObjectTagging returns the tags set on an object as a key/value map
+func (r *R2Client) GetObjectTagging(ctx context.Context, key string) (map[string]string, error) {
+	output, err := r.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
 		Bucket: aws.String(r.bucketName),
 		Key:    aws.String(key),
 	})
 	if err != nil {
-		// Check if error is "not found" - object doesn't exist
-		return false, nil
+		return nil, fmt.Errorf("failed to get tagging for object %s: %w", key, err)
 	}
 
-	return true, nil
+	tags := make(map[string]string, len(output.TagSet))
+	for _, tag := range output.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	return tags, nil
+}
+
+// ListObjects calls fn for every object under prefix, in the ascending
+// UTF-8 byte order R2 lists keys in, fetching one page at a time
+func (r *R2Client) ListObjects(ctx context.Context, prefix string, fn func(ListedObject) error) error {
+	paginator := s3.NewListObjectsV2Paginator(r.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			err := fn(ListedObject{
+				Key:  aws.ToString(obj.Key),
+				ETag: aws.ToString(obj.ETag),
+				Size: aws.ToInt64(obj.Size),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PresignPutURL returns a URL that lets a client upload exactly size bytes
+// to key with the given content type until expiry passes. The upload fails
+// if key already exists.
+func (r *R2Client) PresignPutURL(ctx context.Context, key string, expiry time.Duration, contentType string, size int64) (PresignedRequest, error) {
+	presigned, err := s3.NewPresignClient(r.client).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(r.bucketName),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+		IfNoneMatch:   aws.String("*"),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return PresignedRequest{}, fmt.Errorf("failed to presign put for object %s: %w", key, err)
+	}
+
+	// Host is implied by the URL and can't be set by most clients
+	header := presigned.SignedHeader.Clone()
+	header.Del("Host")
+
+	return PresignedRequest{
+		URL:    presigned.URL,
+		Method: presigned.Method,
+		Header: header,
+	}, nil
+}
+
+// PresignGetURL returns a URL that downloads key directly from R2 until
+// expiry passes
+func (r *R2Client) PresignGetURL(ctx context.Context, key string, expiry time.Duration) (PresignedRequest, error) {
+	presigned, err := s3.NewPresignClient(r.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucketName),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return PresignedRequest{}, fmt.Errorf("failed to presign get for object %s: %w", key, err)
+	}
+
+	header := presigned.SignedHeader.Clone()
+	header.Del("Host")
+
+	return PresignedRequest{
+		URL:    presigned.URL,
+		Method: presigned.Method,
+		Header: header,
+	}, nil
 }
 
 // HealthCheck verifies R2 connectivity by checking if the bucket exists